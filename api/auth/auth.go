@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
 
@@ -30,20 +31,29 @@ type AuthMiddleware interface {
 	Middleware() gin.HandlerFunc
 	GetUserUidByMail(ctx *gin.Context, userMail string) (string, error)
 	GetUserByUid(ctx *gin.Context, userUid string) (*auth.UserRecord, error)
+	GetUsersByUids(ctx *gin.Context, userUids []string) (map[string]*auth.UserRecord, error)
 	UserFromCtx(ctx *gin.Context) (User, error)
 }
 
+// maxUsersPerLookup is the maximum number of users Firebase returns for a single lookup
+const maxUsersPerLookup = 100
+
+// accessTokenParam is the query parameter carrying the token of WebSocket requests, as browsers can't set
+// the Authorization header when opening a WebSocket
+const accessTokenParam = "access_token"
+
 type FirebaseAuthMiddleware struct {
 	AuthClient *auth.Client
+	userCache  *userRecordCache
 }
 
 func NewFirebaseAuthMiddleware(authClient *auth.Client) AuthMiddleware {
-	return FirebaseAuthMiddleware{AuthClient: authClient}
+	return FirebaseAuthMiddleware{AuthClient: authClient, userCache: newUserRecordCache()}
 }
 
 func (a FirebaseAuthMiddleware) Middleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		bearerToken := a.tokenFromHeader(ctx.Request)
+		bearerToken := a.tokenFromRequest(ctx.Request)
 		if bearerToken == "" {
 			log.Error(errors.New("empty bearer token"))
 			ctx.String(http.StatusUnauthorized, "Unauthorized")
@@ -81,14 +91,61 @@ func (a FirebaseAuthMiddleware) GetUserUidByMail(ctx *gin.Context, userMail stri
 }
 
 func (a FirebaseAuthMiddleware) GetUserByUid(ctx *gin.Context, userUid string) (*auth.UserRecord, error) {
+	if user, ok := a.userCache.get(userUid); ok {
+		return user, nil
+	}
+
 	user, err := a.AuthClient.GetUser(ctx, userUid)
 	if err != nil {
 		return nil, err
 	}
+	a.userCache.set(user)
 
 	return user, nil
 }
 
+// GetUsersByUids loads the users with the given UIDs in batches, users which don't exist are missing in the result
+func (a FirebaseAuthMiddleware) GetUsersByUids(ctx *gin.Context, userUids []string) (map[string]*auth.UserRecord, error) {
+	users := map[string]*auth.UserRecord{}
+	identifiers := []auth.UserIdentifier{}
+	for _, userUid := range userUids {
+		if _, alreadyRequested := users[userUid]; alreadyRequested {
+			continue
+		}
+		if user, ok := a.userCache.get(userUid); ok {
+			users[userUid] = user
+			continue
+		}
+		users[userUid] = nil
+		identifiers = append(identifiers, auth.UIDIdentifier{UID: userUid})
+	}
+
+	for start := 0; start < len(identifiers); start += maxUsersPerLookup {
+		end := start + maxUsersPerLookup
+		if end > len(identifiers) {
+			end = len(identifiers)
+		}
+
+		result, err := a.AuthClient.GetUsers(ctx, identifiers[start:end])
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get users")
+		}
+
+		for _, user := range result.Users {
+			a.userCache.set(user)
+			users[user.UID] = user
+		}
+	}
+
+	for userUid, user := range users {
+		if user == nil {
+			delete(users, userUid)
+		}
+	}
+
+	return users, nil
+}
+
 func (a FirebaseAuthMiddleware) UserFromCtx(ctx *gin.Context) (User, error) {
 	user, ok := ctx.Get("user")
 	log.Infof("got user %+v from context value 'user'", user)
@@ -114,12 +171,41 @@ func (a FirebaseAuthMiddleware) UserFromCtx(ctx *gin.Context) (User, error) {
 	return User{}, ErrNoUserInContext
 }
 
-func (a FirebaseAuthMiddleware) tokenFromHeader(r *http.Request) string {
+func (a FirebaseAuthMiddleware) tokenFromRequest(r *http.Request) string {
 	headerValue := r.Header.Get("Authorization")
 
 	if len(headerValue) > 7 && strings.ToLower(headerValue[0:6]) == "bearer" {
 		return headerValue[7:]
 	}
 
+	if IsWebSocketRequest(r) {
+		return r.URL.Query().Get(accessTokenParam)
+	}
+
 	return ""
 }
+
+// RedactAccessToken replaces the access token in the query of the path, so the path can be logged
+func RedactAccessToken(path string) string {
+	pathWithoutQuery, rawQuery, hasQuery := strings.Cut(path, "?")
+	if !hasQuery {
+		return path
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// a query which can't be parsed may still carry the token
+		return pathWithoutQuery
+	}
+	if !query.Has(accessTokenParam) {
+		return path
+	}
+	query.Set(accessTokenParam, "REDACTED")
+
+	return pathWithoutQuery + "?" + query.Encode()
+}
+
+// IsWebSocketRequest checks if the request wants to upgrade the connection to a WebSocket
+func IsWebSocketRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
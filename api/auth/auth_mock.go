@@ -24,10 +24,15 @@ func NewMockAuthMiddleware() AuthMiddleware {
 // MockAuthMiddleware is used in the local environment (which doesn't depend on Firebase)
 func (a MockAuthMiddleware) Middleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var extractor request.Extractor = request.AuthorizationHeaderExtractor
+		if IsWebSocketRequest(ctx.Request) {
+			extractor = request.MultiExtractor{request.AuthorizationHeaderExtractor, request.ArgumentExtractor{accessTokenParam}}
+		}
+
 		var claims jwt.MapClaims
 		token, err := request.ParseFromRequest(
 			ctx.Request,
-			request.AuthorizationHeaderExtractor,
+			extractor,
 			func(token *jwt.Token) (i interface{}, e error) {
 				return []byte("mock_secret"), nil
 			},
@@ -62,6 +67,10 @@ func (a MockAuthMiddleware) GetUserByUid(ctx *gin.Context, userMail string) (*au
 	return nil, nil
 }
 
+func (a MockAuthMiddleware) GetUsersByUids(ctx *gin.Context, userUids []string) (map[string]*auth.UserRecord, error) {
+	return map[string]*auth.UserRecord{}, nil
+}
+
 func (a MockAuthMiddleware) UserFromCtx(ctx *gin.Context) (User, error) {
 	return User{}, nil
 }
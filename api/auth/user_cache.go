@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+const (
+	// userRecordCacheTTL is the time a user record is served from the cache before it is loaded again
+	userRecordCacheTTL = 10 * time.Minute
+	// userRecordCacheSize is the number of user records kept, the record which expires first is removed for a new one
+	userRecordCacheSize = 1000
+)
+
+type cachedUserRecord struct {
+	record    *auth.UserRecord
+	expiresAt time.Time
+}
+
+// userRecordCache keeps user records in memory, to avoid loading the same user from Firebase on every request
+type userRecordCache struct {
+	mutex   sync.Mutex
+	records map[string]cachedUserRecord
+}
+
+func newUserRecordCache() *userRecordCache {
+	return &userRecordCache{records: map[string]cachedUserRecord{}}
+}
+
+func (c *userRecordCache) get(userUid string) (*auth.UserRecord, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.records[userUid]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(cached.expiresAt) {
+		delete(c.records, userUid)
+		return nil, false
+	}
+
+	return cached.record, true
+}
+
+func (c *userRecordCache) set(record *auth.UserRecord) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if _, cached := c.records[record.UID]; !cached && len(c.records) >= userRecordCacheSize {
+		c.removeExpired(now)
+	}
+	if _, cached := c.records[record.UID]; !cached && len(c.records) >= userRecordCacheSize {
+		c.removeFirstExpiring()
+	}
+
+	c.records[record.UID] = cachedUserRecord{
+		record:    record,
+		expiresAt: now.Add(userRecordCacheTTL),
+	}
+}
+
+func (c *userRecordCache) removeExpired(now time.Time) {
+	for userUid, cached := range c.records {
+		if now.After(cached.expiresAt) {
+			delete(c.records, userUid)
+		}
+	}
+}
+
+func (c *userRecordCache) removeFirstExpiring() {
+	firstExpiringUserUid := ""
+	var firstExpiresAt time.Time
+	for userUid, cached := range c.records {
+		if firstExpiringUserUid == "" || cached.expiresAt.Before(firstExpiresAt) {
+			firstExpiringUserUid = userUid
+			firstExpiresAt = cached.expiresAt
+		}
+	}
+
+	delete(c.records, firstExpiringUserUid)
+}
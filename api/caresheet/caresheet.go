@@ -0,0 +1,120 @@
+package caresheet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+)
+
+// MaxPeriodDays limits the number of days a care sheet can be generated for
+const MaxPeriodDays = 62
+
+type DoseKind string
+
+const (
+	DOSE_KIND_MEDICINE DoseKind = "Medicine"
+	DOSE_KIND_FOOD     DoseKind = "Food"
+)
+
+type Dose struct {
+	Time   string   `json:"time"`
+	Kind   DoseKind `json:"kind"`
+	Name   string   `json:"name"`
+	Dosage int      `json:"dosage"`
+	Unit   string   `json:"unit"`
+}
+
+type Day struct {
+	Date  time.Time `json:"date"`
+	Doses []Dose    `json:"doses"`
+}
+
+type PetSheet struct {
+	Pet       *repository.Pet        `json:"pet"`
+	Medicines []*repository.Medicine `json:"medicines"`
+	Foods     []*repository.Food     `json:"foods"`
+	Days      []Day                  `json:"days"`
+}
+
+type CareSheet struct {
+	From        time.Time  `json:"from"`
+	Until       time.Time  `json:"until"`
+	GeneratedAt time.Time  `json:"generatedAt"`
+	Pets        []PetSheet `json:"pets"`
+}
+
+// New creates an empty care sheet for the days from the start up to and including the end of the period
+func New(from time.Time, until time.Time) (*CareSheet, error) {
+	from = truncateToDay(from)
+	until = truncateToDay(until)
+
+	if until.Before(from) {
+		return nil, repository.NewValidationError("invalid_care_sheet_period", fmt.Errorf("end of care sheet period '%s' is before its start '%s'", until.Format("2006-01-02"), from.Format("2006-01-02")))
+	}
+	if days := int(until.Sub(from).Hours()/24) + 1; days > MaxPeriodDays {
+		return nil, repository.NewValidationError("invalid_care_sheet_period", fmt.Errorf("care sheet period of %d days exceeds the maximum of %d days", days, MaxPeriodDays))
+	}
+
+	return &CareSheet{
+		From:        from,
+		Until:       until,
+		GeneratedAt: time.Now(),
+		Pets:        []PetSheet{},
+	}, nil
+}
+
+// AddPet adds a pet with its medicines and foods to the care sheet and plans their doses for every day of the period.
+// Medicines which are not given daily are planned on the days they are reminded on, see MedicineFrequency.IsDueOn.
+func (c *CareSheet) AddPet(pet *repository.Pet, medicines []*repository.Medicine, foods []*repository.Food) {
+	petSheet := PetSheet{
+		Pet:       pet,
+		Medicines: medicines,
+		Foods:     foods,
+		Days:      []Day{},
+	}
+
+	for date := c.From; !date.After(c.Until); date = date.AddDate(0, 0, 1) {
+		day := Day{Date: date, Doses: []Dose{}}
+
+		for _, medicine := range medicines {
+			for _, frequency := range medicine.Frequencies {
+				if !frequency.IsDueOn(date) {
+					continue
+				}
+				day.Doses = append(day.Doses, Dose{
+					Time:   frequency.Time,
+					Kind:   DOSE_KIND_MEDICINE,
+					Name:   medicine.Name,
+					Dosage: medicine.Dosage,
+					Unit:   string(medicine.Unit),
+				})
+			}
+		}
+
+		for _, food := range foods {
+			for _, frequency := range food.Frequencies {
+				day.Doses = append(day.Doses, Dose{
+					Time:   frequency.Time,
+					Kind:   DOSE_KIND_FOOD,
+					Name:   food.Name,
+					Dosage: food.Dosage,
+					Unit:   string(food.Unit),
+				})
+			}
+		}
+
+		sort.SliceStable(day.Doses, func(i, j int) bool {
+			return day.Doses[i].Time < day.Doses[j].Time
+		})
+
+		petSheet.Days = append(petSheet.Days, day)
+	}
+
+	c.Pets = append(c.Pets, petSheet)
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
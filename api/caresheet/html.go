@@ -0,0 +1,105 @@
+package caresheet
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var htmlTemplate = template.Must(template.New("caresheet").Funcs(template.FuncMap{
+	"date": func(t interface{ Format(string) string }) string {
+		return t.Format("Mon, 02 Jan 2006")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8" />
+    <title>FurMeds care sheet</title>
+    <style>
+      body { font-family: sans-serif; margin: 2em; }
+      section { page-break-after: always; }
+      table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+      th, td { border: 1px solid #999; padding: 0.3em 0.5em; text-align: left; }
+      .done { width: 3em; }
+    </style>
+  </head>
+  <body>
+    <h1>Care sheet {{date .From}} &ndash; {{date .Until}}</h1>
+    {{range .Pets}}
+    <section>
+      <h2>{{.Pet.Name}}{{if .Pet.Species}} ({{.Pet.Species}}){{end}}</h2>
+
+      {{if .Pet.EmergencyNotes}}
+      <h3>Emergency notes</h3>
+      <p>{{.Pet.EmergencyNotes}}</p>
+      {{end}}
+
+      {{if .Pet.VetContacts}}
+      <h3>Vet contacts</h3>
+      <table>
+        <tr><th>Name</th><th>Phone</th><th>Email</th><th>Address</th></tr>
+        {{range .Pet.VetContacts}}
+        <tr><td>{{.Name}}</td><td>{{.Phone}}</td><td>{{.Email}}</td><td>{{.Address}}</td></tr>
+        {{end}}
+      </table>
+      {{end}}
+
+      {{if .Medicines}}
+      <h3>Medicines</h3>
+      <table>
+        <tr><th>Name</th><th>Dosage</th><th>Schedule</th><th>Stock</th></tr>
+        {{range .Medicines}}
+        <tr>
+          <td>{{.Name}}</td>
+          <td>{{.Dosage}} {{.Unit}}</td>
+          <td>{{range .Frequencies}}{{.Time}} every {{if gt .EveryDays 1}}{{.EveryDays}} days{{else}}day{{end}}<br />{{end}}</td>
+          <td>{{.Stock}} {{.Unit}}</td>
+        </tr>
+        {{end}}
+      </table>
+      {{end}}
+
+      {{if .Foods}}
+      <h3>Foods</h3>
+      <table>
+        <tr><th>Name</th><th>Dosage</th><th>Schedule</th><th>Stock</th></tr>
+        {{range .Foods}}
+        <tr>
+          <td>{{.Name}}</td>
+          <td>{{.Dosage}} {{.Unit}}</td>
+          <td>{{range .Frequencies}}{{.Time}} every day<br />{{end}}</td>
+          <td>{{.Stock}} {{.Unit}}</td>
+        </tr>
+        {{end}}
+      </table>
+      {{end}}
+
+      <h3>Schedule</h3>
+      {{range .Days}}
+      <h4>{{date .Date}}</h4>
+      {{if .Doses}}
+      <table>
+        {{range .Doses}}
+        <tr><td>{{.Time}}</td><td>{{.Kind}}</td><td>{{.Name}}</td><td>{{.Dosage}} {{.Unit}}</td><td class="done">&#9744;</td></tr>
+        {{end}}
+      </table>
+      {{else}}
+      <p>Nothing to give.</p>
+      {{end}}
+      {{end}}
+    </section>
+    {{end}}
+  </body>
+</html>
+`))
+
+// RenderHTML writes the care sheet as printable HTML document
+func RenderHTML(w io.Writer, sheet *CareSheet) error {
+	err := htmlTemplate.Execute(w, sheet)
+	if err != nil {
+		return errors.Wrap(err, "failed to render care sheet as html")
+	}
+
+	return nil
+}
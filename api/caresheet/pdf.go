@@ -0,0 +1,216 @@
+package caresheet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// page layout of the generated PDF in points, the page size is A4
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+type pdfLine struct {
+	text   string
+	size   float64
+	bold   bool
+	indent float64
+}
+
+// RenderPDF writes the care sheet as PDF document. The document only uses the standard
+// Helvetica fonts, so no font files need to be embedded.
+func RenderPDF(w io.Writer, sheet *CareSheet) error {
+	pages := paginate(sheetLines(sheet))
+
+	var document bytes.Buffer
+	offsets := []int{}
+	writeObject := func(content string) {
+		offsets = append(offsets, document.Len())
+		fmt.Fprintf(&document, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	document.WriteString("%PDF-1.4\n")
+
+	// objects 1-4 are the catalog, the page tree and the two fonts, every page gets a page and a content object
+	pageReferences := []string{}
+	for index := range pages {
+		pageReferences = append(pageReferences, fmt.Sprintf("%d 0 R", 5+2*index))
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageReferences, " "), len(pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for index, page := range pages {
+		writeObject(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*index,
+		))
+
+		var content bytes.Buffer
+		y := pdfPageHeight - pdfMargin
+		for _, line := range page {
+			y -= line.size * 1.4
+			font := "F1"
+			if line.bold {
+				font = "F2"
+			}
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, line.size, pdfMargin+line.indent, y, pdfText(line.text))
+		}
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xrefOffset := document.Len()
+	fmt.Fprintf(&document, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&document, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&document, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	_, err := w.Write(document.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "failed to write care sheet pdf")
+	}
+
+	return nil
+}
+
+func sheetLines(sheet *CareSheet) []pdfLine {
+	lines := []pdfLine{
+		{text: fmt.Sprintf("Care sheet %s - %s", sheet.From.Format("Mon, 02 Jan 2006"), sheet.Until.Format("Mon, 02 Jan 2006")), size: 18, bold: true},
+	}
+	heading := func(text string) {
+		lines = append(lines, pdfLine{text: "", size: 6}, pdfLine{text: text, size: 13, bold: true})
+	}
+	text := func(text string, indent float64) {
+		lines = append(lines, wrap(pdfLine{text: text, size: 10, indent: indent})...)
+	}
+
+	for _, petSheet := range sheet.Pets {
+		lines = append(lines, pdfLine{text: "", size: 10}, pdfLine{text: petSheet.Pet.Name, size: 16, bold: true})
+		if petSheet.Pet.Species != "" {
+			text(string(petSheet.Pet.Species), 0)
+		}
+
+		if petSheet.Pet.EmergencyNotes != "" {
+			heading("Emergency notes")
+			for _, note := range strings.Split(petSheet.Pet.EmergencyNotes, "\n") {
+				text(note, 0)
+			}
+		}
+
+		if len(petSheet.Pet.VetContacts) != 0 {
+			heading("Vet contacts")
+			for _, vet := range petSheet.Pet.VetContacts {
+				details := []string{vet.Name}
+				for _, detail := range []string{vet.Phone, vet.Email, vet.Address} {
+					if detail != "" {
+						details = append(details, detail)
+					}
+				}
+				text(strings.Join(details, ", "), 0)
+			}
+		}
+
+		if len(petSheet.Medicines) != 0 {
+			heading("Medicines")
+			for _, medicine := range petSheet.Medicines {
+				text(fmt.Sprintf("%s: %d %s, stock %d %s", medicine.Name, medicine.Dosage, medicine.Unit, medicine.Stock, medicine.Unit), 0)
+				for _, frequency := range medicine.Frequencies {
+					if frequency.EveryDays > 1 {
+						text(fmt.Sprintf("%s every %d days", frequency.Time, frequency.EveryDays), 15)
+					} else {
+						text(fmt.Sprintf("%s every day", frequency.Time), 15)
+					}
+				}
+			}
+		}
+
+		if len(petSheet.Foods) != 0 {
+			heading("Foods")
+			for _, food := range petSheet.Foods {
+				text(fmt.Sprintf("%s: %d %s, stock %d %s", food.Name, food.Dosage, food.Unit, food.Stock, food.Unit), 0)
+				for _, frequency := range food.Frequencies {
+					text(fmt.Sprintf("%s every day", frequency.Time), 15)
+				}
+			}
+		}
+
+		heading("Schedule")
+		for _, day := range petSheet.Days {
+			lines = append(lines, pdfLine{text: day.Date.Format("Mon, 02 Jan 2006"), size: 11, bold: true})
+			if len(day.Doses) == 0 {
+				text("Nothing to give.", 15)
+			}
+			for _, dose := range day.Doses {
+				text(fmt.Sprintf("[  ]  %s  %s: %s, %d %s", dose.Time, dose.Kind, dose.Name, dose.Dosage, dose.Unit), 15)
+			}
+		}
+	}
+
+	return lines
+}
+
+// wrap splits a line into multiple lines so it fits the page, based on an average Helvetica character width
+func wrap(line pdfLine) []pdfLine {
+	maxCharacters := int((pdfPageWidth - 2*pdfMargin - line.indent) / (line.size * 0.5))
+
+	wrappedLines := []pdfLine{}
+	current := ""
+	for _, word := range strings.Fields(line.text) {
+		if current != "" && len(current)+1+len(word) > maxCharacters {
+			wrappedLines = append(wrappedLines, pdfLine{text: current, size: line.size, bold: line.bold, indent: line.indent})
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += word
+	}
+
+	return append(wrappedLines, pdfLine{text: current, size: line.size, bold: line.bold, indent: line.indent})
+}
+
+func paginate(lines []pdfLine) [][]pdfLine {
+	pages := [][]pdfLine{{}}
+	usedHeight := 0.0
+	for _, line := range lines {
+		lineHeight := line.size * 1.4
+		if usedHeight+lineHeight > pdfPageHeight-2*pdfMargin {
+			pages = append(pages, []pdfLine{})
+			usedHeight = 0
+		}
+		pages[len(pages)-1] = append(pages[len(pages)-1], line)
+		usedHeight += lineHeight
+	}
+
+	return pages
+}
+
+// pdfText escapes a text for a PDF string literal and maps it to the Latin-1 part of the WinAnsi encoding
+func pdfText(text string) string {
+	var escaped strings.Builder
+	for _, character := range text {
+		switch {
+		case character == '\\' || character == '(' || character == ')':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(character)
+		case character < 32:
+			escaped.WriteByte(' ')
+		case character < 128:
+			escaped.WriteRune(character)
+		case character >= 160 && character < 256:
+			fmt.Fprintf(&escaped, "\\%03o", character)
+		default:
+			escaped.WriteByte('?')
+		}
+	}
+
+	return escaped.String()
+}
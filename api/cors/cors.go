@@ -4,8 +4,16 @@ import "github.com/gin-gonic/gin"
 
 type CORSMiddleware interface {
 	Middleware() gin.HandlerFunc
+	// AllowsOrigin checks if requests of the origin are allowed, e.g. for opening a WebSocket
+	AllowsOrigin(origin string) bool
 }
 
+const (
+	allowedHeaders = "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match, If-None-Match"
+	exposedHeaders = "ETag"
+	allowedMethods = "POST, OPTIONS, GET, PUT, PATCH, DELETE"
+)
+
 type AllowingCORSMiddleware struct{}
 
 func NewAllowingCORSMiddleware() CORSMiddleware {
@@ -15,9 +23,7 @@ func NewAllowingCORSMiddleware() CORSMiddleware {
 func (c AllowingCORSMiddleware) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		setCORSHeaders(c)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -27,3 +33,49 @@ func (c AllowingCORSMiddleware) Middleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func (c AllowingCORSMiddleware) AllowsOrigin(origin string) bool {
+	return true
+}
+
+// AllowListCORSMiddleware allows requests of the listed origins only
+type AllowListCORSMiddleware struct {
+	allowedOrigins map[string]bool
+}
+
+func NewAllowListCORSMiddleware(allowedOrigins []string) CORSMiddleware {
+	middleware := AllowListCORSMiddleware{allowedOrigins: map[string]bool{}}
+	for _, origin := range allowedOrigins {
+		middleware.allowedOrigins[origin] = true
+	}
+
+	return middleware
+}
+
+func (c AllowListCORSMiddleware) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Writer.Header().Add("Vary", "Origin")
+		if origin := ctx.GetHeader("Origin"); c.AllowsOrigin(origin) {
+			ctx.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			setCORSHeaders(ctx)
+		}
+
+		if ctx.Request.Method == "OPTIONS" {
+			ctx.AbortWithStatus(204)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func (c AllowListCORSMiddleware) AllowsOrigin(origin string) bool {
+	return c.allowedOrigins[origin]
+}
+
+func setCORSHeaders(c *gin.Context) {
+	c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+	c.Writer.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+	c.Writer.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+	c.Writer.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+}
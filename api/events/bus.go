@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// subscriberBacklogWarningSize is the number of queued events of a subscriber from which on every further event
+// is logged as a warning, the queue itself is not limited so publishing never blocks
+const subscriberBacklogWarningSize = 256
+
+type Publisher interface {
+	// Publish queues the event for the subscribers accepting it, except for the ones in handledBy which handled it
+	// before. Handled is called after each subscriber handled the event successfully. The returned channel receives
+	// nil once all subscribers handled the event, or the error of a subscriber which failed to handle it.
+	Publish(event Event, handledBy []string, handled func(subscriberName string)) <-chan error
+}
+
+// delivery is an event queued for a subscriber
+type delivery struct {
+	event   Event
+	handled func(subscriberName string)
+	pending *pendingDelivery
+}
+
+// pendingDelivery tracks the subscribers which still have to handle a published event
+type pendingDelivery struct {
+	mutex       sync.Mutex
+	subscribers int
+	err         error
+	result      chan error
+}
+
+func (p *pendingDelivery) done(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	p.subscribers--
+	if p.subscribers == 0 {
+		p.result <- p.err
+	}
+}
+
+type subscriber struct {
+	name    string
+	accepts func(event Event) bool
+	handle  func(ctx context.Context, event Event) error
+	// queue holds the deliveries not handled yet, queued signals the delivering goroutine about new deliveries
+	queueMutex sync.Mutex
+	queue      []*delivery
+	queued     chan struct{}
+}
+
+// Bus delivers published events asynchronously to its subscribers. Every subscriber has its own queue and
+// receives the events in the order they were published, a slow subscriber does not delay the others.
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers []*subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Publish queues the event for every subscriber accepting it without waiting for any of them
+func (b *Bus) Publish(event Event, handledBy []string, handled func(subscriberName string)) <-chan error {
+	b.mutex.RLock()
+	subscribers := b.subscribers
+	b.mutex.RUnlock()
+
+	isHandled := map[string]bool{}
+	for _, subscriberName := range handledBy {
+		isHandled[subscriberName] = true
+	}
+
+	receivers := []*subscriber{}
+	for _, subscriber := range subscribers {
+		if subscriber.accepts(event) && !isHandled[subscriber.name] {
+			receivers = append(receivers, subscriber)
+		}
+	}
+
+	pending := &pendingDelivery{subscribers: len(receivers), result: make(chan error, 1)}
+	if len(receivers) == 0 {
+		pending.result <- nil
+	}
+	for _, subscriber := range receivers {
+		subscriber.enqueue(&delivery{event: event, handled: handled, pending: pending})
+	}
+
+	return pending.result
+}
+
+// Subscribe registers the handler for all events of type T. The name identifies the subscriber in the logs and in the
+// outbox, so it must not change between releases.
+func Subscribe[T Event](bus *Bus, name string, handler func(ctx context.Context, event T) error) {
+	bus.subscribe(
+		name,
+		func(event Event) bool {
+			_, ok := event.(T)
+			return ok
+		},
+		func(ctx context.Context, event Event) error {
+			return handler(ctx, event.(T))
+		},
+	)
+}
+
+// SubscribeAll registers the handler for every event published on the bus
+func SubscribeAll(bus *Bus, name string, handler func(ctx context.Context, event Event) error) {
+	bus.subscribe(name, func(event Event) bool { return true }, handler)
+}
+
+func (b *Bus) subscribe(name string, accepts func(event Event) bool, handle func(ctx context.Context, event Event) error) {
+	subscriber := &subscriber{
+		name:    name,
+		accepts: accepts,
+		handle:  handle,
+		queued:  make(chan struct{}, 1),
+	}
+
+	b.mutex.Lock()
+	b.subscribers = append(b.subscribers, subscriber)
+	b.mutex.Unlock()
+
+	go func() {
+		for range subscriber.queued {
+			for delivery, ok := subscriber.dequeue(); ok; delivery, ok = subscriber.dequeue() {
+				err := subscriber.deliver(delivery.event)
+				if err == nil && delivery.handled != nil {
+					delivery.handled(subscriber.name)
+				}
+				delivery.pending.done(err)
+			}
+		}
+	}()
+}
+
+func (s *subscriber) enqueue(delivery *delivery) {
+	s.queueMutex.Lock()
+	s.queue = append(s.queue, delivery)
+	backlog := len(s.queue)
+	s.queueMutex.Unlock()
+
+	if backlog > subscriberBacklogWarningSize {
+		log.Warnf("subscriber '%s' is behind by %d events", s.name, backlog)
+	}
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+		// the delivering goroutine was already signaled and takes the delivery with the others
+	}
+}
+
+func (s *subscriber) dequeue() (*delivery, bool) {
+	s.queueMutex.Lock()
+	defer s.queueMutex.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+
+	delivery := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	return delivery, true
+}
+
+func (s *subscriber) deliver(event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("subscriber '%s' panicked on %s event '%s': %v", s.name, event.Name(), event.EventHeader().ID, recovered)
+			log.Error(err)
+		}
+	}()
+
+	err = s.handle(context.Background(), event)
+	if err != nil {
+		err = errors.Wrapf(err, "subscriber '%s' failed to handle %s event '%s'", s.name, event.Name(), event.EventHeader().ID)
+		log.Error(err)
+	}
+
+	return err
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/google/uuid"
+)
+
+type Name string
+
+const (
+	EVENT_NAME_PET_CREATED      Name = "PetCreated"
+	EVENT_NAME_PET_UPDATED      Name = "PetUpdated"
+	EVENT_NAME_PET_DELETED      Name = "PetDeleted"
+	EVENT_NAME_MEDICINE_CREATED Name = "MedicineCreated"
+	EVENT_NAME_MEDICINE_UPDATED Name = "MedicineUpdated"
+	EVENT_NAME_MEDICINE_DELETED Name = "MedicineDeleted"
+	EVENT_NAME_FOOD_CREATED     Name = "FoodCreated"
+	EVENT_NAME_FOOD_UPDATED     Name = "FoodUpdated"
+	EVENT_NAME_FOOD_DELETED     Name = "FoodDeleted"
+	EVENT_NAME_SHARE_INVITED    Name = "ShareInvited"
+	EVENT_NAME_SHARE_ACCEPTED   Name = "ShareAccepted"
+	EVENT_NAME_TODO_UPDATED     Name = "ToDoUpdated"
+	EVENT_NAME_TODO_COMPLETED   Name = "ToDoCompleted"
+	EVENT_NAME_DOSE_RECORDED    Name = "DoseRecorded"
+	EVENT_NAME_STOCK_LOW        Name = "StockLow"
+)
+
+// Event is a change in the domain which other parts of the application may react to
+type Event interface {
+	Name() Name
+	EventHeader() Header
+}
+
+// Header holds what every event has in common, ActorUid is the user whose request caused the event
+type Header struct {
+	ID         string    `json:"id"`
+	OccurredAt time.Time `json:"occurredAt"`
+	ActorUid   string    `json:"actorUid"`
+	PetUuid    string    `json:"petUuid"`
+}
+
+func NewHeader(actorUid string, petUuid string) Header {
+	return Header{
+		ID:         uuid.NewString(),
+		OccurredAt: time.Now(),
+		ActorUid:   actorUid,
+		PetUuid:    petUuid,
+	}
+}
+
+func (h Header) EventHeader() Header {
+	return h
+}
+
+type PetCreated struct {
+	Header
+	Pet *repository.Pet `json:"pet"`
+}
+
+func (PetCreated) Name() Name { return EVENT_NAME_PET_CREATED }
+
+type PetUpdated struct {
+	Header
+	Pet *repository.Pet `json:"pet"`
+}
+
+func (PetUpdated) Name() Name { return EVENT_NAME_PET_UPDATED }
+
+type PetDeleted struct {
+	Header
+	Pet *repository.Pet `json:"pet"`
+}
+
+func (PetDeleted) Name() Name { return EVENT_NAME_PET_DELETED }
+
+type MedicineCreated struct {
+	Header
+	Medicine *repository.Medicine `json:"medicine"`
+}
+
+func (MedicineCreated) Name() Name { return EVENT_NAME_MEDICINE_CREATED }
+
+type MedicineUpdated struct {
+	Header
+	Medicine *repository.Medicine `json:"medicine"`
+}
+
+func (MedicineUpdated) Name() Name { return EVENT_NAME_MEDICINE_UPDATED }
+
+type MedicineDeleted struct {
+	Header
+	Medicine *repository.Medicine `json:"medicine"`
+}
+
+func (MedicineDeleted) Name() Name { return EVENT_NAME_MEDICINE_DELETED }
+
+type FoodCreated struct {
+	Header
+	Food *repository.Food `json:"food"`
+}
+
+func (FoodCreated) Name() Name { return EVENT_NAME_FOOD_CREATED }
+
+type FoodUpdated struct {
+	Header
+	Food *repository.Food `json:"food"`
+}
+
+func (FoodUpdated) Name() Name { return EVENT_NAME_FOOD_UPDATED }
+
+type FoodDeleted struct {
+	Header
+	Food *repository.Food `json:"food"`
+}
+
+func (FoodDeleted) Name() Name { return EVENT_NAME_FOOD_DELETED }
+
+// ShareInvited is published when the owner of a pet invites another user to care for it
+type ShareInvited struct {
+	Header
+	Pet        *repository.Pet `json:"pet"`
+	InviteeUid string          `json:"inviteeUid"`
+}
+
+func (ShareInvited) Name() Name { return EVENT_NAME_SHARE_INVITED }
+
+// ShareAccepted is published when an invited user accepts to care for a pet, the actor is the invitee
+type ShareAccepted struct {
+	Header
+	Pet   *repository.Pet      `json:"pet"`
+	Share repository.PetShares `json:"share"`
+}
+
+func (ShareAccepted) Name() Name { return EVENT_NAME_SHARE_ACCEPTED }
+
+// ToDoUpdated is published on every status change of a todo, including todos which are opened again
+type ToDoUpdated struct {
+	Header
+	ToDo *repository.ToDo `json:"todo"`
+}
+
+func (ToDoUpdated) Name() Name { return EVENT_NAME_TODO_UPDATED }
+
+type ToDoCompleted struct {
+	Header
+	ToDo *repository.ToDo `json:"todo"`
+}
+
+func (ToDoCompleted) Name() Name { return EVENT_NAME_TODO_COMPLETED }
+
+// DoseRecorded is published when the todo of a medicine dose is completed
+type DoseRecorded struct {
+	Header
+	ToDo *repository.ToDo `json:"todo"`
+}
+
+func (DoseRecorded) Name() Name { return EVENT_NAME_DOSE_RECORDED }
+
+// StockLow is published when the stock of a medicine or food falls below the low stock threshold
+type StockLow struct {
+	Header
+	ResourceUuid string  `json:"resourceUuid"`
+	ResourceName string  `json:"resourceName"`
+	Stock        int     `json:"stock"`
+	Unit         string  `json:"unit"`
+	DaysLeft     float64 `json:"daysLeft"`
+}
+
+func (StockLow) Name() Name { return EVENT_NAME_STOCK_LOW }
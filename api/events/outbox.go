@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+// decoders restore the typed events from the outbox, every event type needs to be registered here
+var decoders = map[Name]func(payload []byte) (Event, error){}
+
+func register[T Event]() {
+	var event T
+	decoders[event.Name()] = func(payload []byte) (Event, error) {
+		var event T
+		err := json.Unmarshal(payload, &event)
+		return event, err
+	}
+}
+
+func init() {
+	register[PetCreated]()
+	register[PetUpdated]()
+	register[PetDeleted]()
+	register[MedicineCreated]()
+	register[MedicineUpdated]()
+	register[MedicineDeleted]()
+	register[FoodCreated]()
+	register[FoodUpdated]()
+	register[FoodDeleted]()
+	register[ShareInvited]()
+	register[ShareAccepted]()
+	register[ToDoUpdated]()
+	register[ToDoCompleted]()
+	register[DoseRecorded]()
+	register[StockLow]()
+}
+
+// WithOutbox stores the events in the outbox together with the next repository write using the returned context.
+// The events are built inside the transaction, after the update functions of the write ran.
+func WithOutbox(ctx context.Context, eventsFn func() []Event) context.Context {
+	return repository.WithOutbox(ctx, func() ([]*repository.OutboxEntry, error) {
+		entries := []*repository.OutboxEntry{}
+		for _, event := range eventsFn() {
+			entry, err := newOutboxEntry(event)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+
+		return entries, nil
+	})
+}
+
+func newOutboxEntry(event Event) (*repository.OutboxEntry, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s event", event.Name())
+	}
+
+	header := event.EventHeader()
+	return &repository.OutboxEntry{
+		ID:        header.ID,
+		Name:      string(event.Name()),
+		PetUUID:   header.PetUuid,
+		Payload:   string(payload),
+		CreatedAt: header.OccurredAt,
+	}, nil
+}
+
+func decodeOutboxEntry(entry *repository.OutboxEntry) (Event, error) {
+	decode, ok := decoders[Name(entry.Name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown event '%s' in outbox entry '%s'", entry.Name, entry.ID)
+	}
+
+	event, err := decode([]byte(entry.Payload))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s event of outbox entry '%s'", entry.Name, entry.ID)
+	}
+
+	return event, nil
+}
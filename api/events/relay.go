@@ -0,0 +1,146 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	relayInterval  = time.Second
+	relayBatchSize = 100
+	// maxRelayedEntriesInFlight limits the entries published but not handled by all subscribers yet, the relay
+	// stops publishing more entries while a subscriber is that far behind
+	maxRelayedEntriesInFlight = 1000
+)
+
+// Relay publishes the events of the outbox on the bus. An entry is removed from the outbox only after all
+// subscribers handled it, so every event is handled at least once, even if the relay stops in between. The entry
+// records which subscribers handled it, so an entry published once more is only delivered to the others.
+type Relay struct {
+	outboxRepository repository.OutboxRepository
+	bus              Publisher
+	// published maps the IDs of the published entries to true while they are handled. Removed entries are kept until
+	// the outbox doesn't return them anymore, as a query running while they were removed may still return them.
+	mutex     sync.Mutex
+	published map[string]bool
+}
+
+func NewRelay(outboxRepository repository.OutboxRepository, bus Publisher) *Relay {
+	return &Relay{outboxRepository: outboxRepository, bus: bus, published: map[string]bool{}}
+}
+
+// Start relays the outbox every second until the context is done
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(relayInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := r.RelayOutbox(ctx)
+			if err != nil {
+				log.Error(errors.Wrap(err, "failed to relay outbox"))
+			}
+		}
+	}()
+}
+
+// RelayOutbox publishes the oldest events of the outbox in the order they were written. Entries which are still
+// handled by a subscriber are not published again, a failed entry is published again on the next run.
+func (r *Relay) RelayOutbox(ctx context.Context) error {
+	r.mutex.Lock()
+	published := len(r.published)
+	r.mutex.Unlock()
+	if published >= maxRelayedEntriesInFlight {
+		return nil
+	}
+
+	entries, err := r.outboxRepository.GetOutboxEntries(ctx, published+relayBatchSize)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	isReturned := map[string]bool{}
+	for _, entry := range entries {
+		isReturned[entry.ID] = true
+	}
+	for entryId, isHandling := range r.published {
+		if !isHandling && !isReturned[entryId] {
+			delete(r.published, entryId)
+		}
+	}
+	r.mutex.Unlock()
+
+	undecodableEntryIds := []string{}
+	for _, entry := range entries {
+		r.mutex.Lock()
+		_, isPublished := r.published[entry.ID]
+		if !isPublished {
+			r.published[entry.ID] = true
+		}
+		r.mutex.Unlock()
+		if isPublished {
+			continue
+		}
+
+		event, err := decodeOutboxEntry(entry)
+		if err != nil {
+			// an entry which can't be decoded would block the outbox forever, so it is dropped
+			log.Error(err)
+			undecodableEntryIds = append(undecodableEntryIds, entry.ID)
+			r.release(entry.ID, true)
+			continue
+		}
+
+		entryId := entry.ID
+		result := r.bus.Publish(event, entry.HandledBy, func(subscriberName string) {
+			err := r.outboxRepository.MarkOutboxEntryHandled(ctx, entryId, subscriberName)
+			if err != nil {
+				log.Error(err)
+			}
+		})
+		go r.awaitHandled(ctx, entryId, result)
+	}
+
+	return r.outboxRepository.DeleteOutboxEntries(ctx, undecodableEntryIds)
+}
+
+// awaitHandled removes the entry from the outbox once all subscribers handled its event
+func (r *Relay) awaitHandled(ctx context.Context, entryId string, result <-chan error) {
+	err := <-result
+	if err != nil {
+		log.Error(errors.Wrapf(err, "outbox entry '%s' is published again as it wasn't handled by all subscribers", entryId))
+		r.release(entryId, false)
+		return
+	}
+
+	err = r.outboxRepository.DeleteOutboxEntries(ctx, []string{entryId})
+	if err != nil {
+		log.Error(err)
+	}
+	r.release(entryId, err == nil)
+}
+
+// release marks a removed entry to be forgotten once the outbox doesn't return it anymore, an entry which is
+// not removed is forgotten right away to be published again
+func (r *Relay) release(entryId string, isRemoved bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if isRemoved {
+		r.published[entryId] = false
+	} else {
+		delete(r.published, entryId)
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+// maxActivitiesPerPage limits the size of a page of the activity of a pet, it is also the default page size
+const maxActivitiesPerPage = 100
+
+type ActivityHandler interface {
+	GetAllForPet(ctx context.Context, pet *repository.Pet, cursor string, limit int) (*repository.ActivityPage, error)
+}
+
+type ActivityHandle struct {
+	activityRepository repository.ActivityRepository
+}
+
+func NewActivityHandler(activityRepository repository.ActivityRepository) ActivityHandler {
+	return ActivityHandle{activityRepository}
+}
+
+// GetAllForPet returns a page of who changed what of the pet and its medicines and foods, newest first. A limit of
+// 0 returns the largest page.
+func (h ActivityHandle) GetAllForPet(ctx context.Context, pet *repository.Pet, cursor string, limit int) (*repository.ActivityPage, error) {
+	if limit == 0 {
+		limit = maxActivitiesPerPage
+	}
+	if limit < 0 || limit > maxActivitiesPerPage {
+		return nil, repository.NewValidationError("invalid_limit", errors.Errorf("limit has to be between 1 and %d", maxActivitiesPerPage))
+	}
+
+	return h.activityRepository.GetActivitiesForPet(ctx, pet.UUID.String(), cursor, limit)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/caresheet"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+type CareSheetHandler interface {
+	Create(ctx context.Context, userUid string, petUuids []string, from time.Time, until time.Time) (*caresheet.CareSheet, error)
+}
+
+type CareSheetHandle struct {
+	petRepository      repository.PetRepository
+	medicineRepository repository.MedicineRepository
+	foodRepository     repository.FoodRepository
+}
+
+func NewCareSheetHandler(petRepository repository.PetRepository, medicineRepository repository.MedicineRepository, foodRepository repository.FoodRepository) CareSheetHandler {
+	return CareSheetHandle{petRepository, medicineRepository, foodRepository}
+}
+
+// Create builds a care sheet for the given pets, or all active pets of the user if no pets are given
+func (h CareSheetHandle) Create(ctx context.Context, userUid string, petUuids []string, from time.Time, until time.Time) (*caresheet.CareSheet, error) {
+	sheet, err := caresheet.New(from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	pets := []*repository.Pet{}
+	if len(petUuids) == 0 {
+		userPets, err := h.petRepository.GetPets(ctx, userUid)
+		if err != nil {
+			return nil, err
+		}
+
+		// archived pets have no doses to plan, they are only included if they are asked for
+		for _, pet := range userPets {
+			if !pet.IsArchived() {
+				pets = append(pets, pet)
+			}
+		}
+	}
+	for _, petUuid := range petUuids {
+		pet, err := h.petRepository.GetPet(ctx, userUid, petUuid)
+		if err != nil {
+			return nil, err
+		}
+		pets = append(pets, pet)
+	}
+
+	for _, pet := range pets {
+		medicines, err := h.medicineRepository.GetMedicines(ctx, userUid, pet.UUID.String())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get medicines for care sheet of pet %s", pet.UUID.String())
+		}
+
+		foods, err := h.foodRepository.GetFoods(ctx, userUid, pet.UUID.String())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get foods for care sheet of pet %s", pet.UUID.String())
+		}
+
+		sheet.AddPet(pet, medicines, foods)
+	}
+
+	return sheet, nil
+}
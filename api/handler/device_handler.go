@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cafo13/fur-meds/api/notify"
+	"github.com/cafo13/fur-meds/api/repository"
+)
+
+type DeviceHandler interface {
+	Register(ctx context.Context, userUid string, request *repository.RegisterDeviceRequest) ([]*repository.Device, error)
+	GetAllForUser(ctx context.Context, userUid string) ([]*repository.Device, error)
+	Unregister(ctx context.Context, userUid string, token string) ([]*repository.Device, error)
+}
+
+type DeviceHandle struct {
+	deviceRepository repository.DeviceRepository
+}
+
+func NewDeviceHandler(deviceRepository repository.DeviceRepository) DeviceHandler {
+	return DeviceHandle{deviceRepository}
+}
+
+func (h DeviceHandle) Register(ctx context.Context, userUid string, request *repository.RegisterDeviceRequest) ([]*repository.Device, error) {
+	if request.Token == "" {
+		return nil, repository.NewValidationError("invalid_device", errors.New("push token of device is missing"))
+	}
+	if request.Provider != repository.PUSH_TOKEN_PROVIDER_FCM && request.Provider != repository.PUSH_TOKEN_PROVIDER_APNS {
+		return nil, repository.NewValidationError("invalid_device", fmt.Errorf("unknown push token provider '%s'", request.Provider))
+	}
+	if request.Platform != repository.DEVICE_PLATFORM_ANDROID && request.Platform != repository.DEVICE_PLATFORM_IOS && request.Platform != repository.DEVICE_PLATFORM_WEB {
+		return nil, repository.NewValidationError("invalid_device", fmt.Errorf("unknown device platform '%s'", request.Platform))
+	}
+	for _, mutedNotificationType := range request.MutedNotificationTypes {
+		if !notify.IsNotificationType(mutedNotificationType) {
+			return nil, repository.NewValidationError("invalid_device", fmt.Errorf("unknown notification type '%s'", mutedNotificationType))
+		}
+	}
+
+	return h.deviceRepository.RegisterDevice(ctx, userUid, &repository.Device{
+		Token:                  request.Token,
+		Provider:               request.Provider,
+		Platform:               request.Platform,
+		AppVersion:             request.AppVersion,
+		MutedNotificationTypes: request.MutedNotificationTypes,
+	})
+}
+
+func (h DeviceHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repository.Device, error) {
+	return h.deviceRepository.GetDevices(ctx, userUid)
+}
+
+func (h DeviceHandle) Unregister(ctx context.Context, userUid string, token string) ([]*repository.Device, error) {
+	return h.deviceRepository.DeleteDevice(ctx, userUid, token)
+}
@@ -2,97 +2,117 @@ package handler
 
 import (
 	"context"
-	"reflect"
+	"encoding/json"
 
+	"github.com/cafo13/fur-meds/api/events"
 	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/validation"
+	"github.com/google/uuid"
 )
 
+// FoodHandler manages the foods of a pet, the pet is loaded and the access of the user is checked before
 type FoodHandler interface {
-	Create(ctx context.Context, userUid string, petUuid string, food *repository.Food) ([]*repository.Food, error)
-	Get(ctx context.Context, userUid string, foodUuid string) (*repository.Food, error)
-	Update(ctx context.Context, userUid string, foodUuid string, food *repository.Food) ([]*repository.Food, error)
-	Delete(ctx context.Context, userUid string, foodUuid string) ([]*repository.Food, error)
-	GetAllForPet(ctx context.Context, userUid string, petUuid string) ([]*repository.Food, error)
+	Create(ctx context.Context, userUid string, pet *repository.Pet, food *repository.Food) ([]*repository.Food, error)
+	Get(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string) (*repository.Food, error)
+	Update(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string, food *repository.Food) ([]*repository.Food, error)
+	Patch(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string, patch map[string]json.RawMessage) ([]*repository.Food, error)
+	Delete(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string) ([]*repository.Food, error)
+	GetAllForPet(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Food, error)
 }
 
 type FoodHandle struct {
 	foodRepository repository.FoodRepository
-	petRepository  repository.PetRepository
 }
 
-func NewFoodHandler(foodRepository repository.FoodRepository, petRepository repository.PetRepository) FoodHandler {
-	return FoodHandle{foodRepository, petRepository}
+func NewFoodHandler(foodRepository repository.FoodRepository) FoodHandler {
+	return FoodHandle{foodRepository}
 }
 
-func (h FoodHandle) Create(ctx context.Context, userUid string, petUuid string, food *repository.Food) ([]*repository.Food, error) {
-	foods, err := h.foodRepository.AddFood(ctx, userUid, petUuid, food)
+func (h FoodHandle) Create(ctx context.Context, userUid string, pet *repository.Pet, food *repository.Food) ([]*repository.Food, error) {
+	err := checkPetWritable(pet)
 	if err != nil {
 		return nil, err
 	}
 
-	return foods, nil
-}
+	// only the mutations of offline clients choose the UUID of a new food, see SyncHandle.ApplyMutations
+	food.UUID = uuid.Nil
 
-func (h FoodHandle) Get(ctx context.Context, userUid string, foodUuid string) (*repository.Food, error) {
-	food, err := h.foodRepository.GetFood(ctx, userUid, foodUuid)
+	err = validation.Validate(food)
 	if err != nil {
 		return nil, err
 	}
 
-	hasAccess, err := h.petRepository.UserHasAccessToPet(ctx, userUid, food.PetUUID.String())
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.FoodCreated{Header: events.NewHeader(userUid, pet.UUID.String()), Food: food}}
+	})
+
+	foods, err := h.foodRepository.AddFood(ctx, userUid, pet.UUID.String(), food)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, &repository.NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: food.PetUUID.String(),
-		}
-	}
+	return foods, nil
+}
 
-	return food, nil
+func (h FoodHandle) Get(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string) (*repository.Food, error) {
+	return h.foodRepository.GetFood(ctx, userUid, pet.UUID.String(), foodUuid)
 }
 
-func (h FoodHandle) Update(ctx context.Context, userUid string, foodUuid string, food *repository.Food) ([]*repository.Food, error) {
-	food, err := h.foodRepository.GetFood(ctx, userUid, foodUuid)
+// Update replaces all fields of the food which can be changed by the fields of the given food
+func (h FoodHandle) Update(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string, food *repository.Food) ([]*repository.Food, error) {
+	err := validation.Validate(food)
 	if err != nil {
 		return nil, err
 	}
-	petUuid := food.PetUUID.String()
 
-	hasAccess, err := h.petRepository.UserHasAccessToPet(ctx, userUid, petUuid)
+	return h.update(ctx, userUid, pet, foodUuid, func(firestoreFood *repository.Food) error {
+		firestoreFood.Name = food.Name
+		firestoreFood.Dosage = food.Dosage
+		firestoreFood.Unit = food.Unit
+		firestoreFood.Stock = food.Stock
+		firestoreFood.Frequencies = food.Frequencies
+
+		return nil
+	})
+}
+
+// Patch applies a JSON merge patch to the food
+func (h FoodHandle) Patch(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string, patch map[string]json.RawMessage) ([]*repository.Food, error) {
+	return h.update(ctx, userUid, pet, foodUuid, func(firestoreFood *repository.Food) error {
+		err := applyMergePatch(firestoreFood, mutableFields[repository.MUTATION_KIND_FOOD], patch)
+		if err != nil {
+			return err
+		}
+
+		return validation.Validate(firestoreFood)
+	})
+}
+
+func (h FoodHandle) update(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string, changeFn func(firestoreFood *repository.Food) error) ([]*repository.Food, error) {
+	err := checkPetWritable(pet)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, &repository.NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: petUuid,
-		}
-	}
+	var daysLeftBefore, daysLeft float64
+	var updatedFood *repository.Food
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return foodUpdatedEvents(userUid, updatedFood, daysLeftBefore, daysLeft)
+	})
 
 	foods, err := h.foodRepository.UpdateFood(
 		ctx,
 		userUid,
+		pet.UUID.String(),
 		foodUuid,
 		func(context context.Context, firestoreFood *repository.Food) (*repository.Food, error) {
-			if food.Name != "" && food.Name != firestoreFood.Name {
-				firestoreFood.Name = food.Name
-			}
-			if food.Dosage != 0 && food.Dosage != firestoreFood.Dosage {
-				firestoreFood.Dosage = food.Dosage
-			}
-			if food.Unit != "" && food.Unit != firestoreFood.Unit {
-				firestoreFood.Unit = food.Unit
-			}
-			if food.Stock != 0 && food.Stock != firestoreFood.Stock {
-				firestoreFood.Stock = food.Stock
-			}
-			if len(food.Frequencies) != 0 && !reflect.DeepEqual(food.Frequencies, firestoreFood.Frequencies) {
-				firestoreFood.Frequencies = food.Frequencies
+			daysLeftBefore = stockDaysLeft(firestoreFood.Stock, firestoreFood.DailyConsumption())
+			err := changeFn(firestoreFood)
+			if err != nil {
+				return nil, err
 			}
+			daysLeft = stockDaysLeft(firestoreFood.Stock, firestoreFood.DailyConsumption())
+			updatedFood = firestoreFood
 
 			return firestoreFood, nil
 		},
@@ -104,28 +124,41 @@ func (h FoodHandle) Update(ctx context.Context, userUid string, foodUuid string,
 	return foods, nil
 }
 
-func (h FoodHandle) Delete(ctx context.Context, userUid string, foodUuid string) ([]*repository.Food, error) {
-	food, err := h.foodRepository.GetFood(ctx, userUid, foodUuid)
+func (h FoodHandle) Delete(ctx context.Context, userUid string, pet *repository.Pet, foodUuid string) ([]*repository.Food, error) {
+	err := checkPetWritable(pet)
 	if err != nil {
 		return nil, err
 	}
-	petUuid := food.PetUUID.String()
 
-	hasAccess, err := h.petRepository.UserHasAccessToPet(ctx, userUid, petUuid)
+	food, err := h.Get(ctx, userUid, pet, foodUuid)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, &repository.NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: petUuid,
-		}
-	}
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.FoodDeleted{Header: events.NewHeader(userUid, pet.UUID.String()), Food: food}}
+	})
+
+	return h.foodRepository.DeleteFood(ctx, userUid, pet.UUID.String(), foodUuid)
+}
 
-	return h.foodRepository.DeleteFood(ctx, userUid, foodUuid)
+func (h FoodHandle) GetAllForPet(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Food, error) {
+	return h.foodRepository.GetFoods(ctx, userUid, pet.UUID.String())
 }
 
-func (h FoodHandle) GetAllForPet(ctx context.Context, userUid string, petUuid string) ([]*repository.Food, error) {
-	return h.foodRepository.GetFoods(ctx, userUid, petUuid)
+// foodUpdatedEvents are the events of an updated food, the stock is low if it runs out within lowStockDays after the update
+func foodUpdatedEvents(userUid string, updatedFood *repository.Food, daysLeftBefore float64, daysLeft float64) []events.Event {
+	foodEvents := []events.Event{events.FoodUpdated{Header: events.NewHeader(userUid, updatedFood.PetUUID.String()), Food: updatedFood}}
+	if isStockRunningLow(daysLeftBefore, daysLeft) {
+		foodEvents = append(foodEvents, events.StockLow{
+			Header:       events.NewHeader(userUid, updatedFood.PetUUID.String()),
+			ResourceUuid: updatedFood.UUID.String(),
+			ResourceName: updatedFood.Name,
+			Stock:        updatedFood.Stock,
+			Unit:         string(updatedFood.Unit),
+			DaysLeft:     daysLeft,
+		})
+	}
+
+	return foodEvents
 }
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/cafo13/fur-meds/api/repository"
+)
+
+type HouseholdHandler interface {
+	Create(ctx context.Context, userUid string, household *repository.Household) ([]*repository.Household, error)
+	Get(ctx context.Context, userUid string, householdUuid string) (*repository.Household, error)
+	GetAllForUser(ctx context.Context, userUid string) ([]*repository.Household, error)
+	Update(ctx context.Context, userUid string, householdUuid string, household *repository.Household) ([]*repository.Household, error)
+	Delete(ctx context.Context, userUid string, householdUuid string) ([]*repository.Household, error)
+	AddMember(ctx context.Context, userUid string, householdUuid string, memberUid string, role repository.HouseholdRole) ([]*repository.Household, error)
+	RemoveMember(ctx context.Context, userUid string, householdUuid string, memberUid string) ([]*repository.Household, error)
+	AddPet(ctx context.Context, userUid string, householdUuid string, petUuid string) ([]*repository.Pet, error)
+	RemovePet(ctx context.Context, userUid string, householdUuid string, petUuid string) ([]*repository.Pet, error)
+}
+
+type HouseholdHandle struct {
+	householdRepository repository.HouseholdRepository
+	petRepository       repository.PetRepository
+}
+
+func NewHouseholdHandler(householdRepository repository.HouseholdRepository, petRepository repository.PetRepository) HouseholdHandler {
+	return HouseholdHandle{householdRepository, petRepository}
+}
+
+func (h HouseholdHandle) Create(ctx context.Context, userUid string, household *repository.Household) ([]*repository.Household, error) {
+	return h.householdRepository.AddHousehold(ctx, userUid, household)
+}
+
+func (h HouseholdHandle) Get(ctx context.Context, userUid string, householdUuid string) (*repository.Household, error) {
+	return h.householdRepository.GetHousehold(ctx, userUid, householdUuid)
+}
+
+func (h HouseholdHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repository.Household, error) {
+	return h.householdRepository.GetHouseholds(ctx, userUid)
+}
+
+func (h HouseholdHandle) Update(ctx context.Context, userUid string, householdUuid string, household *repository.Household) ([]*repository.Household, error) {
+	return h.householdRepository.UpdateHousehold(
+		ctx,
+		userUid,
+		householdUuid,
+		func(context context.Context, firestoreHousehold *repository.Household) (*repository.Household, error) {
+			if !firestoreHousehold.IsOwner(userUid) {
+				return nil, &repository.NoAccessToHouseholdError{UserUid: userUid, HouseholdUuid: householdUuid}
+			}
+
+			if household.Name != "" && household.Name != firestoreHousehold.Name {
+				firestoreHousehold.Name = household.Name
+			}
+
+			return firestoreHousehold, nil
+		},
+	)
+}
+
+func (h HouseholdHandle) Delete(ctx context.Context, userUid string, householdUuid string) ([]*repository.Household, error) {
+	// the detached pets are kept by UUID, so a retried transaction replaces the pets of its previous attempt
+	detachedPets := map[string]*repository.Pet{}
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		petEvents := []events.Event{}
+		for petUuid, pet := range detachedPets {
+			petEvents = append(petEvents, events.PetUpdated{Header: events.NewHeader(userUid, petUuid), Pet: pet})
+		}
+
+		return petEvents
+	})
+
+	return h.householdRepository.DeleteHousehold(
+		ctx,
+		userUid,
+		householdUuid,
+		func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
+			detachedPets[firestorePet.UUID.String()] = firestorePet
+
+			return firestorePet, nil
+		},
+	)
+}
+
+func (h HouseholdHandle) AddMember(ctx context.Context, userUid string, householdUuid string, memberUid string, role repository.HouseholdRole) ([]*repository.Household, error) {
+	if role == "" {
+		role = repository.HOUSEHOLD_ROLE_MEMBER
+	}
+	if role != repository.HOUSEHOLD_ROLE_OWNER && role != repository.HOUSEHOLD_ROLE_MEMBER {
+		return nil, repository.NewValidationError("invalid_household_role", fmt.Errorf("unknown household role '%s'", role))
+	}
+
+	return h.householdRepository.UpdateHousehold(
+		ctx,
+		userUid,
+		householdUuid,
+		func(context context.Context, firestoreHousehold *repository.Household) (*repository.Household, error) {
+			if !firestoreHousehold.IsOwner(userUid) {
+				return nil, &repository.NoAccessToHouseholdError{UserUid: userUid, HouseholdUuid: householdUuid}
+			}
+
+			if firestoreHousehold.IsMember(memberUid) {
+				return nil, repository.NewConflictError("household_member_exists", fmt.Errorf("user '%s' is already a member of household '%s'", memberUid, householdUuid))
+			}
+
+			firestoreHousehold.Members = append(firestoreHousehold.Members, repository.HouseholdMember{UserUid: memberUid, Role: role})
+
+			return firestoreHousehold, nil
+		},
+	)
+}
+
+func (h HouseholdHandle) RemoveMember(ctx context.Context, userUid string, householdUuid string, memberUid string) ([]*repository.Household, error) {
+	return h.householdRepository.UpdateHousehold(
+		ctx,
+		userUid,
+		householdUuid,
+		func(context context.Context, firestoreHousehold *repository.Household) (*repository.Household, error) {
+			// members are allowed to leave a household on their own, everything else is up to the owners
+			if memberUid != userUid && !firestoreHousehold.IsOwner(userUid) {
+				return nil, &repository.NoAccessToHouseholdError{UserUid: userUid, HouseholdUuid: householdUuid}
+			}
+
+			remainingOwners := 0
+			remainingMembers := []repository.HouseholdMember{}
+			for _, member := range firestoreHousehold.Members {
+				if member.UserUid == memberUid {
+					continue
+				}
+				if member.Role == repository.HOUSEHOLD_ROLE_OWNER {
+					remainingOwners++
+				}
+				remainingMembers = append(remainingMembers, member)
+			}
+
+			if len(remainingMembers) == len(firestoreHousehold.Members) {
+				return nil, repository.NewNotFoundError("household_member_not_found", fmt.Errorf("user '%s' is not a member of household '%s'", memberUid, householdUuid))
+			}
+			if remainingOwners == 0 {
+				return nil, repository.NewConflictError("household_owner_required", fmt.Errorf("household '%s' needs at least one owner", householdUuid))
+			}
+
+			firestoreHousehold.Members = remainingMembers
+
+			return firestoreHousehold, nil
+		},
+	)
+}
+
+func (h HouseholdHandle) AddPet(ctx context.Context, userUid string, householdUuid string, petUuid string) ([]*repository.Pet, error) {
+	_, err := h.householdRepository.GetHousehold(ctx, userUid, householdUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedPet *repository.Pet
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.PetUpdated{Header: events.NewHeader(userUid, petUuid), Pet: updatedPet}}
+	})
+
+	return h.petRepository.UpdatePet(
+		ctx,
+		userUid,
+		petUuid,
+		func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
+			if firestorePet.UserUID != userUid {
+				return nil, &repository.NoAccessToPetError{UserUid: userUid, PetUuid: petUuid}
+			}
+
+			firestorePet.HouseholdUUID = householdUuid
+			updatedPet = firestorePet
+
+			return firestorePet, nil
+		},
+	)
+}
+
+func (h HouseholdHandle) RemovePet(ctx context.Context, userUid string, householdUuid string, petUuid string) ([]*repository.Pet, error) {
+	household, err := h.householdRepository.GetHousehold(ctx, userUid, householdUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedPet *repository.Pet
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.PetUpdated{Header: events.NewHeader(userUid, petUuid), Pet: updatedPet}}
+	})
+
+	return h.petRepository.UpdatePet(
+		ctx,
+		userUid,
+		petUuid,
+		func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
+			if firestorePet.HouseholdUUID != householdUuid {
+				return nil, repository.NewNotFoundError("household_pet_not_found", fmt.Errorf("pet '%s' does not belong to household '%s'", petUuid, householdUuid))
+			}
+			if firestorePet.UserUID != userUid && !household.IsOwner(userUid) {
+				return nil, &repository.NoAccessToPetError{UserUid: userUid, PetUuid: petUuid}
+			}
+
+			firestorePet.HouseholdUUID = ""
+			updatedPet = firestorePet
+
+			return firestorePet, nil
+		},
+	)
+}
@@ -2,97 +2,124 @@ package handler
 
 import (
 	"context"
-	"reflect"
+	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/cafo13/fur-meds/api/events"
 	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/validation"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
+// MedicineHandler manages the medicines of a pet, the pet is loaded and the access of the user is checked before
 type MedicineHandler interface {
-	Create(ctx context.Context, userUid string, petUuid string, medicine *repository.Medicine) ([]*repository.Medicine, error)
-	Get(ctx context.Context, userUid string, medicineUuid string) (*repository.Medicine, error)
-	Update(ctx context.Context, userUid string, medicineUuid string, medicine *repository.Medicine) ([]*repository.Medicine, error)
-	Delete(ctx context.Context, userUid string, medicineUuid string) ([]*repository.Medicine, error)
-	GetAllForPet(ctx context.Context, userUid string, petUuid string) ([]*repository.Medicine, error)
+	Create(ctx context.Context, userUid string, pet *repository.Pet, medicine *repository.Medicine) ([]*repository.Medicine, error)
+	Get(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string) (*repository.Medicine, error)
+	Update(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, medicine *repository.Medicine) ([]*repository.Medicine, error)
+	Patch(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, patch map[string]json.RawMessage) ([]*repository.Medicine, error)
+	Delete(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string) ([]*repository.Medicine, error)
+	GetAllForPet(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Medicine, error)
+	GetRevisions(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string) ([]*repository.MedicineRevision, error)
+	GetAt(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, at time.Time) (*repository.Medicine, error)
+	DiffRevisions(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, fromRevisionUuid string, toRevisionUuid string) (*repository.MedicineRevisionDiff, error)
 }
 
 type MedicineHandle struct {
 	medicineRepository repository.MedicineRepository
-	petRepository      repository.PetRepository
 }
 
-func NewMedicineHandler(medicineRepository repository.MedicineRepository, petRepository repository.PetRepository) MedicineHandler {
-	return MedicineHandle{medicineRepository, petRepository}
+func NewMedicineHandler(medicineRepository repository.MedicineRepository) MedicineHandler {
+	return MedicineHandle{medicineRepository}
 }
 
-func (h MedicineHandle) Create(ctx context.Context, userUid string, petUuid string, medicine *repository.Medicine) ([]*repository.Medicine, error) {
-	medicines, err := h.medicineRepository.AddMedicine(ctx, userUid, petUuid, medicine)
+func (h MedicineHandle) Create(ctx context.Context, userUid string, pet *repository.Pet, medicine *repository.Medicine) ([]*repository.Medicine, error) {
+	err := checkPetWritable(pet)
 	if err != nil {
 		return nil, err
 	}
 
-	return medicines, nil
-}
+	// only the mutations of offline clients choose the UUID of a new medicine, see SyncHandle.ApplyMutations
+	medicine.UUID = uuid.Nil
 
-func (h MedicineHandle) Get(ctx context.Context, userUid string, medicineUuid string) (*repository.Medicine, error) {
-	medicine, err := h.medicineRepository.GetMedicine(ctx, userUid, medicineUuid)
+	err = validation.Validate(medicine)
 	if err != nil {
 		return nil, err
 	}
 
-	hasAccess, err := h.petRepository.UserHasAccessToPet(ctx, userUid, medicine.PetUUID.String())
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.MedicineCreated{Header: events.NewHeader(userUid, pet.UUID.String()), Medicine: medicine}}
+	})
+
+	medicines, err := h.medicineRepository.AddMedicine(ctx, userUid, pet.UUID.String(), medicine)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, &repository.NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: medicine.PetUUID.String(),
-		}
-	}
+	return medicines, nil
+}
 
-	return medicine, nil
+func (h MedicineHandle) Get(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string) (*repository.Medicine, error) {
+	return h.medicineRepository.GetMedicine(ctx, userUid, pet.UUID.String(), medicineUuid)
 }
 
-func (h MedicineHandle) Update(ctx context.Context, userUid string, medicineUuid string, medicine *repository.Medicine) ([]*repository.Medicine, error) {
-	petUuid := medicine.PetUUID.String()
-	medicine, err := h.medicineRepository.GetMedicine(ctx, userUid, petUuid)
+// Update replaces all fields of the medicine which can be changed by the fields of the given medicine
+func (h MedicineHandle) Update(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, medicine *repository.Medicine) ([]*repository.Medicine, error) {
+	err := validation.Validate(medicine)
 	if err != nil {
 		return nil, err
 	}
 
-	hasAccess, err := h.petRepository.UserHasAccessToPet(ctx, userUid, petUuid)
+	return h.update(ctx, userUid, pet, medicineUuid, func(firestoreMedicine *repository.Medicine) error {
+		firestoreMedicine.Name = medicine.Name
+		firestoreMedicine.Dosage = medicine.Dosage
+		firestoreMedicine.Unit = medicine.Unit
+		firestoreMedicine.Stock = medicine.Stock
+		firestoreMedicine.Frequencies = medicine.Frequencies
+		firestoreMedicine.Escalation = medicine.Escalation
+
+		return nil
+	})
+}
+
+// Patch applies a JSON merge patch to the medicine
+func (h MedicineHandle) Patch(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, patch map[string]json.RawMessage) ([]*repository.Medicine, error) {
+	return h.update(ctx, userUid, pet, medicineUuid, func(firestoreMedicine *repository.Medicine) error {
+		err := applyMergePatch(firestoreMedicine, mutableFields[repository.MUTATION_KIND_MEDICINE], patch)
+		if err != nil {
+			return err
+		}
+
+		return validation.Validate(firestoreMedicine)
+	})
+}
+
+func (h MedicineHandle) update(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, changeFn func(firestoreMedicine *repository.Medicine) error) ([]*repository.Medicine, error) {
+	err := checkPetWritable(pet)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, &repository.NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: petUuid,
-		}
-	}
+	var daysLeftBefore, daysLeft float64
+	var updatedMedicine *repository.Medicine
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return medicineUpdatedEvents(userUid, updatedMedicine, daysLeftBefore, daysLeft)
+	})
 
 	medicines, err := h.medicineRepository.UpdateMedicine(
 		ctx,
 		userUid,
+		pet.UUID.String(),
 		medicineUuid,
 		func(context context.Context, firestoreMedicine *repository.Medicine) (*repository.Medicine, error) {
-			if medicine.Name != "" && medicine.Name != firestoreMedicine.Name {
-				firestoreMedicine.Name = medicine.Name
-			}
-			if medicine.Dosage != 0 && medicine.Dosage != firestoreMedicine.Dosage {
-				firestoreMedicine.Dosage = medicine.Dosage
-			}
-			if medicine.Unit != "" && medicine.Unit != firestoreMedicine.Unit {
-				firestoreMedicine.Unit = medicine.Unit
-			}
-			if medicine.Stock != 0 && medicine.Stock != firestoreMedicine.Stock {
-				firestoreMedicine.Stock = medicine.Stock
-			}
-			if len(medicine.Frequencies) != 0 && !reflect.DeepEqual(medicine.Frequencies, firestoreMedicine.Frequencies) {
-				firestoreMedicine.Frequencies = medicine.Frequencies
+			daysLeftBefore = stockDaysLeft(firestoreMedicine.Stock, firestoreMedicine.DailyConsumption())
+			err := changeFn(firestoreMedicine)
+			if err != nil {
+				return nil, err
 			}
+			daysLeft = stockDaysLeft(firestoreMedicine.Stock, firestoreMedicine.DailyConsumption())
+			updatedMedicine = firestoreMedicine
 
 			return firestoreMedicine, nil
 		},
@@ -104,27 +131,80 @@ func (h MedicineHandle) Update(ctx context.Context, userUid string, medicineUuid
 	return medicines, nil
 }
 
-func (h MedicineHandle) Delete(ctx context.Context, userUid string, medicineUuid string) ([]*repository.Medicine, error) {
-	medicine, err := h.medicineRepository.GetMedicine(ctx, userUid, medicineUuid)
+func (h MedicineHandle) Delete(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string) ([]*repository.Medicine, error) {
+	err := checkPetWritable(pet)
 	if err != nil {
 		return nil, err
 	}
 
-	hasAccess, err := h.petRepository.UserHasAccessToPet(ctx, userUid, medicine.PetUUID.String())
+	medicine, err := h.Get(ctx, userUid, pet, medicineUuid)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, &repository.NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: medicine.PetUUID.String(),
-		}
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.MedicineDeleted{Header: events.NewHeader(userUid, pet.UUID.String()), Medicine: medicine}}
+	})
+
+	return h.medicineRepository.DeleteMedicine(ctx, userUid, pet.UUID.String(), medicineUuid)
+}
+
+func (h MedicineHandle) GetAllForPet(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Medicine, error) {
+	return h.medicineRepository.GetMedicines(ctx, userUid, pet.UUID.String())
+}
+
+// GetRevisions lists every change of the medicine, oldest first. Revisions are kept after the medicine was deleted.
+func (h MedicineHandle) GetRevisions(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string) ([]*repository.MedicineRevision, error) {
+	return h.medicineRepository.GetMedicineRevisions(ctx, userUid, pet.UUID.String(), medicineUuid)
+}
+
+// GetAt reconstructs the medicine as it was at the given time, e.g. to tell the dose of a past prescription. A
+// medicine which didn't exist at that time is reported as not found.
+func (h MedicineHandle) GetAt(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, at time.Time) (*repository.Medicine, error) {
+	revision, err := h.medicineRepository.GetMedicineRevisionAt(ctx, userUid, pet.UUID.String(), medicineUuid, at)
+	if err != nil {
+		return nil, err
+	}
+	if revision.Medicine == nil {
+		return nil, repository.NewNotFoundError("medicine_not_found", fmt.Errorf("medicine '%s' was deleted at %s", medicineUuid, at.Format(time.RFC3339)))
 	}
 
-	return h.medicineRepository.DeleteMedicine(ctx, userUid, medicineUuid)
+	return revision.Medicine, nil
 }
 
-func (h MedicineHandle) GetAllForPet(ctx context.Context, userUid string, petUuid string) ([]*repository.Medicine, error) {
-	return h.medicineRepository.GetMedicines(ctx, userUid, petUuid)
+// DiffRevisions compares two revisions of the medicine field by field
+func (h MedicineHandle) DiffRevisions(ctx context.Context, userUid string, pet *repository.Pet, medicineUuid string, fromRevisionUuid string, toRevisionUuid string) (*repository.MedicineRevisionDiff, error) {
+	fromRevision, err := h.medicineRepository.GetMedicineRevision(ctx, userUid, pet.UUID.String(), medicineUuid, fromRevisionUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	toRevision, err := h.medicineRepository.GetMedicineRevision(ctx, userUid, pet.UUID.String(), medicineUuid, toRevisionUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := repository.DocumentChanges(fromRevision.Medicine, toRevision.Medicine)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare revisions of medicine '%s'", medicineUuid)
+	}
+
+	return &repository.MedicineRevisionDiff{From: fromRevision, To: toRevision, Changes: changes}, nil
+}
+
+// medicineUpdatedEvents are the events of an updated medicine, the stock is low if it runs out within lowStockDays after the update
+func medicineUpdatedEvents(userUid string, updatedMedicine *repository.Medicine, daysLeftBefore float64, daysLeft float64) []events.Event {
+	medicineEvents := []events.Event{events.MedicineUpdated{Header: events.NewHeader(userUid, updatedMedicine.PetUUID.String()), Medicine: updatedMedicine}}
+	if isStockRunningLow(daysLeftBefore, daysLeft) {
+		medicineEvents = append(medicineEvents, events.StockLow{
+			Header:       events.NewHeader(userUid, updatedMedicine.PetUUID.String()),
+			ResourceUuid: updatedMedicine.UUID.String(),
+			ResourceName: updatedMedicine.Name,
+			Stock:        updatedMedicine.Stock,
+			Unit:         string(updatedMedicine.Unit),
+			DaysLeft:     daysLeft,
+		})
+	}
+
+	return medicineEvents
 }
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+// applyMergePatch applies a JSON merge patch (RFC 7396) to the document, which has to be a pointer to a struct. Only
+// the allowed fields may be patched. A field set to null is reset to its zero value, objects are merged recursively
+// and all other values replace the value of the field.
+func applyMergePatch(document interface{}, allowedFields map[string]bool, patch map[string]json.RawMessage) error {
+	documentJSON, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	serverValues := map[string]json.RawMessage{}
+	err = json.Unmarshal(documentJSON, &serverValues)
+	if err != nil {
+		return err
+	}
+
+	fields := []string{}
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if !allowedFields[field] {
+			return repository.NewValidationError("immutable_field", fmt.Errorf("field '%s' can't be changed", field))
+		}
+
+		mergedValue, err := mergePatchValue(serverValues[field], patch[field])
+		if err != nil {
+			return err
+		}
+
+		resetField(document, field)
+		if mergedValue == nil {
+			continue
+		}
+
+		fieldJSON, err := json.Marshal(map[string]json.RawMessage{field: mergedValue})
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(fieldJSON, document)
+		if err != nil {
+			return repository.NewValidationError("invalid_field", errors.Wrapf(err, "invalid value of field '%s'", field))
+		}
+	}
+
+	return nil
+}
+
+// mergePatchValue merges the patch into the target value, nil is returned if the patch removes the value
+func mergePatchValue(target json.RawMessage, patch json.RawMessage) (json.RawMessage, error) {
+	if bytes.Equal(bytes.TrimSpace(patch), []byte("null")) {
+		return nil, nil
+	}
+
+	patchObject := map[string]json.RawMessage{}
+	if json.Unmarshal(patch, &patchObject) != nil {
+		return patch, nil
+	}
+
+	targetObject := map[string]json.RawMessage{}
+	if json.Unmarshal(target, &targetObject) != nil || targetObject == nil {
+		targetObject = map[string]json.RawMessage{}
+	}
+
+	for key, value := range patchObject {
+		mergedValue, err := mergePatchValue(targetObject[key], value)
+		if err != nil {
+			return nil, err
+		}
+
+		if mergedValue == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergedValue
+		}
+	}
+
+	return json.Marshal(targetObject)
+}
+
+// resetField sets the field of the document with the given JSON name to its zero value
+func resetField(document interface{}, name string) {
+	value := reflect.ValueOf(document).Elem()
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == name {
+			value.Field(index).Set(reflect.Zero(field.Type))
+		}
+	}
+}
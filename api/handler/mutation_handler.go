@@ -0,0 +1,680 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/validation"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mutationInternalErrorDetail replaces the message of server errors in the result of a mutation
+const mutationInternalErrorDetail = "The mutation could not be applied because of an error on the server."
+
+// maxMutationsPerBatch limits the size of a batch, a client with more queued changes uploads them in several batches
+const maxMutationsPerBatch = 500
+
+// mutableFields are the fields of each kind of document a mutation may change, by their JSON names
+var mutableFields = map[repository.MutationKind]map[string]bool{
+	repository.MUTATION_KIND_PET:      {"name": true, "species": true, "image": true, "vetContacts": true, "emergencyNotes": true},
+	repository.MUTATION_KIND_MEDICINE: {"name": true, "dosage": true, "unit": true, "stock": true, "frequencies": true, "escalation": true},
+	repository.MUTATION_KIND_FOOD:     {"name": true, "dosage": true, "unit": true, "stock": true, "frequencies": true},
+	repository.MUTATION_KIND_TODO:     {"status": true},
+}
+
+// mutationConflictError aborts the transaction of a mutation which is rejected because of conflicting fields
+type mutationConflictError struct {
+	conflicts      []repository.FieldConflict
+	serverDocument interface{}
+}
+
+func (e *mutationConflictError) Error() string {
+	return fmt.Sprintf("%d fields of the mutation were changed on the server since its base version", len(e.conflicts))
+}
+
+// ApplyMutations applies the mutations of the batch in their order and reports the result of each of them. A mutation
+// which was applied before is not applied again, its stored result is returned instead, so a batch can be retried.
+func (h SyncHandle) ApplyMutations(ctx context.Context, userUid string, batch *repository.MutationBatch) ([]*repository.MutationResult, error) {
+	if batch.ConflictStrategy == "" {
+		batch.ConflictStrategy = repository.CONFLICT_STRATEGY_REJECT
+	}
+	if batch.ConflictStrategy != repository.CONFLICT_STRATEGY_REJECT && batch.ConflictStrategy != repository.CONFLICT_STRATEGY_LAST_WRITER_WINS {
+		return nil, repository.NewValidationError("invalid_mutation_batch", fmt.Errorf("unknown conflict strategy '%s'", batch.ConflictStrategy))
+	}
+	if len(batch.Mutations) > maxMutationsPerBatch {
+		return nil, repository.NewValidationError("invalid_mutation_batch", fmt.Errorf("a batch may not have more than %d mutations", maxMutationsPerBatch))
+	}
+
+	seenMutations := map[string]bool{}
+	for _, mutation := range batch.Mutations {
+		if mutation.ID == "" {
+			return nil, repository.NewValidationError("invalid_mutation_batch", errors.New("every mutation needs an ID"))
+		}
+		if seenMutations[mutation.ID] {
+			return nil, repository.NewValidationError("invalid_mutation_batch", fmt.Errorf("mutation ID '%s' is used more than once in the batch", mutation.ID))
+		}
+		seenMutations[mutation.ID] = true
+	}
+
+	results := []*repository.MutationResult{}
+	for _, mutation := range batch.Mutations {
+		result, err := h.applyMutation(ctx, userUid, batch.ConflictStrategy, mutation)
+		if err != nil {
+			result = &repository.MutationResult{
+				MutationID:   mutation.ID,
+				Status:       repository.MUTATION_STATUS_FAILED,
+				Kind:         mutation.Kind,
+				DocumentUUID: mutation.DocumentUUID,
+			}
+			result.ErrorCode, result.Error = mutationError(mutation, err)
+
+			var conflictError *mutationConflictError
+			if errors.As(err, &conflictError) {
+				result.Status = repository.MUTATION_STATUS_CONFLICT
+				result.Conflicts = conflictError.conflicts
+				result.ServerDocument = conflictError.serverDocument
+				result.ErrorCode = ""
+				result.Error = ""
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// mutationError tells the client the code and detail of a domain error, other errors may tell internals of the
+// server and are only logged
+func mutationError(mutation *repository.Mutation, err error) (string, string) {
+	var domainError repository.DomainError
+	if errors.As(err, &domainError) {
+		return domainError.Code(), domainError.Error()
+	}
+
+	log.Error(errors.Wrapf(err, "failed to apply mutation '%s'", mutation.ID))
+
+	return "internal_error", mutationInternalErrorDetail
+}
+
+func (h SyncHandle) applyMutation(ctx context.Context, userUid string, strategy repository.ConflictStrategy, mutation *repository.Mutation) (*repository.MutationResult, error) {
+	// a retried mutation is mostly answered from the log right away, a concurrent retry is caught by the
+	// transaction of the change which creates the log entry
+	appliedResult, err := h.syncRepository.GetMutationResult(ctx, userUid, mutation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if appliedResult != nil {
+		return appliedResult, nil
+	}
+
+	if mutation.DocumentUUID == uuid.Nil && mutation.Kind != repository.MUTATION_KIND_DOSE {
+		return nil, repository.NewValidationError("invalid_mutation", errors.New("document UUID of mutation is missing"))
+	}
+	if mutation.PetUUID == uuid.Nil && mutation.Kind != repository.MUTATION_KIND_PET {
+		// medicines, foods and todos are only found together with their pet
+		return nil, repository.NewValidationError("invalid_mutation", errors.New("pet UUID of mutation is missing"))
+	}
+	if mutation.MutatedAt.After(time.Now()) {
+		// a client clock running ahead must not win against all later writers
+		mutation.MutatedAt = time.Now()
+	}
+
+	result := &repository.MutationResult{
+		MutationID:   mutation.ID,
+		Status:       repository.MUTATION_STATUS_APPLIED,
+		Kind:         mutation.Kind,
+		DocumentUUID: mutation.DocumentUUID,
+	}
+
+	switch mutation.Kind {
+	case repository.MUTATION_KIND_PET:
+		err = h.applyPetMutation(ctx, userUid, strategy, mutation, result)
+	case repository.MUTATION_KIND_MEDICINE:
+		err = h.applyMedicineMutation(ctx, userUid, strategy, mutation, result)
+	case repository.MUTATION_KIND_FOOD:
+		err = h.applyFoodMutation(ctx, userUid, strategy, mutation, result)
+	case repository.MUTATION_KIND_TODO:
+		err = h.applyToDoMutation(ctx, userUid, strategy, mutation, result)
+	case repository.MUTATION_KIND_DOSE:
+		err = h.applyDoseMutation(ctx, userUid, mutation, result)
+	default:
+		err = repository.NewValidationError("invalid_mutation", fmt.Errorf("unknown mutation kind '%s'", mutation.Kind))
+	}
+	if status.Code(errors.Cause(err)) == codes.AlreadyExists {
+		// the change was rolled back because the mutation was applied concurrently, its log entry exists now
+		appliedResult, getErr := h.syncRepository.GetMutationResult(ctx, userUid, mutation.ID)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if appliedResult != nil {
+			return appliedResult, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (h SyncHandle) applyPetMutation(ctx context.Context, userUid string, strategy repository.ConflictStrategy, mutation *repository.Mutation, result *repository.MutationResult) error {
+	petUuid := mutation.DocumentUUID.String()
+
+	switch mutation.Operation {
+	case repository.MUTATION_OPERATION_CREATE:
+		pet := &repository.Pet{UUID: mutation.DocumentUUID}
+		_, err := mergeFields(pet, nil, mutation, strategy)
+		if err != nil {
+			return err
+		}
+		err = validation.Validate(pet)
+		if err != nil {
+			return err
+		}
+
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.PetCreated{Header: events.NewHeader(userUid, petUuid), Pet: pet}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return pet.Version })
+
+		_, err = h.petRepository.AddPet(ctx, userUid, pet)
+		return err
+	case repository.MUTATION_OPERATION_UPDATE:
+		var updatedPet *repository.Pet
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.PetUpdated{Header: events.NewHeader(userUid, petUuid), Pet: updatedPet}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return updatedPet.Version })
+
+		_, err := h.petRepository.UpdatePet(
+			ctx,
+			userUid,
+			petUuid,
+			func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
+				err := checkPetWritable(firestorePet)
+				if err != nil {
+					return nil, err
+				}
+
+				conflicts, err := mergeFields(firestorePet, firestorePet.FieldChanges, mutation, strategy)
+				if err != nil {
+					return nil, err
+				}
+				err = validation.Validate(firestorePet)
+				if err != nil {
+					return nil, err
+				}
+				setMergeResult(result, conflicts)
+				updatedPet = firestorePet
+
+				return firestorePet, nil
+			},
+		)
+		return err
+	case repository.MUTATION_OPERATION_DELETE:
+		pet, err := h.petRepository.GetPet(ctx, userUid, petUuid)
+		if isNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = checkDeleteConflict(pet, pet.Version, pet.UpdatedAt, mutation, strategy)
+		if err != nil {
+			return err
+		}
+
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.PetDeleted{Header: events.NewHeader(userUid, petUuid), Pet: pet}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return 0 })
+
+		_, err = h.petRepository.DeletePet(ctx, userUid, petUuid)
+		return err
+	default:
+		return repository.NewValidationError("invalid_mutation", fmt.Errorf("unknown mutation operation '%s'", mutation.Operation))
+	}
+}
+
+func (h SyncHandle) applyMedicineMutation(ctx context.Context, userUid string, strategy repository.ConflictStrategy, mutation *repository.Mutation, result *repository.MutationResult) error {
+	medicineUuid := mutation.DocumentUUID.String()
+	petUuid := mutation.PetUUID.String()
+
+	err := h.checkPetWritable(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	if mutation.Operation == repository.MUTATION_OPERATION_CREATE {
+		medicine := &repository.Medicine{UUID: mutation.DocumentUUID}
+		_, err = mergeFields(medicine, nil, mutation, strategy)
+		if err != nil {
+			return err
+		}
+		err = validation.Validate(medicine)
+		if err != nil {
+			return err
+		}
+
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.MedicineCreated{Header: events.NewHeader(userUid, mutation.PetUUID.String()), Medicine: medicine}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return medicine.Version })
+
+		_, err = h.medicineRepository.AddMedicine(ctx, userUid, petUuid, medicine)
+		return err
+	}
+
+	medicine, err := h.medicineRepository.GetMedicine(ctx, userUid, petUuid, medicineUuid)
+	if isNotFound(err) && mutation.Operation == repository.MUTATION_OPERATION_DELETE {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch mutation.Operation {
+	case repository.MUTATION_OPERATION_UPDATE:
+		var daysLeftBefore, daysLeft float64
+		var updatedMedicine *repository.Medicine
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return medicineUpdatedEvents(userUid, updatedMedicine, daysLeftBefore, daysLeft)
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return updatedMedicine.Version })
+
+		_, err = h.medicineRepository.UpdateMedicine(
+			ctx,
+			userUid,
+			petUuid,
+			medicineUuid,
+			func(context context.Context, firestoreMedicine *repository.Medicine) (*repository.Medicine, error) {
+				daysLeftBefore = stockDaysLeft(firestoreMedicine.Stock, firestoreMedicine.DailyConsumption())
+				conflicts, err := mergeFields(firestoreMedicine, firestoreMedicine.FieldChanges, mutation, strategy)
+				if err != nil {
+					return nil, err
+				}
+				err = validation.Validate(firestoreMedicine)
+				if err != nil {
+					return nil, err
+				}
+				setMergeResult(result, conflicts)
+				daysLeft = stockDaysLeft(firestoreMedicine.Stock, firestoreMedicine.DailyConsumption())
+				updatedMedicine = firestoreMedicine
+
+				return firestoreMedicine, nil
+			},
+		)
+		return err
+	case repository.MUTATION_OPERATION_DELETE:
+		err = checkDeleteConflict(medicine, medicine.Version, medicine.UpdatedAt, mutation, strategy)
+		if err != nil {
+			return err
+		}
+
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.MedicineDeleted{Header: events.NewHeader(userUid, medicine.PetUUID.String()), Medicine: medicine}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return 0 })
+
+		_, err = h.medicineRepository.DeleteMedicine(ctx, userUid, petUuid, medicineUuid)
+		return err
+	default:
+		return repository.NewValidationError("invalid_mutation", fmt.Errorf("unknown mutation operation '%s'", mutation.Operation))
+	}
+}
+
+func (h SyncHandle) applyFoodMutation(ctx context.Context, userUid string, strategy repository.ConflictStrategy, mutation *repository.Mutation, result *repository.MutationResult) error {
+	foodUuid := mutation.DocumentUUID.String()
+	petUuid := mutation.PetUUID.String()
+
+	err := h.checkPetWritable(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	if mutation.Operation == repository.MUTATION_OPERATION_CREATE {
+		food := &repository.Food{UUID: mutation.DocumentUUID}
+		_, err = mergeFields(food, nil, mutation, strategy)
+		if err != nil {
+			return err
+		}
+		err = validation.Validate(food)
+		if err != nil {
+			return err
+		}
+
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.FoodCreated{Header: events.NewHeader(userUid, mutation.PetUUID.String()), Food: food}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return food.Version })
+
+		_, err = h.foodRepository.AddFood(ctx, userUid, petUuid, food)
+		return err
+	}
+
+	food, err := h.foodRepository.GetFood(ctx, userUid, petUuid, foodUuid)
+	if isNotFound(err) && mutation.Operation == repository.MUTATION_OPERATION_DELETE {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch mutation.Operation {
+	case repository.MUTATION_OPERATION_UPDATE:
+		var daysLeftBefore, daysLeft float64
+		var updatedFood *repository.Food
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return foodUpdatedEvents(userUid, updatedFood, daysLeftBefore, daysLeft)
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return updatedFood.Version })
+
+		_, err = h.foodRepository.UpdateFood(
+			ctx,
+			userUid,
+			petUuid,
+			foodUuid,
+			func(context context.Context, firestoreFood *repository.Food) (*repository.Food, error) {
+				daysLeftBefore = stockDaysLeft(firestoreFood.Stock, firestoreFood.DailyConsumption())
+				conflicts, err := mergeFields(firestoreFood, firestoreFood.FieldChanges, mutation, strategy)
+				if err != nil {
+					return nil, err
+				}
+				err = validation.Validate(firestoreFood)
+				if err != nil {
+					return nil, err
+				}
+				setMergeResult(result, conflicts)
+				daysLeft = stockDaysLeft(firestoreFood.Stock, firestoreFood.DailyConsumption())
+				updatedFood = firestoreFood
+
+				return firestoreFood, nil
+			},
+		)
+		return err
+	case repository.MUTATION_OPERATION_DELETE:
+		err = checkDeleteConflict(food, food.Version, food.UpdatedAt, mutation, strategy)
+		if err != nil {
+			return err
+		}
+
+		ctx = events.WithOutbox(ctx, func() []events.Event {
+			return []events.Event{events.FoodDeleted{Header: events.NewHeader(userUid, food.PetUUID.String()), Food: food}}
+		})
+		ctx = withMutationLog(ctx, userUid, result, func() int { return 0 })
+
+		_, err = h.foodRepository.DeleteFood(ctx, userUid, petUuid, foodUuid)
+		return err
+	default:
+		return repository.NewValidationError("invalid_mutation", fmt.Errorf("unknown mutation operation '%s'", mutation.Operation))
+	}
+}
+
+// applyToDoMutation changes the status of a todo, todos are only created by the server
+func (h SyncHandle) applyToDoMutation(ctx context.Context, userUid string, strategy repository.ConflictStrategy, mutation *repository.Mutation, result *repository.MutationResult) error {
+	if mutation.Operation != repository.MUTATION_OPERATION_UPDATE {
+		return repository.NewValidationError("invalid_mutation", fmt.Errorf("todos can only be updated, not '%s'", mutation.Operation))
+	}
+
+	petUuid := mutation.PetUUID.String()
+	err := h.checkPetWritable(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	todo, err := h.todoRepository.GetToDo(ctx, userUid, petUuid, mutation.DocumentUUID.String())
+	if err != nil {
+		return err
+	}
+
+	return h.updateToDo(ctx, userUid, petUuid, todo.UUID.String(), mutation, result, func(firestoreToDo *repository.ToDo) error {
+		conflicts, err := mergeFields(firestoreToDo, firestoreToDo.FieldChanges, mutation, strategy)
+		if err != nil {
+			return err
+		}
+		setMergeResult(result, conflicts)
+
+		return nil
+	})
+}
+
+// applyDoseMutation records a dose given while offline, the todo of the dose is created if the server didn't create it yet
+func (h SyncHandle) applyDoseMutation(ctx context.Context, userUid string, mutation *repository.Mutation, result *repository.MutationResult) error {
+	if mutation.Operation != repository.MUTATION_OPERATION_CREATE {
+		return repository.NewValidationError("invalid_mutation", fmt.Errorf("doses can only be created, not '%s'", mutation.Operation))
+	}
+
+	var medicineUuid, frequencyUuid uuid.UUID
+	var dueAt time.Time
+	for field, target := range map[string]interface{}{"medicineUuid": &medicineUuid, "frequencyUuid": &frequencyUuid, "dueAt": &dueAt} {
+		value, ok := mutation.Fields[field]
+		if !ok {
+			return repository.NewValidationError("invalid_mutation", fmt.Errorf("field '%s' of dose is missing", field))
+		}
+		err := json.Unmarshal(value, target)
+		if err != nil {
+			return repository.NewValidationError("invalid_mutation", errors.Wrapf(err, "invalid field '%s' of dose", field))
+		}
+	}
+
+	// loading the pet checks the access of the user
+	pet, err := h.petRepository.GetPet(ctx, userUid, mutation.PetUUID.String())
+	if err != nil {
+		return err
+	}
+	err = checkPetWritable(pet)
+	if err != nil {
+		return err
+	}
+	medicine, err := h.medicineRepository.GetMedicine(ctx, userUid, pet.UUID.String(), medicineUuid.String())
+	if err != nil {
+		return err
+	}
+
+	hasFrequency := false
+	for _, frequency := range medicine.Frequencies {
+		hasFrequency = hasFrequency || frequency.UUID == frequencyUuid
+	}
+	if !hasFrequency {
+		return repository.NewNotFoundError("medicine_frequency_not_found", fmt.Errorf("medicine '%s' has no frequency '%s'", medicineUuid, frequencyUuid))
+	}
+
+	doseToDo := repository.NewDoseToDo(pet, medicine, frequencyUuid, dueAt)
+	_, err = h.todoRepository.AddToDo(ctx, doseToDo)
+	if err != nil {
+		return err
+	}
+	result.DocumentUUID = doseToDo.UUID
+
+	return h.updateToDo(ctx, userUid, pet.UUID.String(), doseToDo.UUID.String(), mutation, result, func(firestoreToDo *repository.ToDo) error {
+		firestoreToDo.Status = repository.TODO_STATUS_DONE
+
+		return nil
+	})
+}
+
+// updateToDo applies the change to the todo and keeps who completed it and when in line with its status
+func (h SyncHandle) updateToDo(ctx context.Context, userUid string, petUuid string, todoUuid string, mutation *repository.Mutation, result *repository.MutationResult, changeFn func(firestoreToDo *repository.ToDo) error) error {
+	// updatedToDo is only set if the status changed, versionedToDo in any case to report its new version
+	var updatedToDo, completedToDo, versionedToDo *repository.ToDo
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return todoUpdatedEvents(userUid, updatedToDo, completedToDo)
+	})
+	ctx = withMutationLog(ctx, userUid, result, func() int { return versionedToDo.Version })
+
+	_, err := h.todoRepository.UpdateToDo(
+		ctx,
+		userUid,
+		petUuid,
+		todoUuid,
+		func(context context.Context, firestoreToDo *repository.ToDo) (*repository.ToDo, error) {
+			updatedToDo = nil
+			completedToDo = nil
+			statusBefore := firestoreToDo.Status
+			err := changeFn(firestoreToDo)
+			if err != nil {
+				return nil, err
+			}
+			if firestoreToDo.Status != repository.TODO_STATUS_OPEN && firestoreToDo.Status != repository.TODO_STATUS_DONE {
+				return nil, repository.NewValidationError("invalid_mutation", fmt.Errorf("unknown todo status '%s'", firestoreToDo.Status))
+			}
+
+			if firestoreToDo.Status != statusBefore {
+				updatedToDo = firestoreToDo
+				if firestoreToDo.Status == repository.TODO_STATUS_DONE {
+					completedAt := mutation.MutatedAt
+					if completedAt.IsZero() {
+						completedAt = time.Now()
+					}
+					firestoreToDo.CompletedBy = userUid
+					firestoreToDo.CompletedAt = &completedAt
+					completedToDo = firestoreToDo
+				} else {
+					firestoreToDo.CompletedBy = ""
+					firestoreToDo.CompletedAt = nil
+				}
+			}
+			versionedToDo = firestoreToDo
+
+			return firestoreToDo, nil
+		},
+	)
+
+	return err
+}
+
+// checkPetWritable checks that the user has access to the pet and that the pet isn't archived
+func (h SyncHandle) checkPetWritable(ctx context.Context, userUid string, petUuid string) error {
+	pet, err := h.petRepository.GetPet(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	return checkPetWritable(pet)
+}
+
+// mergeFields applies the fields of the mutation to the document. Fields which were changed on the server after
+// the base version of the mutation are conflicts: with the reject strategy the mutation is rejected as a whole,
+// with last writer wins the client value is kept if the client changed the field after the server did.
+// The returned conflicts are the fields which kept the value of the server.
+func mergeFields(document interface{}, fieldChanges map[string]repository.FieldChange, mutation *repository.Mutation, strategy repository.ConflictStrategy) ([]repository.FieldConflict, error) {
+	allowedFields := mutableFields[mutation.Kind]
+
+	documentJSON, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	serverValues := map[string]json.RawMessage{}
+	err = json.Unmarshal(documentJSON, &serverValues)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	for field := range mutation.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	conflicts := []repository.FieldConflict{}
+	appliedValues := map[string]json.RawMessage{}
+	for _, field := range fields {
+		if !allowedFields[field] {
+			return nil, repository.NewValidationError("immutable_field", fmt.Errorf("field '%s' of %s can't be changed", field, mutation.Kind))
+		}
+
+		clientValue := mutation.Fields[field]
+		change, changed := fieldChanges[field]
+		if !changed || change.Version <= mutation.BaseVersion || bytes.Equal(clientValue, serverValues[field]) {
+			appliedValues[field] = clientValue
+			continue
+		}
+
+		if strategy == repository.CONFLICT_STRATEGY_LAST_WRITER_WINS && mutation.MutatedAt.After(change.ChangedAt) {
+			appliedValues[field] = clientValue
+			continue
+		}
+
+		conflicts = append(conflicts, repository.FieldConflict{
+			Field:           field,
+			ClientValue:     clientValue,
+			ServerValue:     serverValues[field],
+			ServerChangedAt: change.ChangedAt,
+			ServerVersion:   change.Version,
+		})
+	}
+
+	if strategy == repository.CONFLICT_STRATEGY_REJECT && len(conflicts) > 0 {
+		return nil, &mutationConflictError{conflicts: conflicts, serverDocument: document}
+	}
+
+	patch, err := json.Marshal(appliedValues)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(patch, document)
+	if err != nil {
+		return nil, repository.NewValidationError("invalid_mutation", errors.Wrapf(err, "invalid fields of %s mutation", mutation.Kind))
+	}
+
+	return conflicts, nil
+}
+
+// checkDeleteConflict checks if a document changed on the server after the base version of the mutation which deletes it
+func checkDeleteConflict(document interface{}, version int, updatedAt time.Time, mutation *repository.Mutation, strategy repository.ConflictStrategy) error {
+	if version <= mutation.BaseVersion {
+		return nil
+	}
+	if strategy == repository.CONFLICT_STRATEGY_LAST_WRITER_WINS && mutation.MutatedAt.After(updatedAt) {
+		return nil
+	}
+
+	return &mutationConflictError{
+		conflicts:      []repository.FieldConflict{{ServerChangedAt: updatedAt, ServerVersion: version}},
+		serverDocument: document,
+	}
+}
+
+func setMergeResult(result *repository.MutationResult, conflicts []repository.FieldConflict) {
+	result.Status = repository.MUTATION_STATUS_APPLIED
+	result.Conflicts = nil
+	if len(conflicts) > 0 {
+		result.Status = repository.MUTATION_STATUS_MERGED
+		result.Conflicts = conflicts
+	}
+}
+
+// withMutationLog stores the result of the mutation together with its data change, versionFn returns the new version of the document
+func withMutationLog(ctx context.Context, userUid string, result *repository.MutationResult, versionFn func() int) context.Context {
+	return repository.WithMutationLog(ctx, func() *repository.MutationLogEntry {
+		result.Version = versionFn()
+		now := time.Now()
+
+		return &repository.MutationLogEntry{
+			ID:          repository.MutationLogID(userUid, result.MutationID),
+			UserUID:     userUid,
+			Result:      *result,
+			CreatedAt:   now,
+			DeleteAfter: now.Add(repository.MutationRetention),
+		}
+	})
+}
+
+func isNotFound(err error) bool {
+	var domainError repository.DomainError
+	if errors.As(err, &domainError) {
+		return domainError.Kind() == repository.ERROR_KIND_NOT_FOUND
+	}
+
+	return status.Code(errors.Cause(err)) == codes.NotFound
+}
@@ -2,34 +2,51 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"reflect"
+	"time"
 
+	"github.com/cafo13/fur-meds/api/events"
 	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/validation"
+	"github.com/google/uuid"
 )
 
 type PetHandler interface {
 	Create(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Pet, error)
-	Delete(ctx context.Context, userUid string, petUuid string) ([]*repository.Pet, error)
+	Delete(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Pet, error)
 	Get(ctx context.Context, userUid string, petUuid string) (*repository.Pet, error)
-	GetAllForUser(ctx context.Context, userUid string) ([]*repository.Pet, error)
+	GetAllForUser(ctx context.Context, userUid string, includeArchived bool) ([]*repository.Pet, error)
 	Update(ctx context.Context, userUid string, petUUID string, pet *repository.Pet) ([]*repository.Pet, error)
-	UserHasAccess(ctx context.Context, userUid string, petUuid string) (bool, error)
-	CreatePetShareInvite(ctx context.Context, userUid string, petUuid string, userUidToSharePetWith string) ([]*repository.Pet, error)
+	Patch(ctx context.Context, userUid string, petUuid string, patch map[string]json.RawMessage) ([]*repository.Pet, error)
+	Archive(ctx context.Context, userUid string, petUuid string, archive *repository.PetArchive) ([]*repository.Pet, error)
+	Unarchive(ctx context.Context, userUid string, petUuid string) ([]*repository.Pet, error)
+	CreatePetShareInvite(ctx context.Context, userUid string, petUuid string, userUidToSharePetWith string, validFrom *time.Time, validUntil *time.Time) ([]*repository.Pet, error)
 	AnswerPetShareInvite(ctx context.Context, userUid string, petUuid string, petShareInviteAnswer repository.PetShareAnswer) ([]*repository.Pet, error)
 	GetOpenSharedPets(ctx context.Context, userUid string) ([]*repository.Pet, error)
 }
 
 type PetHandle struct {
 	petRepository repository.PetRepository
-	todoChannel   chan string
 }
 
-func NewPetHandler(petRepository repository.PetRepository, todoChannel chan string) PetHandler {
-	return PetHandle{petRepository, todoChannel}
+func NewPetHandler(petRepository repository.PetRepository) PetHandler {
+	return PetHandle{petRepository}
 }
 
 func (h PetHandle) Create(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Pet, error) {
+	// only the mutations of offline clients choose the UUID of a new pet, see SyncHandle.ApplyMutations
+	pet.UUID = uuid.Nil
+
+	err := validation.Validate(pet)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.PetCreated{Header: events.NewHeader(userUid, pet.UUID.String()), Pet: pet}}
+	})
+
 	pets, err := h.petRepository.AddPet(ctx, userUid, pet)
 	if err != nil {
 		return nil, err
@@ -38,8 +55,13 @@ func (h PetHandle) Create(ctx context.Context, userUid string, pet *repository.P
 	return pets, nil
 }
 
-func (h PetHandle) Delete(ctx context.Context, userUid string, petUuid string) ([]*repository.Pet, error) {
-	pets, err := h.petRepository.DeletePet(ctx, userUid, petUuid)
+// Delete removes the pet which was already loaded for the request
+func (h PetHandle) Delete(ctx context.Context, userUid string, pet *repository.Pet) ([]*repository.Pet, error) {
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.PetDeleted{Header: events.NewHeader(userUid, pet.UUID.String()), Pet: pet}}
+	})
+
+	pets, err := h.petRepository.DeletePet(ctx, userUid, pet.UUID.String())
 	if err != nil {
 		return nil, err
 	}
@@ -56,36 +78,115 @@ func (h PetHandle) Get(ctx context.Context, userUid string, petUuid string) (*re
 	return pet, nil
 }
 
-func (h PetHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repository.Pet, error) {
+// GetAllForUser lists the pets of the user, archived pets are only included on request
+func (h PetHandle) GetAllForUser(ctx context.Context, userUid string, includeArchived bool) ([]*repository.Pet, error) {
 	pets, err := h.petRepository.GetPets(ctx, userUid)
 	if err != nil {
 		return nil, err
 	}
 
-	return pets, nil
+	if includeArchived {
+		return pets, nil
+	}
+
+	activePets := []*repository.Pet{}
+	for _, pet := range pets {
+		if !pet.IsArchived() {
+			activePets = append(activePets, pet)
+		}
+	}
+
+	return activePets, nil
 }
 
+// Update replaces all fields of the pet which can be changed by the fields of the given pet
 func (h PetHandle) Update(ctx context.Context, userUid string, petUuid string, pet *repository.Pet) ([]*repository.Pet, error) {
+	err := validation.Validate(pet)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.update(ctx, userUid, petUuid, func(firestorePet *repository.Pet) error {
+		err := checkPetWritable(firestorePet)
+		if err != nil {
+			return err
+		}
+
+		firestorePet.Name = pet.Name
+		firestorePet.Species = pet.Species
+		firestorePet.Image = pet.Image
+		firestorePet.VetContacts = pet.VetContacts
+		firestorePet.EmergencyNotes = pet.EmergencyNotes
+
+		return nil
+	})
+}
+
+// Patch applies a JSON merge patch to the pet
+func (h PetHandle) Patch(ctx context.Context, userUid string, petUuid string, patch map[string]json.RawMessage) ([]*repository.Pet, error) {
+	return h.update(ctx, userUid, petUuid, func(firestorePet *repository.Pet) error {
+		err := checkPetWritable(firestorePet)
+		if err != nil {
+			return err
+		}
+
+		err = applyMergePatch(firestorePet, mutableFields[repository.MUTATION_KIND_PET], patch)
+		if err != nil {
+			return err
+		}
+
+		return validation.Validate(firestorePet)
+	})
+}
+
+// Archive keeps the pet as a read-only record, which has no reminders or todos anymore
+func (h PetHandle) Archive(ctx context.Context, userUid string, petUuid string, archive *repository.PetArchive) ([]*repository.Pet, error) {
+	if archive.ArchivedAt.IsZero() {
+		archive.ArchivedAt = time.Now()
+	}
+	err := validation.Validate(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.update(ctx, userUid, petUuid, func(firestorePet *repository.Pet) error {
+		err := checkPetWritable(firestorePet)
+		if err != nil {
+			return err
+		}
+
+		firestorePet.Archive = archive
+
+		return nil
+	})
+}
+
+// Unarchive makes the pet an active pet again
+func (h PetHandle) Unarchive(ctx context.Context, userUid string, petUuid string) ([]*repository.Pet, error) {
+	return h.update(ctx, userUid, petUuid, func(firestorePet *repository.Pet) error {
+		firestorePet.Archive = nil
+
+		return nil
+	})
+}
+
+func (h PetHandle) update(ctx context.Context, userUid string, petUuid string, changeFn func(firestorePet *repository.Pet) error) ([]*repository.Pet, error) {
+	// the pet is captured by reference, the repository stamps its version before the event is built
+	var updatedPet *repository.Pet
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.PetUpdated{Header: events.NewHeader(userUid, petUuid), Pet: updatedPet}}
+	})
+
 	pets, err := h.petRepository.UpdatePet(
 		ctx,
 		userUid,
 		petUuid,
 		func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
-			if pet.Name != "" && pet.Name != firestorePet.Name {
-				firestorePet.Name = pet.Name
-			}
-			if pet.Species != "" && pet.Species != firestorePet.Species {
-				firestorePet.Species = pet.Species
-			}
-			if pet.Image != "" && pet.Image != firestorePet.Image {
-				firestorePet.Image = pet.Image
-			}
-			if len(pet.Medicines) != 0 && !reflect.DeepEqual(pet.Medicines, firestorePet.Medicines) {
-				firestorePet.Medicines = pet.Medicines
-			}
-			if len(pet.Foods) != 0 && !reflect.DeepEqual(pet.Foods, firestorePet.Foods) {
-				firestorePet.Foods = pet.Foods
+			err := changeFn(firestorePet)
+			if err != nil {
+				return nil, err
 			}
+			updatedPet = firestorePet
 
 			return firestorePet, nil
 		},
@@ -97,39 +198,77 @@ func (h PetHandle) Update(ctx context.Context, userUid string, petUuid string, p
 	return pets, nil
 }
 
-func (h PetHandle) UserHasAccess(ctx context.Context, userUid string, petUuid string) (bool, error) {
-	return h.petRepository.UserHasAccessToPet(ctx, userUid, petUuid)
-}
+func (h PetHandle) CreatePetShareInvite(ctx context.Context, userUid string, petUuid string, userUidToSharePetWith string, validFrom *time.Time, validUntil *time.Time) ([]*repository.Pet, error) {
+	if validFrom != nil && validUntil != nil && !validUntil.After(*validFrom) {
+		return nil, repository.NewValidationError("invalid_pet_share", fmt.Errorf("end of pet share '%s' has to be after its start '%s'", validUntil, validFrom))
+	}
+	if validUntil != nil && !validUntil.After(time.Now()) {
+		return nil, repository.NewValidationError("invalid_pet_share", fmt.Errorf("end of pet share '%s' is in the past", validUntil))
+	}
+
+	var sharedPet *repository.Pet
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{events.ShareInvited{Header: events.NewHeader(userUid, petUuid), Pet: sharedPet, InviteeUid: userUidToSharePetWith}}
+	})
 
-func (h PetHandle) CreatePetShareInvite(ctx context.Context, userUid string, petUuid string, userUidToSharePetWith string) ([]*repository.Pet, error) {
-	return h.petRepository.UpdatePet(
+	pets, err := h.petRepository.UpdatePet(
 		ctx,
 		userUid,
 		petUuid,
 		func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
+			err := checkPetWritable(firestorePet)
+			if err != nil {
+				return nil, err
+			}
+
 			if firestorePet.SharedWithUsers == nil {
 				firestorePet.SharedWithUsers = []repository.PetShares{}
 			}
 			for _, sharedUser := range firestorePet.SharedWithUsers {
 				if sharedUser.UserUid == userUidToSharePetWith {
-					return nil, fmt.Errorf("user '%s' is already invited to accept share for pet '%s'", userUid, petUuid)
+					return nil, repository.NewConflictError("pet_share_already_invited", fmt.Errorf("user '%s' is already invited to accept share for pet '%s'", userUid, petUuid))
 				}
 			}
 
-			firestorePet.SharedWithUsers = append(firestorePet.SharedWithUsers, repository.PetShares{UserUid: userUidToSharePetWith, ShareAccepted: false})
+			invitedAt := time.Now()
+			firestorePet.SharedWithUsers = append(firestorePet.SharedWithUsers, repository.PetShares{
+				UserUid:       userUidToSharePetWith,
+				ShareAccepted: false,
+				ValidFrom:     validFrom,
+				ValidUntil:    validUntil,
+				InvitedAt:     &invitedAt,
+			})
+
+			sharedPet = firestorePet
 
 			return firestorePet, nil
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pets, nil
 }
 
 func (h PetHandle) AnswerPetShareInvite(ctx context.Context, userUid string, petUuid string, petShareInviteAnswer repository.PetShareAnswer) ([]*repository.Pet, error) {
-	return h.petRepository.UpdatePet(
+	var acceptedShare *repository.PetShares
+	var sharedPet *repository.Pet
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		if acceptedShare == nil {
+			return nil
+		}
+
+		return []events.Event{events.ShareAccepted{Header: events.NewHeader(userUid, petUuid), Pet: sharedPet, Share: *acceptedShare}}
+	})
+
+	return h.petRepository.UpdateInvitedPet(
 		ctx,
 		userUid,
 		petUuid,
 		func(context context.Context, firestorePet *repository.Pet) (*repository.Pet, error) {
-			noInviteFoundError := fmt.Errorf("no open invite exists for user '%s' at pet '%s'", userUid, petUuid)
+			acceptedShare = nil
+			noInviteFoundError := repository.NewNotFoundError("pet_share_invite_not_found", fmt.Errorf("no open invite exists for user '%s' at pet '%s'", userUid, petUuid))
 			if firestorePet.SharedWithUsers == nil {
 				return nil, noInviteFoundError
 			}
@@ -138,6 +277,8 @@ func (h PetHandle) AnswerPetShareInvite(ctx context.Context, userUid string, pet
 				if sharedUser.UserUid == userUid {
 					if petShareInviteAnswer == repository.PET_SHARE_ANSWER_ACCEPT {
 						firestorePet.SharedWithUsers[index].ShareAccepted = true
+						acceptedShare = &firestorePet.SharedWithUsers[index]
+						sharedPet = firestorePet
 					}
 					if petShareInviteAnswer == repository.PET_SHARE_ANSWER_DENY {
 						firestorePet.SharedWithUsers = append(firestorePet.SharedWithUsers[:index], firestorePet.SharedWithUsers[index+1:]...)
@@ -155,3 +296,12 @@ func (h PetHandle) AnswerPetShareInvite(ctx context.Context, userUid string, pet
 func (h PetHandle) GetOpenSharedPets(ctx context.Context, userUid string) ([]*repository.Pet, error) {
 	return h.petRepository.GetOpenSharedPets(ctx, userUid)
 }
+
+// checkPetWritable rejects changes of an archived pet and its medicines, foods and todos
+func checkPetWritable(pet *repository.Pet) error {
+	if pet.IsArchived() {
+		return repository.NewConflictError("pet_archived", fmt.Errorf("pet '%s' is archived and can't be changed", pet.UUID))
+	}
+
+	return nil
+}
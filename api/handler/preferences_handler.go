@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/notify"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/validation"
+)
+
+type PreferencesHandler interface {
+	Get(ctx context.Context, userUid string) (*repository.UserPreferences, error)
+	Set(ctx context.Context, userUid string, preferences *repository.UserPreferences) (*repository.UserPreferences, error)
+}
+
+type PreferencesHandle struct {
+	preferencesRepository repository.PreferencesRepository
+}
+
+func NewPreferencesHandler(preferencesRepository repository.PreferencesRepository) PreferencesHandler {
+	return PreferencesHandle{preferencesRepository}
+}
+
+func (h PreferencesHandle) Get(ctx context.Context, userUid string) (*repository.UserPreferences, error) {
+	return h.preferencesRepository.GetPreferences(ctx, userUid)
+}
+
+func (h PreferencesHandle) Set(ctx context.Context, userUid string, preferences *repository.UserPreferences) (*repository.UserPreferences, error) {
+	if preferences.Language != "" && !notify.IsLanguage(preferences.Language) {
+		return nil, repository.NewValidationError("invalid_preferences", fmt.Errorf("unsupported language '%s'", preferences.Language))
+	}
+	if preferences.Timezone != "" {
+		_, err := time.LoadLocation(preferences.Timezone)
+		if err != nil {
+			return nil, repository.NewValidationError("invalid_preferences", fmt.Errorf("unknown timezone '%s'", preferences.Timezone))
+		}
+	}
+
+	for notificationType, preference := range preferences.Notifications {
+		if !notify.IsNotificationType(notificationType) {
+			return nil, repository.NewValidationError("invalid_preferences", fmt.Errorf("unknown notification type '%s'", notificationType))
+		}
+		err := validateChannelTypes(preference.Channels)
+		if err != nil {
+			return nil, err
+		}
+		if preference.QuietHours != nil {
+			err := validateTimeOfDay(preference.QuietHours.Start)
+			if err != nil {
+				return nil, err
+			}
+			err = validateTimeOfDay(preference.QuietHours.End)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if preferences.Digest.Enabled {
+		err := validateTimeOfDay(preferences.Digest.Time)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := validateChannelTypes(preferences.Digest.Channels)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.preferencesRepository.SetPreferences(ctx, userUid, preferences)
+}
+
+func validateChannelTypes(channelTypes []string) error {
+	for _, channelType := range channelTypes {
+		if !notify.IsChannelType(channelType) {
+			return repository.NewValidationError("invalid_preferences", fmt.Errorf("unknown notification channel '%s'", channelType))
+		}
+	}
+
+	return nil
+}
+
+func validateTimeOfDay(timeOfDay string) error {
+	if !validation.IsTimeOfDay(timeOfDay) {
+		return repository.NewValidationError("invalid_time_of_day", fmt.Errorf("time '%s' has to be formatted as HH:MM", timeOfDay))
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"math"
+)
+
+// lowStockDays is the number of days the stock of a medicine or food has to last before its caretakers get notified
+const lowStockDays = 7
+
+// stockDaysLeft calculates how many days the stock lasts with the given daily consumption
+func stockDaysLeft(stock int, dailyConsumption float64) float64 {
+	if dailyConsumption <= 0 {
+		return math.Inf(1)
+	}
+
+	return float64(stock) / dailyConsumption
+}
+
+// isStockRunningLow checks if the stock fell below the low stock threshold with the last change
+func isStockRunningLow(daysLeftBefore float64, daysLeft float64) bool {
+	return daysLeftBefore >= lowStockDays && daysLeft < lowStockDays
+}
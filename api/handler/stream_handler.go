@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/stream"
+)
+
+type StreamHandler interface {
+	Connect(ctx context.Context, userUid string, lastEventId string) *stream.Connection
+	OpenSession(userUid string, displayName string) (*stream.Session, error)
+}
+
+type StreamHandle struct {
+	hub           *stream.Hub
+	sessions      *stream.Sessions
+	petRepository repository.PetRepository
+}
+
+func NewStreamHandler(hub *stream.Hub, petRepository repository.PetRepository) StreamHandler {
+	return StreamHandle{hub, stream.NewSessions(hub, petRepository), petRepository}
+}
+
+// Connect streams the changes of all pets the user has access to until the context is done
+func (h StreamHandle) Connect(ctx context.Context, userUid string, lastEventId string) *stream.Connection {
+	return stream.Connect(ctx, h.hub, stream.NewAccessFilter(userUid, h.petRepository), lastEventId)
+}
+
+// OpenSession registers a WebSocket session of the user, it fails if the user has too many open sessions
+func (h StreamHandle) OpenSession(userUid string, displayName string) (*stream.Session, error) {
+	return h.sessions.Open(userUid, displayName)
+}
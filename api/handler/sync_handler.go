@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// syncOverlap is subtracted from the start of a sync to get the time of its cursor, so writes which were still
+	// in flight while the sync ran are returned again on the next sync instead of being missed
+	syncOverlap = time.Minute
+	// syncCursorRetention is how long a cursor can be used, it matches the retention of the tombstones
+	syncCursorRetention = repository.TombstoneRetention
+)
+
+type SyncHandler interface {
+	GetChanges(ctx context.Context, userUid string, cursorId string) (*repository.SyncChanges, error)
+	ApplyMutations(ctx context.Context, userUid string, batch *repository.MutationBatch) ([]*repository.MutationResult, error)
+}
+
+type SyncHandle struct {
+	syncRepository     repository.SyncRepository
+	petRepository      repository.PetRepository
+	medicineRepository repository.MedicineRepository
+	foodRepository     repository.FoodRepository
+	todoRepository     repository.TodoRepository
+}
+
+func NewSyncHandler(syncRepository repository.SyncRepository, petRepository repository.PetRepository, medicineRepository repository.MedicineRepository, foodRepository repository.FoodRepository, todoRepository repository.TodoRepository) SyncHandler {
+	return SyncHandle{syncRepository, petRepository, medicineRepository, foodRepository, todoRepository}
+}
+
+// GetChanges returns everything that changed for the user since the cursor. Pets the user gained access to are
+// returned completely, pets the user lost access to are returned as deleted. Without a known cursor all data is
+// returned and the client has to reset its local data.
+func (h SyncHandle) GetChanges(ctx context.Context, userUid string, cursorId string) (*repository.SyncChanges, error) {
+	startedAt := time.Now()
+
+	var cursor *repository.SyncCursor
+	if cursorId != "" {
+		var err error
+		cursor, err = h.syncRepository.GetSyncCursor(ctx, cursorId)
+		if err != nil {
+			return nil, err
+		}
+		// the cursor of another user is treated like an unknown one, so it doesn't reveal anything
+		if cursor != nil && (cursor.UserUID != userUid || startedAt.Sub(cursor.Since) > repository.TombstoneRetention) {
+			cursor = nil
+		}
+	}
+
+	pets, err := h.petRepository.GetPets(ctx, userUid)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &repository.SyncChanges{
+		Reset:      cursor == nil,
+		Pets:       []*repository.Pet{},
+		Medicines:  []*repository.Medicine{},
+		Foods:      []*repository.Food{},
+		ToDos:      []*repository.ToDo{},
+		Tombstones: []*repository.Tombstone{},
+	}
+
+	knownPets := map[string]bool{}
+	if cursor != nil {
+		for _, petUuid := range cursor.PetUuids {
+			knownPets[petUuid] = true
+		}
+	}
+
+	accessiblePets := map[string]bool{}
+	petUuids := []string{}
+	for _, pet := range pets {
+		petUuid := pet.UUID.String()
+		accessiblePets[petUuid] = true
+		petUuids = append(petUuids, petUuid)
+
+		if knownPets[petUuid] {
+			err = h.addPetChanges(ctx, userUid, changes, pet, cursor.Since)
+		} else {
+			err = h.addPet(ctx, userUid, changes, pet)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sync pet '%s'", petUuid)
+		}
+	}
+
+	// deleted pets and pets the user lost access to look the same to the client
+	for petUuid := range knownPets {
+		if accessiblePets[petUuid] {
+			continue
+		}
+
+		petUUID, err := uuid.Parse(petUuid)
+		if err != nil {
+			return nil, err
+		}
+		changes.Tombstones = append(changes.Tombstones, &repository.Tombstone{
+			UUID:      petUUID,
+			Kind:      repository.TOMBSTONE_KIND_PET,
+			PetUUID:   petUUID,
+			DeletedAt: startedAt,
+		})
+	}
+
+	nextCursor := &repository.SyncCursor{
+		ID:          uuid.NewString(),
+		UserUID:     userUid,
+		Since:       startedAt.Add(-syncOverlap),
+		PetUuids:    petUuids,
+		CreatedAt:   startedAt,
+		DeleteAfter: startedAt.Add(syncCursorRetention),
+	}
+	err = h.syncRepository.AddSyncCursor(ctx, nextCursor)
+	if err != nil {
+		return nil, err
+	}
+	changes.Cursor = nextCursor.ID
+
+	return changes, nil
+}
+
+// addPet adds the pet with all of its documents
+func (h SyncHandle) addPet(ctx context.Context, userUid string, changes *repository.SyncChanges, pet *repository.Pet) error {
+	petUuid := pet.UUID.String()
+
+	medicines, err := h.medicineRepository.GetMedicines(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	foods, err := h.foodRepository.GetFoods(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	todos, err := h.todoRepository.GetToDosForPet(ctx, userUid, petUuid)
+	if err != nil {
+		return err
+	}
+
+	changes.Pets = append(changes.Pets, pet)
+	changes.Medicines = append(changes.Medicines, medicines...)
+	changes.Foods = append(changes.Foods, foods...)
+	changes.ToDos = append(changes.ToDos, todos...)
+
+	return nil
+}
+
+// addPetChanges adds the pet and its documents which were changed or deleted after the given time
+func (h SyncHandle) addPetChanges(ctx context.Context, userUid string, changes *repository.SyncChanges, pet *repository.Pet, since time.Time) error {
+	petUuid := pet.UUID.String()
+
+	medicines, err := h.medicineRepository.GetMedicinesChangedSince(ctx, userUid, petUuid, since)
+	if err != nil {
+		return err
+	}
+
+	foods, err := h.foodRepository.GetFoodsChangedSince(ctx, userUid, petUuid, since)
+	if err != nil {
+		return err
+	}
+
+	todos, err := h.todoRepository.GetToDosForPetChangedSince(ctx, userUid, petUuid, since)
+	if err != nil {
+		return err
+	}
+
+	tombstones, err := h.syncRepository.GetTombstones(ctx, petUuid, since)
+	if err != nil {
+		return err
+	}
+
+	if pet.UpdatedAt.After(since) {
+		changes.Pets = append(changes.Pets, pet)
+	}
+	changes.Medicines = append(changes.Medicines, medicines...)
+	changes.Foods = append(changes.Foods, foods...)
+	changes.ToDos = append(changes.ToDos, todos...)
+	changes.Tombstones = append(changes.Tombstones, tombstones...)
+
+	return nil
+}
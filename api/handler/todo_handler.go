@@ -2,24 +2,27 @@ package handler
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/cafo13/fur-meds/api/events"
 	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
 type TodoHandler interface {
 	GetAllForUser(ctx context.Context, userUid string) ([]*repository.ToDo, error)
-	SetToDoStatus(ctx context.Context, userUid string, newStatus repository.ToDoStatus) ([]*repository.ToDo, error)
+	SetToDoStatus(ctx context.Context, userUid string, todoUuid string, newStatus repository.ToDoStatus) ([]*repository.ToDo, error)
 }
 
 type TodoHandle struct {
 	todoRepository repository.TodoRepository
 	petRepository  repository.PetRepository
-	todoChannel    chan string
 }
 
-func NewTodoHandler(todoRepository repository.TodoRepository, petRepository repository.PetRepository, todoChannel chan string) TodoHandler {
-	return TodoHandle{todoRepository, petRepository, todoChannel}
+func NewTodoHandler(todoRepository repository.TodoRepository, petRepository repository.PetRepository) TodoHandler {
+	return TodoHandle{todoRepository, petRepository}
 }
 
 func (h TodoHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repository.ToDo, error) {
@@ -30,7 +33,11 @@ func (h TodoHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repos
 
 	userTodos := []*repository.ToDo{}
 	for _, pet := range userPets {
-		petToDos, err := h.todoRepository.GetToDosForPet(ctx, pet.UUID.String())
+		if pet.IsArchived() {
+			continue
+		}
+
+		petToDos, err := h.todoRepository.GetToDosForPet(ctx, userUid, pet.UUID.String())
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to get todos for pet %s", pet.UUID.String())
 		}
@@ -40,6 +47,79 @@ func (h TodoHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repos
 	return userTodos, nil
 }
 
-func (h TodoHandle) SetToDoStatus(ctx context.Context, userUid string, newStatus repository.ToDoStatus) ([]*repository.ToDo, error) {
-	return nil, nil
+func (h TodoHandle) SetToDoStatus(ctx context.Context, userUid string, todoUuid string, newStatus repository.ToDoStatus) ([]*repository.ToDo, error) {
+	if newStatus != repository.TODO_STATUS_OPEN && newStatus != repository.TODO_STATUS_DONE {
+		return nil, repository.NewValidationError("invalid_todo_status", fmt.Errorf("unknown todo status '%s'", newStatus))
+	}
+
+	// the todo is only found among the todos of the pets the user has access to
+	userTodos, err := h.GetAllForUser(ctx, userUid)
+	if err != nil {
+		return nil, err
+	}
+
+	var todo *repository.ToDo
+	for _, userTodo := range userTodos {
+		if userTodo.UUID.String() == todoUuid {
+			todo = userTodo
+		}
+	}
+	if todo == nil {
+		return nil, repository.NewNotFoundError("todo_not_found", fmt.Errorf("todo '%s' not found", todoUuid))
+	}
+
+	var updatedToDo, completedToDo *repository.ToDo
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return todoUpdatedEvents(userUid, updatedToDo, completedToDo)
+	})
+
+	_, err = h.todoRepository.UpdateToDo(
+		ctx,
+		userUid,
+		todo.PetUUID.String(),
+		todoUuid,
+		func(context context.Context, firestoreToDo *repository.ToDo) (*repository.ToDo, error) {
+			updatedToDo = nil
+			completedToDo = nil
+			if firestoreToDo.Status == newStatus {
+				return firestoreToDo, nil
+			}
+
+			firestoreToDo.Status = newStatus
+			updatedToDo = firestoreToDo
+			if newStatus == repository.TODO_STATUS_DONE {
+				completedAt := time.Now()
+				firestoreToDo.CompletedBy = userUid
+				firestoreToDo.CompletedAt = &completedAt
+				completedToDo = firestoreToDo
+			} else {
+				firestoreToDo.CompletedBy = ""
+				firestoreToDo.CompletedAt = nil
+			}
+
+			return firestoreToDo, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.GetAllForUser(ctx, userUid)
+}
+
+// todoUpdatedEvents are the events of a todo whose status changed, completedToDo is only set if the todo was completed
+func todoUpdatedEvents(userUid string, updatedToDo *repository.ToDo, completedToDo *repository.ToDo) []events.Event {
+	if updatedToDo == nil {
+		return nil
+	}
+
+	todoEvents := []events.Event{events.ToDoUpdated{Header: events.NewHeader(userUid, updatedToDo.PetUUID.String()), ToDo: updatedToDo}}
+	if completedToDo != nil {
+		todoEvents = append(todoEvents, events.ToDoCompleted{Header: events.NewHeader(userUid, completedToDo.PetUUID.String()), ToDo: completedToDo})
+		if completedToDo.MedicineUUID != uuid.Nil {
+			todoEvents = append(todoEvents, events.DoseRecorded{Header: events.NewHeader(userUid, completedToDo.PetUUID.String()), ToDo: completedToDo})
+		}
+	}
+
+	return todoEvents
 }
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+type TrashHandler interface {
+	GetAllForUser(ctx context.Context, userUid string) ([]*repository.TrashItem, error)
+	Restore(ctx context.Context, userUid string, trashItemUuid string) ([]*repository.TrashItem, error)
+}
+
+type TrashHandle struct {
+	trashRepository repository.TrashRepository
+	petRepository   repository.PetRepository
+}
+
+func NewTrashHandler(trashRepository repository.TrashRepository, petRepository repository.PetRepository) TrashHandler {
+	return TrashHandle{trashRepository, petRepository}
+}
+
+// GetAllForUser lists the deleted pets of the user and the deleted medicines and foods of the pets the user has
+// access to
+func (h TrashHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repository.TrashItem, error) {
+	userTrashItems, err := h.trashRepository.GetTrashedPets(ctx, userUid)
+	if err != nil {
+		return nil, err
+	}
+
+	userPets, err := h.petRepository.GetPets(ctx, userUid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pet := range userPets {
+		petTrashItems, err := h.trashRepository.GetTrashItemsForPet(ctx, pet.UUID.String())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get deleted documents of pet %s", pet.UUID.String())
+		}
+		userTrashItems = append(userTrashItems, petTrashItems...)
+	}
+
+	return userTrashItems, nil
+}
+
+// Restore moves the deleted pet, medicine or food out of the trash. A pet is restored by its owner, a medicine or
+// food by a user with access to its pet, which has to be restored first if it was deleted as well.
+func (h TrashHandle) Restore(ctx context.Context, userUid string, trashItemUuid string) ([]*repository.TrashItem, error) {
+	trashItem, err := h.trashRepository.GetTrashItem(ctx, trashItemUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var restoredEvent events.Event
+	header := events.NewHeader(userUid, trashItem.PetUUID.String())
+	switch trashItem.Kind {
+	case repository.TRASH_KIND_PET:
+		// the deleted pets of other users are not listed, so they can't be found either
+		if trashItem.Pet.UserUID != userUid {
+			return nil, repository.NewNotFoundError("trash_item_not_found", fmt.Errorf("no deleted document with UUID '%s' in the trash", trashItemUuid))
+		}
+		restoredEvent = events.PetCreated{Header: header, Pet: trashItem.Pet}
+	case repository.TRASH_KIND_MEDICINE, repository.TRASH_KIND_FOOD:
+		_, err = h.petRepository.GetPet(ctx, userUid, trashItem.PetUUID.String())
+		if isNotFound(err) {
+			return nil, repository.NewConflictError("pet_in_trash", errors.Wrapf(err, "pet '%s' has to be restored first", trashItem.PetUUID))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if trashItem.Kind == repository.TRASH_KIND_MEDICINE {
+			restoredEvent = events.MedicineCreated{Header: header, Medicine: trashItem.Medicine}
+		} else {
+			restoredEvent = events.FoodCreated{Header: header, Food: trashItem.Food}
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind '%s' of deleted document", trashItem.Kind)
+	}
+
+	if time.Now().After(trashItem.PurgeAfter) {
+		return nil, repository.NewConflictError("trash_item_expired", fmt.Errorf("deleted document '%s' could only be restored until %s", trashItemUuid, trashItem.PurgeAfter.Format(time.RFC3339)))
+	}
+
+	ctx = events.WithOutbox(ctx, func() []events.Event {
+		return []events.Event{restoredEvent}
+	})
+
+	err = h.trashRepository.RestoreTrashItem(ctx, userUid, trashItemUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.GetAllForUser(ctx, userUid)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/webhook"
+	"github.com/pkg/errors"
+)
+
+// webhookDeliveriesLimit is the number of deliveries shown in the delivery log of a webhook
+const webhookDeliveriesLimit = 100
+
+type WebhookHandler interface {
+	Create(ctx context.Context, userUid string, webhook *repository.Webhook) ([]*repository.Webhook, error)
+	Get(ctx context.Context, userUid string, webhookUuid string) (*repository.Webhook, error)
+	GetAllForUser(ctx context.Context, userUid string) ([]*repository.Webhook, error)
+	Update(ctx context.Context, userUid string, webhookUuid string, webhookUpdate *repository.WebhookUpdate) ([]*repository.Webhook, error)
+	Delete(ctx context.Context, userUid string, webhookUuid string) ([]*repository.Webhook, error)
+	GetDeliveries(ctx context.Context, userUid string, webhookUuid string) ([]*repository.WebhookDelivery, error)
+	Redeliver(ctx context.Context, userUid string, webhookUuid string, deliveryUuid string) ([]*repository.WebhookDelivery, error)
+}
+
+type WebhookHandle struct {
+	webhookRepository repository.WebhookRepository
+}
+
+func NewWebhookHandler(webhookRepository repository.WebhookRepository) WebhookHandler {
+	return WebhookHandle{webhookRepository}
+}
+
+func (h WebhookHandle) Create(ctx context.Context, userUid string, newWebhook *repository.Webhook) ([]*repository.Webhook, error) {
+	err := validateWebhook(newWebhook)
+	if err != nil {
+		return nil, err
+	}
+
+	if newWebhook.Secret == "" {
+		newWebhook.Secret, err = webhook.NewSecret()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate webhook secret")
+		}
+	}
+	newWebhook.Active = true
+
+	return h.webhookRepository.AddWebhook(ctx, userUid, newWebhook)
+}
+
+func (h WebhookHandle) Get(ctx context.Context, userUid string, webhookUuid string) (*repository.Webhook, error) {
+	return h.webhookRepository.GetWebhook(ctx, userUid, webhookUuid)
+}
+
+func (h WebhookHandle) GetAllForUser(ctx context.Context, userUid string) ([]*repository.Webhook, error) {
+	return h.webhookRepository.GetWebhooks(ctx, userUid)
+}
+
+// Update changes the fields of the webhook which are given, the others keep their value
+func (h WebhookHandle) Update(ctx context.Context, userUid string, webhookUuid string, updatedWebhook *repository.WebhookUpdate) ([]*repository.Webhook, error) {
+	if updatedWebhook.URL != "" {
+		err := validateWebhookURL(updatedWebhook.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := validateWebhookEvents(updatedWebhook.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.webhookRepository.UpdateWebhook(
+		ctx,
+		userUid,
+		webhookUuid,
+		func(context context.Context, firestoreWebhook *repository.Webhook) (*repository.Webhook, error) {
+			if updatedWebhook.URL != "" && updatedWebhook.URL != firestoreWebhook.URL {
+				firestoreWebhook.URL = updatedWebhook.URL
+			}
+			if updatedWebhook.Secret != "" && updatedWebhook.Secret != firestoreWebhook.Secret {
+				firestoreWebhook.Secret = updatedWebhook.Secret
+			}
+			if len(updatedWebhook.Events) != 0 {
+				firestoreWebhook.Events = updatedWebhook.Events
+			}
+			if updatedWebhook.Active != nil {
+				firestoreWebhook.Active = *updatedWebhook.Active
+			}
+
+			return firestoreWebhook, nil
+		},
+	)
+}
+
+func (h WebhookHandle) Delete(ctx context.Context, userUid string, webhookUuid string) ([]*repository.Webhook, error) {
+	return h.webhookRepository.DeleteWebhook(ctx, userUid, webhookUuid)
+}
+
+func (h WebhookHandle) GetDeliveries(ctx context.Context, userUid string, webhookUuid string) ([]*repository.WebhookDelivery, error) {
+	return h.webhookRepository.GetWebhookDeliveries(ctx, userUid, webhookUuid, webhookDeliveriesLimit)
+}
+
+// Redeliver queues the payload of a previous delivery again, as a new delivery with its own attempts
+func (h WebhookHandle) Redeliver(ctx context.Context, userUid string, webhookUuid string, deliveryUuid string) ([]*repository.WebhookDelivery, error) {
+	delivery, err := h.webhookRepository.GetWebhookDelivery(ctx, userUid, deliveryUuid)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookUUID.String() != webhookUuid {
+		return nil, repository.NewNotFoundError("webhook_delivery_not_found", fmt.Errorf("delivery '%s' does not belong to webhook '%s'", deliveryUuid, webhookUuid))
+	}
+
+	redeliveryOf := delivery.UUID
+	err = h.webhookRepository.AddWebhookDelivery(ctx, &repository.WebhookDelivery{
+		WebhookUUID:   delivery.WebhookUUID,
+		UserUID:       delivery.UserUID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        repository.WEBHOOK_DELIVERY_STATUS_PENDING,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  &redeliveryOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h.GetDeliveries(ctx, userUid, webhookUuid)
+}
+
+func validateWebhook(webhook *repository.Webhook) error {
+	err := validateWebhookURL(webhook.URL)
+	if err != nil {
+		return err
+	}
+	if len(webhook.Events) == 0 {
+		return repository.NewValidationError("invalid_webhook", fmt.Errorf("webhook needs to be subscribed to at least one event"))
+	}
+
+	return validateWebhookEvents(webhook.Events)
+}
+
+// validateWebhookURL rejects URLs of hosts which are not public, the delivery worker checks the resolved address again
+// on every connect, see webhook.NewPublicHTTPClient
+func validateWebhookURL(webhookUrl string) error {
+	parsedUrl, err := url.Parse(webhookUrl)
+	if err != nil || parsedUrl.Scheme != "https" || parsedUrl.Host == "" {
+		return repository.NewValidationError("invalid_webhook", fmt.Errorf("webhook URL '%s' has to be an absolute https URL", webhookUrl))
+	}
+	if !webhook.IsPublicHost(parsedUrl.Hostname()) {
+		return repository.NewValidationError("invalid_webhook", fmt.Errorf("webhook URL '%s' has to point to a public host", webhookUrl))
+	}
+
+	return nil
+}
+
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		if !webhook.IsEventType(event) {
+			return repository.NewValidationError("invalid_webhook", fmt.Errorf("unknown webhook event '%s'", event))
+		}
+	}
+
+	return nil
+}
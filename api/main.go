@@ -3,16 +3,24 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 
 	"github.com/cafo13/fur-meds/api/auth"
 	"github.com/cafo13/fur-meds/api/cors"
+	"github.com/cafo13/fur-meds/api/events"
 	"github.com/cafo13/fur-meds/api/handler"
+	"github.com/cafo13/fur-meds/api/notify"
 	"github.com/cafo13/fur-meds/api/repository"
 	"github.com/cafo13/fur-meds/api/router"
+	"github.com/cafo13/fur-meds/api/scheduler"
+	"github.com/cafo13/fur-meds/api/stream"
+	"github.com/cafo13/fur-meds/api/webhook"
 
 	firebase "firebase.google.com/go/v4"
 	log "github.com/sirupsen/logrus"
@@ -23,10 +31,9 @@ func setupLogger() {
 	log.SetLevel(log.InfoLevel)
 }
 
-func setupAuthMiddleware(gcpProject string) *auth.AuthMiddleware {
+func setupFirebaseApp(gcpProject string) *firebase.App {
 	if mockAuth, _ := strconv.ParseBool(os.Getenv("MOCK_AUTH")); mockAuth {
-		authMiddleware := auth.NewMockAuthMiddleware()
-		return &authMiddleware
+		return nil
 	}
 
 	config := &firebase.Config{ProjectID: gcpProject}
@@ -35,6 +42,15 @@ func setupAuthMiddleware(gcpProject string) *auth.AuthMiddleware {
 		panic(err)
 	}
 
+	return firebaseApp
+}
+
+func setupAuthMiddleware(firebaseApp *firebase.App) *auth.AuthMiddleware {
+	if firebaseApp == nil {
+		authMiddleware := auth.NewMockAuthMiddleware()
+		return &authMiddleware
+	}
+
 	authClient, err := firebaseApp.Auth(context.Background())
 	if err != nil {
 		panic(err)
@@ -44,6 +60,94 @@ func setupAuthMiddleware(gcpProject string) *auth.AuthMiddleware {
 	return &authMiddleware
 }
 
+func setupNotifier(firebaseApp *firebase.App, deviceRepository repository.DeviceRepository, preferencesRepository repository.PreferencesRepository, notificationQueueRepository repository.NotificationQueueRepository) notify.ChannelNotifier {
+	language := os.Getenv("NOTIFY_LANGUAGE")
+	if len(language) == 0 {
+		language = notify.DefaultLanguage
+	}
+
+	templates, err := notify.NewTemplates()
+	if err != nil {
+		panic(err)
+	}
+
+	var resolver notify.RecipientResolver = notify.NewStaticRecipientResolver(language)
+	if firebaseApp != nil {
+		authClient, err := firebaseApp.Auth(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		resolver = notify.NewFirebaseRecipientResolver(authClient, deviceRepository, language)
+	}
+
+	notifyChannels := os.Getenv("NOTIFY_CHANNELS")
+	if len(notifyChannels) == 0 {
+		notifyChannels = "log"
+	}
+
+	channels := []notify.Channel{}
+	for _, channel := range strings.Split(notifyChannels, ",") {
+		switch strings.TrimSpace(channel) {
+		case "log":
+			channels = append(channels, notify.NewLogChannel())
+		case "file":
+			channels = append(channels, notify.NewFileChannel(os.Getenv("NOTIFY_FILE_PATH")))
+		case "email":
+			channels = append(channels, notify.NewSMTPChannel(notify.SMTPConfig{
+				Host:     os.Getenv("SMTP_HOST"),
+				Port:     os.Getenv("SMTP_PORT"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     os.Getenv("SMTP_FROM"),
+			}))
+		case "push":
+			if firebaseApp == nil {
+				panic(errors.New("push notifications need Firebase, they can't be used together with MOCK_AUTH"))
+			}
+			messagingClient, err := firebaseApp.Messaging(context.Background())
+			if err != nil {
+				panic(err)
+			}
+			channels = append(channels, notify.NewPushChannel(notify.NewFCMPushSender(messagingClient), deviceRepository))
+		case "webhook":
+			channels = append(channels, notify.NewWebhookChannel(os.Getenv("NOTIFY_WEBHOOK_URL")))
+		default:
+			panic(fmt.Errorf("unknown notification channel '%s' in NOTIFY_CHANNELS", channel))
+		}
+	}
+
+	return notify.NewChannelNotifier(resolver, templates, preferencesRepository, notificationQueueRepository, channels...)
+}
+
+func setupReminderLocation() *time.Location {
+	reminderTimezone := os.Getenv("REMINDER_TIMEZONE")
+	if len(reminderTimezone) == 0 {
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(reminderTimezone)
+	if err != nil {
+		panic(err)
+	}
+
+	return location
+}
+
+// setupCORSMiddleware allows the comma-separated origins of CORS_ALLOWED_ORIGINS, every origin is allowed if none are set
+func setupCORSMiddleware() cors.CORSMiddleware {
+	allowedOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if len(allowedOrigins) == 0 {
+		return cors.NewAllowingCORSMiddleware()
+	}
+
+	origins := []string{}
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		origins = append(origins, strings.TrimSpace(origin))
+	}
+
+	return cors.NewAllowListCORSMiddleware(origins)
+}
+
 func setupFirestoreClient(ctx context.Context, gcpProject string) *firestore.Client {
 	client, err := firestore.NewClient(ctx, gcpProject)
 	if err != nil {
@@ -70,17 +174,45 @@ func main() {
 		panic(errors.New("GCP_PROJECT environment variable needs to be set"))
 	}
 
-	authMiddleware := setupAuthMiddleware(gcpProject)
-	corsMiddleware := cors.NewAllowingCORSMiddleware()
-	todoChannel := make(chan string)
+	firebaseApp := setupFirebaseApp(gcpProject)
+	authMiddleware := setupAuthMiddleware(firebaseApp)
+	corsMiddleware := setupCORSMiddleware()
 	firestoreClient := setupFirestoreClient(context.Background(), gcpProject)
+	deviceRepository := repository.NewDeviceFirestoreRepository(firestoreClient)
+	preferencesRepository := repository.NewPreferencesFirestoreRepository(firestoreClient)
+	notifier := setupNotifier(firebaseApp, deviceRepository, preferencesRepository, repository.NewNotificationQueueFirestoreRepository(firestoreClient))
 	petRepository := repository.NewPetFirestoreRepository(firestoreClient)
+	medicineRepository := repository.NewMedicineFirestoreRepository(firestoreClient)
+	foodRepository := repository.NewFoodFirestoreRepository(firestoreClient)
+	todoRepository := repository.NewTodoFirestoreRepository(firestoreClient)
+	webhookRepository := repository.NewWebhookFirestoreRepository(firestoreClient)
+	trashRepository := repository.NewTrashFirestoreRepository(firestoreClient)
+	eventBus := events.NewBus()
+	notify.SubscribeToEvents(eventBus, notifier, petRepository)
+	webhook.SubscribeToEvents(eventBus, webhook.NewQueueDispatcher(webhookRepository, petRepository))
 	router := setupRouter(authMiddleware, &corsMiddleware, &router.HandlerSet{
-		PetHandler:      handler.NewPetHandler(petRepository, todoChannel),
-		MedicineHandler: handler.NewMedicineHandler(repository.NewMedicineFirestoreRepository(firestoreClient), petRepository),
-		FoodHandler:     handler.NewFoodHandler(repository.NewFoodFirestoreRepository(firestoreClient), petRepository),
-		TodoHandler:     handler.NewTodoHandler(repository.NewTodoFirestoreRepository(firestoreClient), petRepository, todoChannel),
+		PetHandler:         handler.NewPetHandler(petRepository),
+		MedicineHandler:    handler.NewMedicineHandler(medicineRepository),
+		FoodHandler:        handler.NewFoodHandler(foodRepository),
+		TodoHandler:        handler.NewTodoHandler(todoRepository, petRepository),
+		HouseholdHandler:   handler.NewHouseholdHandler(repository.NewHouseholdFirestoreRepository(firestoreClient), petRepository),
+		CareSheetHandler:   handler.NewCareSheetHandler(petRepository, medicineRepository, foodRepository),
+		DeviceHandler:      handler.NewDeviceHandler(deviceRepository),
+		PreferencesHandler: handler.NewPreferencesHandler(preferencesRepository),
+		WebhookHandler:     handler.NewWebhookHandler(webhookRepository),
+		StreamHandler:      handler.NewStreamHandler(stream.NewHub(eventBus), petRepository),
+		SyncHandler:        handler.NewSyncHandler(repository.NewSyncFirestoreRepository(firestoreClient), petRepository, medicineRepository, foodRepository, todoRepository),
+		TrashHandler:       handler.NewTrashHandler(trashRepository, petRepository),
+		ActivityHandler:    handler.NewActivityHandler(repository.NewActivityFirestoreRepository(firestoreClient)),
 	})
 
+	reminderLocation := setupReminderLocation()
+	scheduler.NewDoseReminder(medicineRepository, petRepository, todoRepository, preferencesRepository, notifier, reminderLocation).Start(context.Background())
+	scheduler.NewMissedDoseEscalator(todoRepository, medicineRepository, petRepository, preferencesRepository, notifier, reminderLocation).Start(context.Background())
+	scheduler.NewNotificationQueueWorker(notifier).Start(context.Background())
+	scheduler.NewWebhookDeliveryWorker(webhook.NewDeliveryWorker(webhookRepository)).Start(context.Background())
+	scheduler.NewTrashPurger(trashRepository).Start(context.Background())
+	events.NewRelay(repository.NewOutboxFirestoreRepository(firestoreClient), eventBus).Start(context.Background())
+
 	router.StartRouter(apiPort)
 }
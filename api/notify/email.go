@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPChannel sends notifications as email over SMTP
+type SMTPChannel struct {
+	config SMTPConfig
+}
+
+func NewSMTPChannel(config SMTPConfig) Channel {
+	return SMTPChannel{config}
+}
+
+func (c SMTPChannel) Type() ChannelType {
+	return CHANNEL_TYPE_EMAIL
+}
+
+func (c SMTPChannel) Send(ctx context.Context, recipient Recipient, message Message) error {
+	if recipient.Email == "" {
+		return nil
+	}
+
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		auth = smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+	}
+
+	mail := strings.Join([]string{
+		fmt.Sprintf("From: %s", c.config.From),
+		fmt.Sprintf("To: %s", recipient.Email),
+		fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("utf-8", message.Title)),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		message.Body,
+	}, "\r\n")
+
+	err := smtp.SendMail(c.config.Host+":"+c.config.Port, auth, c.config.From, []string{recipient.Email}, []byte(mail))
+	if err != nil {
+		return errors.Wrapf(err, "failed to send email to '%s'", recipient.Email)
+	}
+
+	return nil
+}
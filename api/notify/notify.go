@@ -0,0 +1,460 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type NotificationType string
+
+const (
+	NOTIFICATION_TYPE_SHARE_INVITE NotificationType = "ShareInvite"
+	NOTIFICATION_TYPE_DOSE_DUE     NotificationType = "DoseDue"
+	NOTIFICATION_TYPE_LOW_STOCK    NotificationType = "LowStock"
+	NOTIFICATION_TYPE_MISSED_DOSE  NotificationType = "MissedDose"
+	NOTIFICATION_TYPE_SHARE_EXPIRY NotificationType = "ShareExpiry"
+	// NOTIFICATION_TYPE_DIGEST is the daily message which batches non-urgent notifications, it can't be configured on its own
+	NOTIFICATION_TYPE_DIGEST NotificationType = "Digest"
+)
+
+var notificationTypes = []NotificationType{
+	NOTIFICATION_TYPE_SHARE_INVITE,
+	NOTIFICATION_TYPE_DOSE_DUE,
+	NOTIFICATION_TYPE_LOW_STOCK,
+	NOTIFICATION_TYPE_MISSED_DOSE,
+	NOTIFICATION_TYPE_SHARE_EXPIRY,
+}
+
+const (
+	// queuedNotificationRetryDelay is doubled after every failed attempt to send a queued notification
+	queuedNotificationRetryDelay = 5 * time.Minute
+	// maxQueuedNotificationAttempts limits the attempts to send a queued notification, so a broken channel doesn't
+	// repeat it forever
+	maxQueuedNotificationAttempts = 6
+)
+
+// urgentNotificationTypes are never batched in the daily digest, as they are about something to do right now
+var urgentNotificationTypes = []NotificationType{
+	NOTIFICATION_TYPE_DOSE_DUE,
+	NOTIFICATION_TYPE_MISSED_DOSE,
+}
+
+func IsNotificationType(value string) bool {
+	for _, notificationType := range notificationTypes {
+		if string(notificationType) == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t NotificationType) IsUrgent() bool {
+	for _, urgentType := range urgentNotificationTypes {
+		if urgentType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ChannelType string
+
+const (
+	CHANNEL_TYPE_EMAIL   ChannelType = "Email"
+	CHANNEL_TYPE_PUSH    ChannelType = "Push"
+	CHANNEL_TYPE_WEBHOOK ChannelType = "Webhook"
+	CHANNEL_TYPE_LOG     ChannelType = "Log"
+)
+
+var channelTypes = []ChannelType{
+	CHANNEL_TYPE_EMAIL,
+	CHANNEL_TYPE_PUSH,
+	CHANNEL_TYPE_WEBHOOK,
+	CHANNEL_TYPE_LOG,
+}
+
+func IsChannelType(value string) bool {
+	for _, channelType := range channelTypes {
+		if string(channelType) == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Notification is something a user should be informed about, Data holds the values used in the message templates
+type Notification struct {
+	// ID makes sending the notification idempotent, a notification with an ID is only sent once even if it is
+	// notified again, e.g. when the event which caused it is handled again. The zero value sends it every time.
+	ID      string            `json:"-"`
+	Type    NotificationType  `json:"type"`
+	UserUid string            `json:"userUid"`
+	Data    map[string]string `json:"data"`
+	// SendAfter holds back the notification until the given time, the zero value sends it right away
+	SendAfter time.Time `json:"-"`
+}
+
+// PushDevice is a device of a recipient which can receive push messages
+type PushDevice struct {
+	Token      string
+	MutedTypes []NotificationType
+}
+
+func (d PushDevice) IsMuted(notificationType NotificationType) bool {
+	for _, mutedType := range d.MutedTypes {
+		if mutedType == notificationType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Recipient holds everything the channels need to reach a user
+type Recipient struct {
+	UserUid     string       `json:"userUid"`
+	Email       string       `json:"email"`
+	Language    string       `json:"language"`
+	PushDevices []PushDevice `json:"-"`
+}
+
+// Message is a notification rendered for a recipient
+type Message struct {
+	Type  NotificationType  `json:"type"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data"`
+}
+
+type Channel interface {
+	Type() ChannelType
+	Send(ctx context.Context, recipient Recipient, message Message) error
+}
+
+type RecipientResolver interface {
+	Resolve(ctx context.Context, userUid string) (Recipient, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+type ChannelNotifier struct {
+	resolver              RecipientResolver
+	templates             *Templates
+	preferencesRepository repository.PreferencesRepository
+	queueRepository       repository.NotificationQueueRepository
+	channels              []Channel
+}
+
+func NewChannelNotifier(resolver RecipientResolver, templates *Templates, preferencesRepository repository.PreferencesRepository, queueRepository repository.NotificationQueueRepository, channels ...Channel) ChannelNotifier {
+	return ChannelNotifier{resolver, templates, preferencesRepository, queueRepository, channels}
+}
+
+// Notify sends the notification according to the preferences of the user. Notifications in the quiet hours
+// of their type or batched in the daily digest are queued and sent later by SendQueuedNotifications, which also
+// retries sending notifications on the channels they failed on.
+func (n ChannelNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.ID == "" {
+		return n.notify(ctx, notification)
+	}
+
+	claimed, err := n.queueRepository.ClaimNotification(ctx, notification.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	err = n.notify(ctx, notification)
+	if err != nil {
+		// the notification was neither sent nor queued, so it has to be sent when it is notified again
+		releaseErr := n.queueRepository.ReleaseNotification(ctx, notification.ID)
+		if releaseErr != nil {
+			log.Error(releaseErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (n ChannelNotifier) notify(ctx context.Context, notification Notification) error {
+	preferences, err := n.preferencesRepository.GetPreferences(ctx, notification.UserUid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get notification preferences of user '%s'", notification.UserUid)
+	}
+
+	preference := preferences.NotificationPreference(string(notification.Type))
+	if preference.Disabled {
+		return nil
+	}
+
+	now := time.Now()
+	if notification.SendAfter.After(now) {
+		now = notification.SendAfter
+	}
+
+	deliverAfter, digest := deliveryTime(notification.Type, preference, preferences.Digest, now.In(preferencesLocation(preferences)))
+	if deliverAfter.IsZero() && notification.SendAfter.After(time.Now()) {
+		deliverAfter = notification.SendAfter
+	}
+	if !deliverAfter.IsZero() {
+		return n.queueRepository.EnqueueNotification(ctx, &repository.QueuedNotification{
+			UserUID:          notification.UserUid,
+			NotificationType: string(notification.Type),
+			Data:             notification.Data,
+			DeliverAfter:     deliverAfter,
+			Digest:           digest,
+		})
+	}
+
+	sentChannels, err := n.send(ctx, notification, preferences, preference.Channels, nil)
+	if err != nil {
+		// the notification is retried on the channels it failed on, instead of sending it again on every channel
+		log.Error(err)
+		return n.queueRepository.EnqueueNotification(ctx, &repository.QueuedNotification{
+			UserUID:          notification.UserUid,
+			NotificationType: string(notification.Type),
+			Data:             notification.Data,
+			DeliverAfter:     time.Now().Add(queuedNotificationRetryDelay),
+			FailedAttempts:   1,
+			SentChannels:     sentChannels,
+		})
+	}
+
+	return nil
+}
+
+// SendQueuedNotifications sends all queued notifications which are due, digest notifications are batched into one message per user
+func (n ChannelNotifier) SendQueuedNotifications(ctx context.Context, now time.Time) error {
+	queuedNotifications, err := n.queueRepository.GetQueuedNotificationsDueBefore(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	userUids := []string{}
+	notificationsByUser := map[string][]*repository.QueuedNotification{}
+	for _, queuedNotification := range queuedNotifications {
+		if _, ok := notificationsByUser[queuedNotification.UserUID]; !ok {
+			userUids = append(userUids, queuedNotification.UserUID)
+		}
+		notificationsByUser[queuedNotification.UserUID] = append(notificationsByUser[queuedNotification.UserUID], queuedNotification)
+	}
+
+	for _, userUid := range userUids {
+		err := n.sendQueuedNotificationsOfUser(ctx, userUid, notificationsByUser[userUid], now)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to send queued notifications to user '%s'", userUid))
+		}
+	}
+
+	return nil
+}
+
+func (n ChannelNotifier) sendQueuedNotificationsOfUser(ctx context.Context, userUid string, queuedNotifications []*repository.QueuedNotification, now time.Time) error {
+	preferences, err := n.preferencesRepository.GetPreferences(ctx, userUid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get notification preferences of user '%s'", userUid)
+	}
+
+	// only the queued notifications which were sent or are disabled are removed, the others are retried later
+	doneNotificationUuids := []uuid.UUID{}
+	failedNotifications := []*repository.QueuedNotification{}
+	digestNotifications := []Notification{}
+	queuedDigestNotifications := []*repository.QueuedNotification{}
+	for _, queuedNotification := range queuedNotifications {
+		notification := Notification{
+			Type:    NotificationType(queuedNotification.NotificationType),
+			UserUid: userUid,
+			Data:    queuedNotification.Data,
+		}
+
+		preference := preferences.NotificationPreference(queuedNotification.NotificationType)
+		if preference.Disabled {
+			doneNotificationUuids = append(doneNotificationUuids, queuedNotification.UUID)
+			continue
+		}
+		if queuedNotification.Digest {
+			digestNotifications = append(digestNotifications, notification)
+			queuedDigestNotifications = append(queuedDigestNotifications, queuedNotification)
+			continue
+		}
+
+		sentChannels, err := n.send(ctx, notification, preferences, preference.Channels, queuedNotification.SentChannels)
+		if err != nil {
+			log.Error(err)
+			queuedNotification.SentChannels = sentChannels
+			failedNotifications = append(failedNotifications, queuedNotification)
+			continue
+		}
+		doneNotificationUuids = append(doneNotificationUuids, queuedNotification.UUID)
+	}
+
+	if len(digestNotifications) != 0 {
+		// the digest is only skipped on the channels all of its notifications were already sent on
+		digestSentChannels := queuedDigestNotifications[0].SentChannels
+		for _, queuedNotification := range queuedDigestNotifications[1:] {
+			digestSentChannels = intersectChannels(digestSentChannels, queuedNotification.SentChannels)
+		}
+
+		sentChannels, err := n.sendDigest(ctx, userUid, digestNotifications, preferences, digestSentChannels)
+		if err != nil {
+			log.Error(err)
+			for _, queuedNotification := range queuedDigestNotifications {
+				queuedNotification.SentChannels = unionChannels(queuedNotification.SentChannels, sentChannels)
+			}
+			failedNotifications = append(failedNotifications, queuedDigestNotifications...)
+		} else {
+			for _, queuedNotification := range queuedDigestNotifications {
+				doneNotificationUuids = append(doneNotificationUuids, queuedNotification.UUID)
+			}
+		}
+	}
+
+	retriedNotifications := []*repository.QueuedNotification{}
+	for _, failedNotification := range failedNotifications {
+		failedNotification.FailedAttempts++
+		if failedNotification.FailedAttempts >= maxQueuedNotificationAttempts {
+			log.Errorf("dropping queued %s notification '%s' of user '%s' after %d failed attempts", failedNotification.NotificationType, failedNotification.UUID, userUid, failedNotification.FailedAttempts)
+			doneNotificationUuids = append(doneNotificationUuids, failedNotification.UUID)
+			continue
+		}
+
+		failedNotification.DeliverAfter = now.Add(queuedNotificationRetryDelay << (failedNotification.FailedAttempts - 1))
+		retriedNotifications = append(retriedNotifications, failedNotification)
+	}
+
+	err = n.queueRepository.RescheduleQueuedNotifications(ctx, retriedNotifications)
+	if err != nil {
+		return err
+	}
+
+	return n.queueRepository.DeleteQueuedNotifications(ctx, doneNotificationUuids)
+}
+
+// send sends the notification on the given channels, except the channels it was already sent on. It returns the
+// channels the notification was sent on so far, also if it failed on some of them.
+func (n ChannelNotifier) send(ctx context.Context, notification Notification, preferences *repository.UserPreferences, channelTypes []string, sentChannels []string) ([]string, error) {
+	recipient, err := n.resolve(ctx, notification.UserUid, preferences)
+	if err != nil {
+		return sentChannels, err
+	}
+
+	message, err := n.templates.Render(notification, recipient.Language)
+	if err != nil {
+		return sentChannels, err
+	}
+
+	return n.sendMessage(ctx, recipient, message, channelTypes, sentChannels)
+}
+
+func (n ChannelNotifier) sendDigest(ctx context.Context, userUid string, notifications []Notification, preferences *repository.UserPreferences, sentChannels []string) ([]string, error) {
+	recipient, err := n.resolve(ctx, userUid, preferences)
+	if err != nil {
+		return sentChannels, err
+	}
+
+	message, err := n.templates.RenderDigest(notifications, recipient.Language)
+	if err != nil {
+		return sentChannels, err
+	}
+
+	return n.sendMessage(ctx, recipient, message, preferences.Digest.Channels, sentChannels)
+}
+
+func (n ChannelNotifier) resolve(ctx context.Context, userUid string, preferences *repository.UserPreferences) (Recipient, error) {
+	recipient, err := n.resolver.Resolve(ctx, userUid)
+	if err != nil {
+		return Recipient{}, errors.Wrapf(err, "failed to resolve recipient '%s' of notification", userUid)
+	}
+	if preferences.Language != "" {
+		recipient.Language = preferences.Language
+	}
+
+	return recipient, nil
+}
+
+// sendMessage sends the message on all of the given channels, or on every channel if none are given, except the
+// channels it was already sent on. A failing channel does not keep the message from being sent on the other
+// channels, the channels the message was sent on so far are returned together with the error.
+func (n ChannelNotifier) sendMessage(ctx context.Context, recipient Recipient, message Message, channelTypes []string, sentChannels []string) ([]string, error) {
+	sentChannels = append([]string{}, sentChannels...)
+	failedChannels := []string{}
+	for _, channel := range n.channels {
+		if !isChannelEnabled(channel.Type(), channelTypes) || containsChannel(sentChannels, string(channel.Type())) {
+			continue
+		}
+
+		err := channel.Send(ctx, recipient, message)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to send %s notification to user '%s' on channel %s", message.Type, recipient.UserUid, channel.Type()))
+			failedChannels = append(failedChannels, string(channel.Type()))
+			continue
+		}
+		sentChannels = append(sentChannels, string(channel.Type()))
+	}
+
+	if len(failedChannels) != 0 {
+		return sentChannels, fmt.Errorf("failed to send %s notification to user '%s' on channels %s", message.Type, recipient.UserUid, strings.Join(failedChannels, ", "))
+	}
+
+	return sentChannels, nil
+}
+
+func containsChannel(channels []string, channelType string) bool {
+	for _, channel := range channels {
+		if channel == channelType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func intersectChannels(channels []string, otherChannels []string) []string {
+	intersection := []string{}
+	for _, channel := range channels {
+		if containsChannel(otherChannels, channel) {
+			intersection = append(intersection, channel)
+		}
+	}
+
+	return intersection
+}
+
+func unionChannels(channels []string, otherChannels []string) []string {
+	union := append([]string{}, channels...)
+	for _, channel := range otherChannels {
+		if !containsChannel(union, channel) {
+			union = append(union, channel)
+		}
+	}
+
+	return union
+}
+
+func isChannelEnabled(channelType ChannelType, channelTypes []string) bool {
+	if len(channelTypes) == 0 {
+		return true
+	}
+
+	for _, enabledChannelType := range channelTypes {
+		if enabledChannelType == string(channelType) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+)
+
+// preferencesLocation is the timezone of the user, falling back to UTC for unknown or missing timezones
+func preferencesLocation(preferences *repository.UserPreferences) *time.Location {
+	if preferences.Timezone == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(preferences.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// deliveryTime decides when a notification is sent. It returns the zero time for notifications which are
+// sent right away, otherwise the time after which the queued notification is sent and if it goes into the digest.
+func deliveryTime(notificationType NotificationType, preference repository.NotificationPreference, digest repository.DigestPreference, now time.Time) (time.Time, bool) {
+	if digest.Enabled && !notificationType.IsUrgent() {
+		digestTime, ok := nextOccurrence(now, digest.Time)
+		if ok {
+			return digestTime, true
+		}
+	}
+
+	if preference.QuietHours != nil && IsInQuietHours(*preference.QuietHours, now) {
+		quietHoursEnd, ok := nextOccurrence(now, preference.QuietHours.End)
+		if ok {
+			return quietHoursEnd, false
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// IsInQuietHours checks if the time of day of t is within the quiet hours, which may span midnight
+func IsInQuietHours(quietHours repository.QuietHours, t time.Time) bool {
+	start, err := time.Parse("15:04", quietHours.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", quietHours.End)
+	if err != nil {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+
+	return minute >= startMinute || minute < endMinute
+}
+
+// nextOccurrence returns the next time after t at the given time of day (HH:MM) in the location of t
+func nextOccurrence(t time.Time, timeOfDay string) (time.Time, bool) {
+	parsed, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), parsed.Hour(), parsed.Minute(), 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next, true
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// PushSender delivers a message to devices and reports the tokens the push service doesn't know (anymore)
+type PushSender interface {
+	Send(ctx context.Context, tokens []string, message Message) (invalidTokens []string, err error)
+}
+
+// TokenPruner removes the devices of push tokens which are not valid anymore
+type TokenPruner interface {
+	DeleteDevicesByTokens(ctx context.Context, tokens []string) error
+}
+
+// PushChannel sends notifications as push messages to the devices of the recipient which didn't mute the notification type
+type PushChannel struct {
+	sender PushSender
+	pruner TokenPruner
+}
+
+func NewPushChannel(sender PushSender, pruner TokenPruner) Channel {
+	return PushChannel{sender, pruner}
+}
+
+func (c PushChannel) Type() ChannelType {
+	return CHANNEL_TYPE_PUSH
+}
+
+func (c PushChannel) Send(ctx context.Context, recipient Recipient, message Message) error {
+	tokens := []string{}
+	for _, device := range recipient.PushDevices {
+		if !device.IsMuted(message.Type) {
+			tokens = append(tokens, device.Token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	invalidTokens, err := c.sender.Send(ctx, tokens, message)
+	if len(invalidTokens) != 0 {
+		pruneErr := c.pruner.DeleteDevicesByTokens(ctx, invalidTokens)
+		if pruneErr != nil {
+			log.Error(errors.Wrap(pruneErr, "failed to prune invalid push tokens"))
+		}
+	}
+
+	return err
+}
+
+// maxTokensPerMulticast is the maximum number of tokens Firebase Cloud Messaging accepts for one multicast message
+const maxTokensPerMulticast = 500
+
+type FCMPushSender struct {
+	messagingClient *messaging.Client
+}
+
+func NewFCMPushSender(messagingClient *messaging.Client) PushSender {
+	return FCMPushSender{messagingClient}
+}
+
+func (s FCMPushSender) Send(ctx context.Context, tokens []string, message Message) ([]string, error) {
+	invalidTokens := []string{}
+	for start := 0; start < len(tokens); start += maxTokensPerMulticast {
+		end := start + maxTokensPerMulticast
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		data := map[string]string{"type": string(message.Type)}
+		for key, value := range message.Data {
+			data[key] = value
+		}
+
+		response, err := s.messagingClient.SendMulticast(ctx, &messaging.MulticastMessage{
+			Tokens: tokens[start:end],
+			Data:   data,
+			Notification: &messaging.Notification{
+				Title: message.Title,
+				Body:  message.Body,
+			},
+		})
+		if err != nil {
+			return invalidTokens, errors.Wrap(err, "failed to send push message")
+		}
+
+		for index, tokenResponse := range response.Responses {
+			if tokenResponse.Error != nil && (messaging.IsRegistrationTokenNotRegistered(tokenResponse.Error) || messaging.IsInvalidArgument(tokenResponse.Error)) {
+				invalidTokens = append(invalidTokens, tokens[start+index])
+			}
+		}
+	}
+
+	return invalidTokens, nil
+}
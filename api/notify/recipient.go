@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+// FirebaseRecipientResolver looks up the email address of users in Firebase Authentication
+// and their devices registered for push messages
+type FirebaseRecipientResolver struct {
+	authClient       *auth.Client
+	deviceRepository repository.DeviceRepository
+	defaultLanguage  string
+}
+
+func NewFirebaseRecipientResolver(authClient *auth.Client, deviceRepository repository.DeviceRepository, defaultLanguage string) RecipientResolver {
+	return FirebaseRecipientResolver{authClient, deviceRepository, defaultLanguage}
+}
+
+func (r FirebaseRecipientResolver) Resolve(ctx context.Context, userUid string) (Recipient, error) {
+	user, err := r.authClient.GetUser(ctx, userUid)
+	if err != nil {
+		return Recipient{}, errors.Wrapf(err, "failed to get user '%s'", userUid)
+	}
+
+	devices, err := r.deviceRepository.GetDevices(ctx, userUid)
+	if err != nil {
+		return Recipient{}, errors.Wrapf(err, "failed to get devices of user '%s'", userUid)
+	}
+
+	return Recipient{
+		UserUid:     userUid,
+		Email:       user.Email,
+		Language:    r.defaultLanguage,
+		PushDevices: pushDevices(devices),
+	}, nil
+}
+
+// pushDevices collects the devices which can be reached by the push sender, which currently is Firebase Cloud Messaging only
+func pushDevices(devices []*repository.Device) []PushDevice {
+	pushDevices := []PushDevice{}
+	for _, device := range devices {
+		if device.Provider != repository.PUSH_TOKEN_PROVIDER_FCM {
+			continue
+		}
+
+		mutedTypes := []NotificationType{}
+		for _, mutedType := range device.MutedNotificationTypes {
+			mutedTypes = append(mutedTypes, NotificationType(mutedType))
+		}
+		pushDevices = append(pushDevices, PushDevice{Token: device.Token, MutedTypes: mutedTypes})
+	}
+
+	return pushDevices
+}
+
+// StaticRecipientResolver resolves every user without any contact details, it is used together with the local sinks
+type StaticRecipientResolver struct {
+	defaultLanguage string
+}
+
+func NewStaticRecipientResolver(defaultLanguage string) RecipientResolver {
+	return StaticRecipientResolver{defaultLanguage}
+}
+
+func (r StaticRecipientResolver) Resolve(ctx context.Context, userUid string) (Recipient, error) {
+	return Recipient{UserUid: userUid, Language: r.defaultLanguage}, nil
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogChannel writes notifications to the log, it is meant for local development
+type LogChannel struct{}
+
+func NewLogChannel() Channel {
+	return LogChannel{}
+}
+
+func (c LogChannel) Type() ChannelType {
+	return CHANNEL_TYPE_LOG
+}
+
+func (c LogChannel) Send(ctx context.Context, recipient Recipient, message Message) error {
+	log.WithFields(log.Fields{
+		"userUid":  recipient.UserUid,
+		"language": recipient.Language,
+		"type":     message.Type,
+	}).Infof("notification: %s - %s", message.Title, message.Body)
+
+	return nil
+}
+
+type fileSinkEntry struct {
+	SentAt    time.Time `json:"sentAt"`
+	Recipient Recipient `json:"recipient"`
+	Message   Message   `json:"message"`
+}
+
+// FileChannel appends notifications as JSON lines to a file, it is meant for local development
+type FileChannel struct {
+	path  string
+	mutex *sync.Mutex
+}
+
+func NewFileChannel(path string) Channel {
+	return FileChannel{path: path, mutex: &sync.Mutex{}}
+}
+
+func (c FileChannel) Type() ChannelType {
+	return CHANNEL_TYPE_LOG
+}
+
+func (c FileChannel) Send(ctx context.Context, recipient Recipient, message Message) error {
+	entry, err := json.Marshal(fileSinkEntry{SentAt: time.Now(), Recipient: recipient, Message: message})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal notification for file sink")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	file, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open notification file sink '%s'", c.path)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(entry, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "failed to write notification to file sink '%s'", c.path)
+	}
+
+	return nil
+}
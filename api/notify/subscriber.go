@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+)
+
+// shareExpiryNotice is the time before the end of a time-boxed share at which the sitter gets notified
+const shareExpiryNotice = 24 * time.Hour
+
+// SubscribeToEvents sends the notifications caused by domain events
+func SubscribeToEvents(bus *events.Bus, notifier Notifier, petRepository repository.PetRepository) {
+	events.Subscribe(bus, "share invite notifications", func(ctx context.Context, event events.ShareInvited) error {
+		return notifier.Notify(ctx, Notification{
+			Type:    NOTIFICATION_TYPE_SHARE_INVITE,
+			UserUid: event.InviteeUid,
+			Data: map[string]string{
+				"PetUuid": event.PetUuid,
+				"PetName": event.Pet.Name,
+			},
+		})
+	})
+
+	// sitters are reminded a day before their access to the pet ends
+	events.Subscribe(bus, "share expiry notifications", func(ctx context.Context, event events.ShareAccepted) error {
+		if event.Share.ValidUntil == nil {
+			return nil
+		}
+
+		return notifier.Notify(ctx, Notification{
+			Type:    NOTIFICATION_TYPE_SHARE_EXPIRY,
+			UserUid: event.ActorUid,
+			Data: map[string]string{
+				"PetUuid":    event.PetUuid,
+				"PetName":    event.Pet.Name,
+				"ValidUntil": event.Share.ValidUntil.Format(time.RFC1123),
+			},
+			SendAfter: event.Share.ValidUntil.Add(-shareExpiryNotice),
+		})
+	})
+
+	events.Subscribe(bus, "low stock notifications", func(ctx context.Context, event events.StockLow) error {
+		pet, caretakers, err := petRepository.GetPetCaretakers(ctx, event.PetUuid)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get caretakers of pet '%s' for low stock notification", event.PetUuid)
+		}
+
+		// the notifications are identified by the event and the caretaker, so a retry of the event only notifies the
+		// caretakers whose notification failed
+		failedNotifications := []string{}
+		for _, caretaker := range caretakers {
+			err := notifier.Notify(ctx, Notification{
+				ID:      event.ID + "/" + caretaker,
+				Type:    NOTIFICATION_TYPE_LOW_STOCK,
+				UserUid: caretaker,
+				Data: map[string]string{
+					"PetUuid":  event.PetUuid,
+					"PetName":  pet.Name,
+					"Name":     event.ResourceName,
+					"Stock":    fmt.Sprint(event.Stock),
+					"Unit":     event.Unit,
+					"DaysLeft": fmt.Sprint(int(event.DaysLeft)),
+				},
+			})
+			if err != nil {
+				failedNotifications = append(failedNotifications, err.Error())
+			}
+		}
+
+		if len(failedNotifications) != 0 {
+			return fmt.Errorf("failed to send low stock notification of pet '%s' to %d caretakers: %s", event.PetUuid, len(failedNotifications), strings.Join(failedNotifications, "; "))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultLanguage = "en"
+
+type messageTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// Templates holds the localised title and body templates of every notification type
+type Templates struct {
+	templates map[string]map[NotificationType]messageTemplate
+}
+
+var templateTexts = map[string]map[NotificationType][2]string{
+	"en": {
+		NOTIFICATION_TYPE_SHARE_INVITE: {
+			"New pet share invite",
+			"You were invited to care for {{.PetName}}.",
+		},
+		NOTIFICATION_TYPE_DOSE_DUE: {
+			"{{.PetName}} needs {{.MedicineName}}",
+			"It's {{.Time}}, time to give {{.PetName}} {{.Dosage}} {{.Unit}} of {{.MedicineName}}.",
+		},
+		NOTIFICATION_TYPE_LOW_STOCK: {
+			"{{.Name}} is running low",
+			"Only {{.Stock}} {{.Unit}} of {{.Name}} for {{.PetName}} are left, that's enough for about {{.DaysLeft}} days.",
+		},
+		NOTIFICATION_TYPE_MISSED_DOSE: {
+			"{{.PetName}} may have missed {{.MedicineName}}",
+			"The dose of {{.MedicineName}} for {{.PetName}} due at {{.Time}} has not been marked as done yet.",
+		},
+		NOTIFICATION_TYPE_SHARE_EXPIRY: {
+			"Your access to {{.PetName}} ends soon",
+			"You can care for {{.PetName}} until {{.ValidUntil}}.",
+		},
+		NOTIFICATION_TYPE_DIGEST: {
+			"Your daily summary",
+			"There are {{.Count}} new notifications for you:",
+		},
+	},
+	"de": {
+		NOTIFICATION_TYPE_SHARE_INVITE: {
+			"Neue Einladung",
+			"Du wurdest eingeladen, dich um {{.PetName}} zu kümmern.",
+		},
+		NOTIFICATION_TYPE_DOSE_DUE: {
+			"{{.PetName}} braucht {{.MedicineName}}",
+			"Es ist {{.Time}}, Zeit {{.PetName}} {{.Dosage}} {{.Unit}} {{.MedicineName}} zu geben.",
+		},
+		NOTIFICATION_TYPE_LOW_STOCK: {
+			"{{.Name}} geht zur Neige",
+			"Nur noch {{.Stock}} {{.Unit}} {{.Name}} für {{.PetName}} übrig, das reicht noch etwa {{.DaysLeft}} Tage.",
+		},
+		NOTIFICATION_TYPE_MISSED_DOSE: {
+			"Hat {{.PetName}} {{.MedicineName}} bekommen?",
+			"Die Gabe von {{.MedicineName}} für {{.PetName}} um {{.Time}} wurde noch nicht als erledigt markiert.",
+		},
+		NOTIFICATION_TYPE_SHARE_EXPIRY: {
+			"Dein Zugriff auf {{.PetName}} endet bald",
+			"Du kannst dich noch bis {{.ValidUntil}} um {{.PetName}} kümmern.",
+		},
+		NOTIFICATION_TYPE_DIGEST: {
+			"Deine tägliche Zusammenfassung",
+			"Es gibt {{.Count}} neue Benachrichtigungen für dich:",
+		},
+	},
+}
+
+// IsLanguage checks if there are templates for the language
+func IsLanguage(language string) bool {
+	_, ok := templateTexts[language]
+	return ok
+}
+
+func NewTemplates() (*Templates, error) {
+	templates := &Templates{templates: map[string]map[NotificationType]messageTemplate{}}
+
+	for language, languageTexts := range templateTexts {
+		templates.templates[language] = map[NotificationType]messageTemplate{}
+		for notificationType, texts := range languageTexts {
+			title, err := template.New(string(notificationType) + "Title").Option("missingkey=zero").Parse(texts[0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %s title template for language '%s'", notificationType, language)
+			}
+			body, err := template.New(string(notificationType) + "Body").Option("missingkey=zero").Parse(texts[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %s body template for language '%s'", notificationType, language)
+			}
+			templates.templates[language][notificationType] = messageTemplate{title, body}
+		}
+	}
+
+	return templates, nil
+}
+
+// Render renders the notification in the given language, falling back to the default language
+func (t *Templates) Render(notification Notification, language string) (Message, error) {
+	languageTemplates, ok := t.templates[language]
+	if !ok {
+		languageTemplates = t.templates[DefaultLanguage]
+	}
+
+	messageTemplate, ok := languageTemplates[notification.Type]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for notification type '%s'", notification.Type)
+	}
+
+	var title, body bytes.Buffer
+	err := messageTemplate.title.Execute(&title, notification.Data)
+	if err != nil {
+		return Message{}, errors.Wrapf(err, "failed to render title of %s notification", notification.Type)
+	}
+	err = messageTemplate.body.Execute(&body, notification.Data)
+	if err != nil {
+		return Message{}, errors.Wrapf(err, "failed to render body of %s notification", notification.Type)
+	}
+
+	return Message{
+		Type:  notification.Type,
+		Title: title.String(),
+		Body:  body.String(),
+		Data:  notification.Data,
+	}, nil
+}
+
+// RenderDigest renders the notifications into one digest message, listing the title and body of every notification
+func (t *Templates) RenderDigest(notifications []Notification, language string) (Message, error) {
+	digest, err := t.Render(Notification{
+		Type: NOTIFICATION_TYPE_DIGEST,
+		Data: map[string]string{"Count": fmt.Sprint(len(notifications))},
+	}, language)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var body strings.Builder
+	body.WriteString(digest.Body)
+	for _, notification := range notifications {
+		message, err := t.Render(notification, language)
+		if err != nil {
+			return Message{}, err
+		}
+		fmt.Fprintf(&body, "\n\n%s\n%s", message.Title, message.Body)
+	}
+	digest.Body = body.String()
+
+	return digest, nil
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type webhookPayload struct {
+	UserUid string    `json:"userUid"`
+	SentAt  time.Time `json:"sentAt"`
+	Message Message   `json:"message"`
+}
+
+// WebhookChannel posts notifications as JSON to a configured URL
+type WebhookChannel struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewWebhookChannel(url string) Channel {
+	return WebhookChannel{url: url, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c WebhookChannel) Type() ChannelType {
+	return CHANNEL_TYPE_WEBHOOK
+}
+
+func (c WebhookChannel) Send(ctx context.Context, recipient Recipient, message Message) error {
+	payload, err := json.Marshal(webhookPayload{
+		UserUid: recipient.UserUid,
+		SentAt:  time.Now(),
+		Message: message,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal webhook payload")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "failed to create webhook request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post notification to webhook '%s'", c.url)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook '%s' answered with status %d", c.url, response.StatusCode)
+	}
+
+	return nil
+}
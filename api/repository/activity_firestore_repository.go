@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const activitiesCollection = "activities"
+
+// writeActivity appends the activity to the pet in the transaction which changes the document. Before is nil for a
+// created document and after is nil for a deleted one, their fields are compared with the empty values then.
+func writeActivity(firestoreClient *firestore.Client, tx *firestore.Transaction, activity *Activity, before interface{}, after interface{}) error {
+	changes, err := DocumentChanges(before, after)
+	if err != nil {
+		return errors.Wrapf(err, "failed to compare %s '%s' for the activity of pet '%s'", activity.Kind, activity.DocumentUUID, activity.PetUUID)
+	}
+	activity.Changes = changes
+
+	err = tx.Create(firestoreClient.Collection(activitiesCollection).Doc(activity.UUID.String()), activity)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write activity of pet '%s'", activity.PetUUID)
+	}
+
+	return nil
+}
+
+// DocumentChanges compares the fields of two documents, both have to be pointers to the same versioned struct. The
+// fields of a nil document are compared as empty values.
+func DocumentChanges(before interface{}, after interface{}) ([]ActivityChange, error) {
+	documentType := reflect.TypeOf(after)
+	if documentType == nil {
+		documentType = reflect.TypeOf(before)
+	}
+	if documentType == nil {
+		return []ActivityChange{}, nil
+	}
+	documentType = documentType.Elem()
+	beforeValue := documentValue(before, documentType)
+	afterValue := documentValue(after, documentType)
+
+	changes := []ActivityChange{}
+	for index := 0; index < documentType.NumField(); index++ {
+		field := documentType.Field(index)
+		if !field.IsExported() || untrackedFields[field.Name] {
+			continue
+		}
+
+		beforeField, err := activityValue(beforeValue.Field(index))
+		if err != nil {
+			return nil, err
+		}
+		afterField, err := activityValue(afterValue.Field(index))
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(beforeField, afterField) {
+			changes = append(changes, ActivityChange{Field: fieldName(field), Before: beforeField, After: afterField})
+		}
+	}
+
+	return changes, nil
+}
+
+func documentValue(document interface{}, documentType reflect.Type) reflect.Value {
+	value := reflect.ValueOf(document)
+	if !value.IsValid() || value.IsNil() {
+		return reflect.New(documentType).Elem()
+	}
+
+	return value.Elem()
+}
+
+// activityValue converts the field to its JSON value, so UUIDs, times and nested structs are stored as in the API
+func activityValue(field reflect.Value) (interface{}, error) {
+	encodedField, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal(encodedField, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+type ActivityFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewActivityFirestoreRepository(firestoreClient *firestore.Client) ActivityRepository {
+	return ActivityFirestoreRepository{firestoreClient}
+}
+
+func (r ActivityFirestoreRepository) activitiesCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection(activitiesCollection)
+}
+
+// GetActivitiesForPet loads a page of the activities of the pet, newest first. The cursor is the UUID of the last
+// activity of the previous page, it is empty for the first page.
+func (r ActivityFirestoreRepository) GetActivitiesForPet(ctx context.Context, petUuid string, cursor string, limit int) (*ActivityPage, error) {
+	query := r.activitiesCollection().
+		Where("petUuid", "==", petUuid).
+		OrderBy("createdAt", firestore.Desc)
+
+	if cursor != "" {
+		cursorDocument, err := r.activitiesCollection().Doc(cursor).Get(ctx)
+		if status.Code(err) == codes.NotFound {
+			return nil, NewValidationError("invalid_cursor", errors.Wrapf(err, "no activity with UUID '%s' to continue after", cursor))
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get activity with UUID '%s' to continue after", cursor)
+		}
+
+		cursorActivity, err := r.unmarshalActivity(cursorDocument)
+		if err != nil {
+			return nil, err
+		}
+		if cursorActivity.PetUUID.String() != petUuid {
+			return nil, NewValidationError("invalid_cursor", errors.Errorf("activity '%s' is not an activity of pet '%s'", cursor, petUuid))
+		}
+
+		query = query.StartAfter(cursorDocument)
+	}
+
+	// one more activity than requested is loaded to know if there is a next page
+	activityDocuments, err := query.Limit(limit + 1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get activities of pet %s", petUuid)
+	}
+
+	page := &ActivityPage{Activities: []*Activity{}}
+	for index, activityDocument := range activityDocuments {
+		if index == limit {
+			page.NextCursor = page.Activities[limit-1].UUID.String()
+			break
+		}
+
+		activity, err := r.unmarshalActivity(activityDocument)
+		if err != nil {
+			return nil, err
+		}
+		page.Activities = append(page.Activities, activity)
+	}
+
+	return page, nil
+}
+
+func (r ActivityFirestoreRepository) unmarshalActivity(doc *firestore.DocumentSnapshot) (*Activity, error) {
+	ActivityModel := Activity{}
+	err := doc.DataTo(&ActivityModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to activity")
+	}
+
+	return &ActivityModel, nil
+}
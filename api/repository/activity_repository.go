@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ActivityKind string
+
+const (
+	ACTIVITY_KIND_PET      ActivityKind = "Pet"
+	ACTIVITY_KIND_MEDICINE ActivityKind = "Medicine"
+	ACTIVITY_KIND_FOOD     ActivityKind = "Food"
+)
+
+type ActivityAction string
+
+const (
+	ACTIVITY_ACTION_CREATED  ActivityAction = "Created"
+	ACTIVITY_ACTION_UPDATED  ActivityAction = "Updated"
+	ACTIVITY_ACTION_DELETED  ActivityAction = "Deleted"
+	ACTIVITY_ACTION_RESTORED ActivityAction = "Restored"
+)
+
+// ActivityChange is the change of a single field, the values are the same as in the JSON of the API
+type ActivityChange struct {
+	Field  string      `firestore:"field" json:"field"`
+	Before interface{} `firestore:"before" json:"before"`
+	After  interface{} `firestore:"after" json:"after"`
+}
+
+// Activity records who created, changed or deleted the pet or one of its medicines or foods. Activities are only
+// appended, they are removed together with the pet when it is purged from the trash.
+type Activity struct {
+	UUID         uuid.UUID        `firestore:"uuid" json:"uuid"`
+	PetUUID      uuid.UUID        `firestore:"petUuid" json:"petUuid"`
+	Kind         ActivityKind     `firestore:"kind" json:"kind"`
+	DocumentUUID uuid.UUID        `firestore:"documentUuid" json:"documentUuid"`
+	Action       ActivityAction   `firestore:"action" json:"action"`
+	ActorUID     string           `firestore:"actorUid" json:"actorUid"`
+	CreatedAt    time.Time        `firestore:"createdAt" json:"createdAt"`
+	Changes      []ActivityChange `firestore:"changes" json:"changes"`
+}
+
+func newActivity(kind ActivityKind, action ActivityAction, documentUuid uuid.UUID, petUuid uuid.UUID, actorUid string) *Activity {
+	return &Activity{
+		UUID:         uuid.New(),
+		PetUUID:      petUuid,
+		Kind:         kind,
+		DocumentUUID: documentUuid,
+		Action:       action,
+		ActorUID:     actorUid,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// ActivityPage is a page of the activities of a pet, newest first. NextCursor is empty on the last page, otherwise
+// it has to be sent to get the next page.
+type ActivityPage struct {
+	Activities []*Activity `json:"activities"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+type ActivityRepository interface {
+	GetActivitiesForPet(ctx context.Context, petUuid string, cursor string, limit int) (*ActivityPage, error)
+}
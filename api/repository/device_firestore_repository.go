@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type DeviceFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewDeviceFirestoreRepository(firestoreClient *firestore.Client) DeviceRepository {
+	return DeviceFirestoreRepository{firestoreClient}
+}
+
+func (r DeviceFirestoreRepository) devicesCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("devices")
+}
+
+// deviceID derives the document ID from the push token, so a token can only be registered once
+func deviceID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// RegisterDevice adds the device or updates it if the token is already known. A token which was registered
+// by another user before, e.g. after logging out and in with another account, is taken over by the user.
+func (r DeviceFirestoreRepository) RegisterDevice(ctx context.Context, userUid string, device *Device) ([]*Device, error) {
+	device.ID = deviceID(device.Token)
+	device.UserUID = userUid
+	device.LastSeen = time.Now()
+	if device.MutedNotificationTypes == nil {
+		device.MutedNotificationTypes = []string{}
+	}
+
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.devicesCollection().Doc(device.ID)
+
+		firestoreDevice, err := tx.Get(documentRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return errors.Wrap(err, "unable to get device document for registration")
+		}
+
+		device.RegisteredAt = device.LastSeen
+		if err == nil {
+			existingDevice, err := r.unmarshalDevice(firestoreDevice)
+			if err != nil {
+				return err
+			}
+			if existingDevice.UserUID == userUid {
+				device.RegisteredAt = existingDevice.RegisteredAt
+			}
+		}
+
+		return tx.Set(documentRef, device)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to register device")
+	}
+
+	userDevices, err := r.GetDevices(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's devices after device was registered")
+	}
+
+	return userDevices, nil
+}
+
+func (r DeviceFirestoreRepository) GetDevices(ctx context.Context, userUid string) ([]*Device, error) {
+	deviceDocuments, err := r.devicesCollection().Where("userUid", "==", userUid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get all devices for user")
+	}
+
+	devices := []*Device{}
+	for _, device := range deviceDocuments {
+		unmarshaledDevice, err := r.unmarshalDevice(device)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, unmarshaledDevice)
+	}
+
+	return devices, nil
+}
+
+func (r DeviceFirestoreRepository) DeleteDevice(ctx context.Context, userUid string, token string) ([]*Device, error) {
+	documentRef := r.devicesCollection().Doc(deviceID(token))
+
+	firestoreDevice, err := documentRef.Get(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load device before deletion")
+	}
+
+	device, err := r.unmarshalDevice(firestoreDevice)
+	if err != nil {
+		return nil, err
+	}
+	if device.UserUID != userUid {
+		return nil, NewNotFoundError("device_not_found", fmt.Errorf("device '%s' is not registered for user '%s'", device.ID, userUid))
+	}
+
+	_, err = documentRef.Delete(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete device '%s'", device.ID)
+	}
+
+	userDevices, err := r.GetDevices(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's devices after device was deleted")
+	}
+
+	return userDevices, nil
+}
+
+// DeleteDevicesByTokens removes the devices of tokens which the push service reported as invalid
+func (r DeviceFirestoreRepository) DeleteDevicesByTokens(ctx context.Context, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	batch := r.firestoreClient.Batch()
+	for _, token := range tokens {
+		batch.Delete(r.devicesCollection().Doc(deviceID(token)))
+	}
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete %d devices with invalid tokens", len(tokens))
+	}
+
+	return nil
+}
+
+func (r DeviceFirestoreRepository) unmarshalDevice(doc *firestore.DocumentSnapshot) (*Device, error) {
+	DeviceModel := Device{}
+	err := doc.DataTo(&DeviceModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to device")
+	}
+
+	return &DeviceModel, nil
+}
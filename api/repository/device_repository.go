@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+type DevicePlatform string
+
+const (
+	DEVICE_PLATFORM_ANDROID DevicePlatform = "Android"
+	DEVICE_PLATFORM_IOS     DevicePlatform = "iOS"
+	DEVICE_PLATFORM_WEB     DevicePlatform = "Web"
+)
+
+type PushTokenProvider string
+
+const (
+	PUSH_TOKEN_PROVIDER_FCM  PushTokenProvider = "FCM"
+	PUSH_TOKEN_PROVIDER_APNS PushTokenProvider = "APNs"
+)
+
+type Device struct {
+	ID           string            `firestore:"id" json:"id"`
+	UserUID      string            `firestore:"userUid" json:"userUid"`
+	Token        string            `firestore:"token" json:"token"`
+	Provider     PushTokenProvider `firestore:"provider" json:"provider"`
+	Platform     DevicePlatform    `firestore:"platform" json:"platform"`
+	AppVersion   string            `firestore:"appVersion" json:"appVersion"`
+	RegisteredAt time.Time         `firestore:"registeredAt" json:"registeredAt"`
+	LastSeen     time.Time         `firestore:"lastSeen" json:"lastSeen"`
+	// MutedNotificationTypes are the types of notifications the user doesn't want to receive on this device
+	MutedNotificationTypes []string `firestore:"mutedNotificationTypes" json:"mutedNotificationTypes"`
+}
+
+type RegisterDeviceRequest struct {
+	Token                  string            `json:"token"`
+	Provider               PushTokenProvider `json:"provider"`
+	Platform               DevicePlatform    `json:"platform"`
+	AppVersion             string            `json:"appVersion"`
+	MutedNotificationTypes []string          `json:"mutedNotificationTypes"`
+}
+
+type UnregisterDeviceRequest struct {
+	Token string `json:"token"`
+}
+
+type DeviceRepository interface {
+	RegisterDevice(ctx context.Context, userUid string, device *Device) ([]*Device, error)
+	GetDevices(ctx context.Context, userUid string) ([]*Device, error)
+	DeleteDevice(ctx context.Context, userUid string, token string) ([]*Device, error)
+	DeleteDevicesByTokens(ctx context.Context, tokens []string) error
+}
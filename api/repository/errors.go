@@ -0,0 +1,99 @@
+package repository
+
+type ErrorKind string
+
+const (
+	ERROR_KIND_NOT_FOUND           ErrorKind = "NotFound"
+	ERROR_KIND_FORBIDDEN           ErrorKind = "Forbidden"
+	ERROR_KIND_CONFLICT            ErrorKind = "Conflict"
+	ERROR_KIND_VALIDATION          ErrorKind = "Validation"
+	ERROR_KIND_PRECONDITION_FAILED ErrorKind = "PreconditionFailed"
+	ERROR_KIND_TOO_MANY_REQUESTS   ErrorKind = "TooManyRequests"
+)
+
+// DomainError is an error the API reports to the client with the status of its kind and a machine-readable code.
+// It is found in the chain of wrapped errors, so it may be wrapped for context on the way up.
+type DomainError interface {
+	error
+	Kind() ErrorKind
+	Code() string
+}
+
+type kindError struct {
+	kind ErrorKind
+	code string
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Kind() ErrorKind {
+	return e.kind
+}
+
+func (e *kindError) Code() string {
+	return e.code
+}
+
+// NewNotFoundError marks the error as a missing resource
+func NewNotFoundError(code string, err error) error {
+	return &kindError{ERROR_KIND_NOT_FOUND, code, err}
+}
+
+// NewForbiddenError marks the error as a resource the user has no access to
+func NewForbiddenError(code string, err error) error {
+	return &kindError{ERROR_KIND_FORBIDDEN, code, err}
+}
+
+// NewConflictError marks the error as a request which conflicts with the current state of a resource
+func NewConflictError(code string, err error) error {
+	return &kindError{ERROR_KIND_CONFLICT, code, err}
+}
+
+// NewValidationError marks the error as invalid input of the client
+func NewValidationError(code string, err error) error {
+	return &kindError{ERROR_KIND_VALIDATION, code, err}
+}
+
+// NewTooManyRequestsError marks the error as a limit the user reached
+func NewTooManyRequestsError(code string, err error) error {
+	return &kindError{ERROR_KIND_TOO_MANY_REQUESTS, code, err}
+}
+
+func (e *NoAccessToPetError) Kind() ErrorKind {
+	return ERROR_KIND_FORBIDDEN
+}
+
+func (e *NoAccessToPetError) Code() string {
+	return "pet_access_denied"
+}
+
+func (e *NoAccessToHouseholdError) Kind() ErrorKind {
+	return ERROR_KIND_FORBIDDEN
+}
+
+func (e *NoAccessToHouseholdError) Code() string {
+	return "household_access_denied"
+}
+
+func (e *NoAccessToWebhookError) Kind() ErrorKind {
+	return ERROR_KIND_FORBIDDEN
+}
+
+func (e *NoAccessToWebhookError) Code() string {
+	return "webhook_access_denied"
+}
+
+func (e *PreconditionFailedError) Kind() ErrorKind {
+	return ERROR_KIND_PRECONDITION_FAILED
+}
+
+func (e *PreconditionFailedError) Code() string {
+	return "precondition_failed"
+}
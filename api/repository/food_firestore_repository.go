@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
@@ -21,19 +22,38 @@ func (r FoodFirestoreRepository) foodsCollection() *firestore.CollectionRef {
 }
 
 func (r FoodFirestoreRepository) AddFood(ctx context.Context, userUid string, petUuid string, food *Food) ([]*Food, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	collection := r.foodsCollection()
 
-	foodUUID := uuid.New()
-	food.UUID = foodUUID
+	// the UUID may be chosen by the client, e.g. for a food created while it was offline
+	if food.UUID == uuid.Nil {
+		food.UUID = uuid.New()
+	}
+	foodUUID := food.UUID
 	food.UserUID = userUid
 	petUUID, err := uuid.Parse(petUuid)
 	if err != nil {
-		return nil, err
+		return nil, NewValidationError("invalid_uuid", errors.Wrapf(err, "invalid pet UUID '%s'", petUuid))
 	}
 	food.PetUUID = petUUID
+	stampCreated(food, time.Now())
 
 	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		return tx.Create(collection.Doc(foodUUID.String()), food)
+		err := tx.Create(collection.Doc(foodUUID.String()), food)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_FOOD, ACTIVITY_ACTION_CREATED, food.UUID, food.PetUUID, userUid), nil, food)
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to add food")
@@ -47,16 +67,37 @@ func (r FoodFirestoreRepository) AddFood(ctx context.Context, userUid string, pe
 	return petFoods, nil
 }
 
-func (r FoodFirestoreRepository) GetFood(ctx context.Context, userUid string, foodUUID string) (*Food, error) {
+// GetFood loads the food of the pet, the food of another pet is reported as not found
+func (r FoodFirestoreRepository) GetFood(ctx context.Context, userUid string, petUuid string, foodUUID string) (*Food, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	firestoreFood, err := r.foodsCollection().Doc(foodUUID).Get(ctx)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get pet food with UUID '%s'", foodUUID)
+		return nil, petDocumentLoadError("food", petUuid, foodUUID, err)
+	}
+
+	food, err := r.unmarshalFood(firestoreFood)
+	if err != nil {
+		return nil, err
 	}
 
-	return r.unmarshalFood(firestoreFood)
+	err = checkPetDocument("food", petUuid, foodUUID, food.PetUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return food, nil
 }
 
 func (r FoodFirestoreRepository) GetFoods(ctx context.Context, userUid string, petUuid string) ([]*Food, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	allPetFoodDocuments, err := r.foodsCollection().Where("petUuid", "==", petUuid).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get all foods for pet %s", petUuid)
@@ -74,30 +115,85 @@ func (r FoodFirestoreRepository) GetFoods(ctx context.Context, userUid string, p
 	return allPetFoods, nil
 }
 
-func (r FoodFirestoreRepository) UpdateFood(ctx context.Context, userUid string, foodUUID string, updateFn func(ctx context.Context, food *Food) (*Food, error)) ([]*Food, error) {
-	var petUuid string
+// GetFoodsChangedSince loads the foods of the pet which were created or changed after the given time
+func (r FoodFirestoreRepository) GetFoodsChangedSince(ctx context.Context, userUid string, petUuid string, since time.Time) ([]*Food, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	changedFoodDocuments, err := r.foodsCollection().
+		Where("petUuid", "==", petUuid).
+		Where("updatedAt", ">", since).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get changed foods for pet %s", petUuid)
+	}
+
+	changedFoods := []*Food{}
+	for _, food := range changedFoodDocuments {
+		unmarshaledFood, err := r.unmarshalFood(food)
+		if err != nil {
+			return nil, err
+		}
+		changedFoods = append(changedFoods, unmarshaledFood)
+	}
+
+	return changedFoods, nil
+}
+
+func (r FoodFirestoreRepository) UpdateFood(ctx context.Context, userUid string, petUuid string, foodUUID string, updateFn func(ctx context.Context, food *Food) (*Food, error)) ([]*Food, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	foodsCollection := r.foodsCollection()
 
-	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		documentRef := foodsCollection.Doc(foodUUID)
 
 		firestoreFood, err := tx.Get(documentRef)
 		if err != nil {
-			return errors.Wrap(err, "unable to food document for update")
+			return petDocumentLoadError("food", petUuid, foodUUID, err)
 		}
 
 		food, err := r.unmarshalFood(firestoreFood)
 		if err != nil {
 			return err
 		}
-		petUuid = food.PetUUID.String()
+		beforeFood, err := r.unmarshalFood(firestoreFood)
+		if err != nil {
+			return err
+		}
+
+		err = checkPetDocument("food", petUuid, foodUUID, food.PetUUID)
+		if err != nil {
+			return err
+		}
+
+		err = checkIfMatch(ctx, foodUUID, food.Version)
+		if err != nil {
+			return err
+		}
 
 		updatedFood, err := updateFn(ctx, food)
 		if err != nil {
 			return err
 		}
 
-		return tx.Set(documentRef, updatedFood)
+		stampUpdated(beforeFood, updatedFood, time.Now())
+		err = tx.Set(documentRef, updatedFood)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_FOOD, ACTIVITY_ACTION_UPDATED, updatedFood.UUID, updatedFood.PetUUID, userUid), beforeFood, updatedFood)
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update food")
@@ -111,18 +207,44 @@ func (r FoodFirestoreRepository) UpdateFood(ctx context.Context, userUid string,
 	return petFoods, nil
 }
 
-func (r FoodFirestoreRepository) DeleteFood(ctx context.Context, userUid string, foodUUID string) ([]*Food, error) {
-	firestoreFood, err := r.foodsCollection().Doc(foodUUID).Get(ctx)
+func (r FoodFirestoreRepository) DeleteFood(ctx context.Context, userUid string, petUuid string, foodUUID string) ([]*Food, error) {
+	food, err := r.GetFood(ctx, userUid, petUuid, foodUUID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to load food with UUID '%s' before deletion", foodUUID)
+		return nil, errors.Wrap(err, "failed to load food before deletion")
 	}
 
-	food, err := r.unmarshalFood(firestoreFood)
-	if err != nil {
-		return nil, err
-	}
+	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.foodsCollection().Doc(foodUUID)
+
+		err := checkDocumentIfMatch(ctx, tx, documentRef)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(documentRef)
+		if err != nil {
+			return err
+		}
+
+		err = writeTombstone(r.firestoreClient, tx, TOMBSTONE_KIND_FOOD, food.UUID, food.PetUUID)
+		if err != nil {
+			return err
+		}
+
+		trashItem := newTrashItem(TRASH_KIND_FOOD, food.UUID, food.PetUUID, userUid, time.Now())
+		trashItem.Food = food
+		err = writeTrashItem(r.firestoreClient, tx, trashItem)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_FOOD, ACTIVITY_ACTION_DELETED, food.UUID, food.PetUUID, userUid), food, nil)
+		if err != nil {
+			return err
+		}
 
-	_, err = r.foodsCollection().Doc(foodUUID).Delete(ctx)
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to delete food with UUID '%s'", foodUUID)
 	}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -15,26 +16,45 @@ const (
 	FOOD_UNIT_OTHER  FoodUnit = "Other"
 )
 
+func (u FoodUnit) IsValid() bool {
+	switch u {
+	case FOOD_UNIT_GRAMMS, FOOD_UNIT_BAGS, FOOD_UNIT_CANS, FOOD_UNIT_OTHER:
+		return true
+	}
+
+	return false
+}
+
 type FoodFrequency struct {
-	UUID uuid.UUID `firestore:"uuid" json:"uuid"`
-	Time string    `firestore:"time" json:"time"`
+	UUID uuid.UUID `firestore:"uuid" json:"uuid" validate:"required"`
+	Time string    `firestore:"time" json:"time" validate:"timeofday"`
 }
 
 type Food struct {
 	UUID        uuid.UUID       `firestore:"uuid" json:"uuid"`
 	UserUID     string          `firestore:"userUid" json:"userUid"`
 	PetUUID     uuid.UUID       `firestore:"petUuid" json:"petUuid"`
-	Name        string          `firestore:"name" json:"name"`
-	Dosage      int             `firestore:"dosage" json:"dosage"`
-	Unit        FoodUnit        `firestore:"unit" json:"unit"`
-	Stock       int             `firestore:"stock" json:"stock"`
-	Frequencies []FoodFrequency `firestore:"frequencies" json:"frequencies"`
+	Name        string          `firestore:"name" json:"name" validate:"required,max=100"`
+	Dosage      int             `firestore:"dosage" json:"dosage" validate:"gt=0"`
+	Unit        FoodUnit        `firestore:"unit" json:"unit" validate:"enum"`
+	Stock       int             `firestore:"stock" json:"stock" validate:"gte=0"`
+	Frequencies []FoodFrequency `firestore:"frequencies" json:"frequencies" validate:"dive"`
+	UpdatedAt   time.Time       `firestore:"updatedAt" json:"updatedAt"`
+	// Version is incremented on every change, FieldChanges tells in which version each field was changed last
+	Version      int                    `firestore:"version" json:"version"`
+	FieldChanges map[string]FieldChange `firestore:"fieldChanges" json:"-"`
+}
+
+// DailyConsumption is the amount of the food used per day
+func (f *Food) DailyConsumption() float64 {
+	return float64(f.Dosage * len(f.Frequencies))
 }
 
 type FoodRepository interface {
 	AddFood(ctx context.Context, userUid string, petUuid string, petFood *Food) ([]*Food, error)
-	GetFood(ctx context.Context, userUid string, petFoodUUID string) (*Food, error)
+	GetFood(ctx context.Context, userUid string, petUuid string, foodUUID string) (*Food, error)
 	GetFoods(ctx context.Context, userUid string, petUuid string) ([]*Food, error)
-	UpdateFood(ctx context.Context, userUid string, foodUUID string, updateFn func(ctx context.Context, petFood *Food) (*Food, error)) ([]*Food, error)
-	DeleteFood(ctx context.Context, userUid string, foodUUID string) ([]*Food, error)
+	GetFoodsChangedSince(ctx context.Context, userUid string, petUuid string, since time.Time) ([]*Food, error)
+	UpdateFood(ctx context.Context, userUid string, petUuid string, foodUUID string, updateFn func(ctx context.Context, petFood *Food) (*Food, error)) ([]*Food, error)
+	DeleteFood(ctx context.Context, userUid string, petUuid string, foodUUID string) ([]*Food, error)
 }
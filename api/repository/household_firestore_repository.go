@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type NoAccessToHouseholdError struct {
+	UserUid       string
+	HouseholdUuid string
+}
+
+func (e *NoAccessToHouseholdError) Error() string {
+	return fmt.Sprintf("user '%s' has no access to household '%s'", e.UserUid, e.HouseholdUuid)
+}
+
+type HouseholdFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewHouseholdFirestoreRepository(firestoreClient *firestore.Client) HouseholdRepository {
+	return HouseholdFirestoreRepository{firestoreClient}
+}
+
+func (r HouseholdFirestoreRepository) householdsCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("households")
+}
+
+func (r HouseholdFirestoreRepository) petsCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("pets")
+}
+
+func (r HouseholdFirestoreRepository) AddHousehold(ctx context.Context, userUid string, household *Household) ([]*Household, error) {
+	collection := r.householdsCollection()
+
+	householdUUID := uuid.New()
+	household.UUID = householdUUID
+	household.UserUID = userUid
+	household.Members = []HouseholdMember{{UserUid: userUid, Role: HOUSEHOLD_ROLE_OWNER}}
+	household.MemberUids = []string{userUid}
+
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		return tx.Create(collection.Doc(householdUUID.String()), household)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add household")
+	}
+
+	userHouseholds, err := r.GetHouseholds(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's households after new household was added")
+	}
+
+	return userHouseholds, nil
+}
+
+func (r HouseholdFirestoreRepository) GetHousehold(ctx context.Context, userUid string, householdUUID string) (*Household, error) {
+	firestoreHousehold, err := r.householdsCollection().Doc(householdUUID).Get(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get household with UUID '%s'", householdUUID)
+	}
+
+	household, err := r.unmarshalHousehold(firestoreHousehold)
+	if err != nil {
+		return nil, err
+	}
+
+	if !household.IsMember(userUid) {
+		return nil, &NoAccessToHouseholdError{
+			UserUid:       userUid,
+			HouseholdUuid: householdUUID,
+		}
+	}
+
+	return household, nil
+}
+
+func (r HouseholdFirestoreRepository) GetHouseholds(ctx context.Context, userUid string) ([]*Household, error) {
+	householdDocuments, err := r.householdsCollection().Where("memberUids", "array-contains", userUid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get all households for user")
+	}
+
+	households := []*Household{}
+	for _, household := range householdDocuments {
+		unmarshaledHousehold, err := r.unmarshalHousehold(household)
+		if err != nil {
+			return nil, err
+		}
+		households = append(households, unmarshaledHousehold)
+	}
+
+	return households, nil
+}
+
+func (r HouseholdFirestoreRepository) UpdateHousehold(ctx context.Context, userUid string, householdUUID string, updateFn func(ctx context.Context, household *Household) (*Household, error)) ([]*Household, error) {
+	householdsCollection := r.householdsCollection()
+
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := householdsCollection.Doc(householdUUID)
+
+		firestoreHousehold, err := tx.Get(documentRef)
+		if err != nil {
+			return errors.Wrap(err, "unable to get household document for update")
+		}
+
+		household, err := r.unmarshalHousehold(firestoreHousehold)
+		if err != nil {
+			return err
+		}
+
+		if !household.IsMember(userUid) {
+			return &NoAccessToHouseholdError{
+				UserUid:       userUid,
+				HouseholdUuid: householdUUID,
+			}
+		}
+
+		updatedHousehold, err := updateFn(ctx, household)
+		if err != nil {
+			return err
+		}
+
+		updatedHousehold.MemberUids = []string{}
+		for _, member := range updatedHousehold.Members {
+			updatedHousehold.MemberUids = append(updatedHousehold.MemberUids, member.UserUid)
+		}
+
+		return tx.Set(documentRef, updatedHousehold)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update household")
+	}
+
+	userHouseholds, err := r.GetHouseholds(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's households after household was updated")
+	}
+
+	return userHouseholds, nil
+}
+
+func (r HouseholdFirestoreRepository) DeleteHousehold(ctx context.Context, userUid string, householdUUID string, detachPetFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Household, error) {
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.householdsCollection().Doc(householdUUID)
+
+		firestoreHousehold, err := tx.Get(documentRef)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load household with UUID '%s' before deletion", householdUUID)
+		}
+
+		household, err := r.unmarshalHousehold(firestoreHousehold)
+		if err != nil {
+			return err
+		}
+		if !household.IsOwner(userUid) {
+			return &NoAccessToHouseholdError{
+				UserUid:       userUid,
+				HouseholdUuid: householdUUID,
+			}
+		}
+
+		householdPets, err := tx.Documents(r.petsCollection().Where("householdUuid", "==", householdUUID)).GetAll()
+		if err != nil {
+			return errors.Wrapf(err, "failed to load pets of household with UUID '%s' before deletion", householdUUID)
+		}
+
+		for _, petDocument := range householdPets {
+			pet, beforePet := Pet{}, Pet{}
+			err = petDocument.DataTo(&pet)
+			if err == nil {
+				err = petDocument.DataTo(&beforePet)
+			}
+			if err != nil {
+				return errors.Wrap(err, "unable to unmarshal document to pet")
+			}
+
+			pet.HouseholdUUID = ""
+			detachedPet, err := detachPetFn(ctx, &pet)
+			if err != nil {
+				return err
+			}
+			detachedPet.mirrorSharedWithUserUids()
+			stampUpdated(&beforePet, detachedPet, time.Now())
+			err = tx.Set(petDocument.Ref, detachedPet)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove pet '%s' from household before deletion", petDocument.Ref.ID)
+			}
+
+			err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_PET, ACTIVITY_ACTION_UPDATED, detachedPet.UUID, detachedPet.UUID, userUid), &beforePet, detachedPet)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Delete(documentRef)
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete household with UUID '%s'", householdUUID)
+	}
+
+	userHouseholds, err := r.GetHouseholds(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's households after household was deleted")
+	}
+
+	return userHouseholds, nil
+}
+
+func (r HouseholdFirestoreRepository) unmarshalHousehold(doc *firestore.DocumentSnapshot) (*Household, error) {
+	HouseholdModel := Household{}
+	err := doc.DataTo(&HouseholdModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to household")
+	}
+
+	return &HouseholdModel, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type HouseholdRole string
+
+const (
+	HOUSEHOLD_ROLE_OWNER  HouseholdRole = "Owner"
+	HOUSEHOLD_ROLE_MEMBER HouseholdRole = "Member"
+)
+
+type HouseholdMember struct {
+	UserUid string        `firestore:"userUid" json:"userUid"`
+	Role    HouseholdRole `firestore:"role" json:"role"`
+}
+
+type Household struct {
+	UUID    uuid.UUID         `firestore:"uuid" json:"uuid"`
+	UserUID string            `firestore:"userUid" json:"userUid"`
+	Name    string            `firestore:"name" json:"name"`
+	Members []HouseholdMember `firestore:"members" json:"members"`
+	// MemberUids mirrors the UIDs of Members, it is needed to query all households of a user
+	MemberUids []string `firestore:"memberUids" json:"-"`
+}
+
+type AddHouseholdMemberRequest struct {
+	UserMailToAdd string        `json:"userMailToAdd"`
+	Role          HouseholdRole `json:"role"`
+}
+
+func (h *Household) Member(userUid string) (HouseholdMember, bool) {
+	for _, member := range h.Members {
+		if member.UserUid == userUid {
+			return member, true
+		}
+	}
+
+	return HouseholdMember{}, false
+}
+
+func (h *Household) IsMember(userUid string) bool {
+	_, isMember := h.Member(userUid)
+	return isMember
+}
+
+func (h *Household) IsOwner(userUid string) bool {
+	member, isMember := h.Member(userUid)
+	return isMember && member.Role == HOUSEHOLD_ROLE_OWNER
+}
+
+type HouseholdRepository interface {
+	AddHousehold(ctx context.Context, userUid string, household *Household) ([]*Household, error)
+	GetHousehold(ctx context.Context, userUid string, householdUUID string) (*Household, error)
+	GetHouseholds(ctx context.Context, userUid string) ([]*Household, error)
+	UpdateHousehold(ctx context.Context, userUid string, householdUUID string, updateFn func(ctx context.Context, household *Household) (*Household, error)) ([]*Household, error)
+	// DeleteHousehold removes the pets from the household before deleting it, detachPetFn is called with every
+	// removed pet and may change it further before it is written
+	DeleteHousehold(ctx context.Context, userUid string, householdUUID string, detachPetFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Household, error)
+}
@@ -2,12 +2,28 @@ package repository
 
 import (
 	"context"
+	"reflect"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const medicineRevisionsCollection = "medicineRevisions"
+
+// writeMedicineRevision keeps the medicine as it is after the change in the transaction which changes it
+func writeMedicineRevision(firestoreClient *firestore.Client, tx *firestore.Transaction, revision *MedicineRevision) error {
+	err := tx.Create(firestoreClient.Collection(medicineRevisionsCollection).Doc(revision.UUID.String()), revision)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write revision of medicine '%s'", revision.MedicineUUID)
+	}
+
+	return nil
+}
+
 type MedicineFirestoreRepository struct {
 	firestoreClient *firestore.Client
 }
@@ -20,20 +36,49 @@ func (r MedicineFirestoreRepository) medicinesCollection() *firestore.Collection
 	return r.firestoreClient.Collection("medicines")
 }
 
+func (r MedicineFirestoreRepository) medicineRevisionsCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection(medicineRevisionsCollection)
+}
+
 func (r MedicineFirestoreRepository) AddMedicine(ctx context.Context, userUid string, petUuid string, medicine *Medicine) ([]*Medicine, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	collection := r.medicinesCollection()
 
-	medicineUUID := uuid.New()
-	medicine.UUID = medicineUUID
+	// the UUID may be chosen by the client, e.g. for a medicine created while it was offline
+	if medicine.UUID == uuid.Nil {
+		medicine.UUID = uuid.New()
+	}
+	medicineUUID := medicine.UUID
 	medicine.UserUID = userUid
 	petUUID, err := uuid.Parse(petUuid)
 	if err != nil {
-		return nil, err
+		return nil, NewValidationError("invalid_uuid", errors.Wrapf(err, "invalid pet UUID '%s'", petUuid))
 	}
 	medicine.PetUUID = petUUID
+	medicine.mirrorDoseTimes()
+	stampCreated(medicine, time.Now())
 
 	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		return tx.Create(collection.Doc(medicineUUID.String()), medicine)
+		err := tx.Create(collection.Doc(medicineUUID.String()), medicine)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_MEDICINE, ACTIVITY_ACTION_CREATED, medicine.UUID, medicine.PetUUID, userUid), nil, medicine)
+		if err != nil {
+			return err
+		}
+
+		err = writeMedicineRevision(r.firestoreClient, tx, newMedicineRevision(ACTIVITY_ACTION_CREATED, medicine.UUID, medicine.PetUUID, userUid, medicine))
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to add medicine")
@@ -47,16 +92,37 @@ func (r MedicineFirestoreRepository) AddMedicine(ctx context.Context, userUid st
 	return petMedicines, nil
 }
 
-func (r MedicineFirestoreRepository) GetMedicine(ctx context.Context, userUid string, medicineUUID string) (*Medicine, error) {
+// GetMedicine loads the medicine of the pet, the medicine of another pet is reported as not found
+func (r MedicineFirestoreRepository) GetMedicine(ctx context.Context, userUid string, petUuid string, medicineUUID string) (*Medicine, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	firestoreMedicine, err := r.medicinesCollection().Doc(medicineUUID).Get(ctx)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get pet medicine with UUID '%s'", medicineUUID)
+		return nil, petDocumentLoadError("medicine", petUuid, medicineUUID, err)
 	}
 
-	return r.unmarshalMedicine(firestoreMedicine)
+	medicine, err := r.unmarshalMedicine(firestoreMedicine)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkPetDocument("medicine", petUuid, medicineUUID, medicine.PetUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return medicine, nil
 }
 
 func (r MedicineFirestoreRepository) GetMedicines(ctx context.Context, userUid string, petUuid string) ([]*Medicine, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	allPetMedicineDocuments, err := r.medicinesCollection().Where("petUuid", "==", petUuid).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get all medicines for pet %s", petUuid)
@@ -74,30 +140,153 @@ func (r MedicineFirestoreRepository) GetMedicines(ctx context.Context, userUid s
 	return allPetMedicines, nil
 }
 
-func (r MedicineFirestoreRepository) UpdateMedicine(ctx context.Context, userUid string, medicineUUID string, updateFn func(ctx context.Context, medicine *Medicine) (*Medicine, error)) ([]*Medicine, error) {
-	var petUuid string
+// GetMedicinesWithDoseTimes loads the medicines of all pets with a dose at one of the given times of day, it is meant
+// for background jobs only
+func (r MedicineFirestoreRepository) GetMedicinesWithDoseTimes(ctx context.Context, doseTimes []string) ([]*Medicine, error) {
+	medicines := []*Medicine{}
+	addedMedicines := map[string]bool{}
+	for start := 0; start < len(doseTimes); start += maxFirestoreInQueryValues {
+		end := start + maxFirestoreInQueryValues
+		if end > len(doseTimes) {
+			end = len(doseTimes)
+		}
+
+		medicineDocuments, err := r.medicinesCollection().Where("doseTimes", "array-contains-any", doseTimes[start:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get medicines with doses at %v", doseTimes[start:end])
+		}
+
+		for _, medicineDocument := range medicineDocuments {
+			if addedMedicines[medicineDocument.Ref.ID] {
+				continue
+			}
+
+			medicine, err := r.unmarshalMedicine(medicineDocument)
+			if err != nil {
+				return nil, err
+			}
+			medicines = append(medicines, medicine)
+			addedMedicines[medicineDocument.Ref.ID] = true
+		}
+	}
+
+	return medicines, nil
+}
+
+// MirrorDoseTimes sets the dose times of the medicines written before they were mirrored. The dose times are derived
+// from the frequencies, so they are set without changing the version of the medicine.
+func (r MedicineFirestoreRepository) MirrorDoseTimes(ctx context.Context) error {
+	medicineDocuments, err := r.medicinesCollection().Documents(ctx).GetAll()
+	if err != nil {
+		return errors.Wrap(err, "failed to get all medicines")
+	}
+
+	for _, medicineDocument := range medicineDocuments {
+		medicine, err := r.unmarshalMedicine(medicineDocument)
+		if err != nil {
+			return err
+		}
+
+		doseTimes := medicine.DoseTimes
+		medicine.mirrorDoseTimes()
+		if doseTimes != nil && reflect.DeepEqual(doseTimes, medicine.DoseTimes) {
+			continue
+		}
+
+		_, err = medicineDocument.Ref.Update(ctx, []firestore.Update{{Path: "doseTimes", Value: medicine.DoseTimes}})
+		if err != nil {
+			return errors.Wrapf(err, "failed to mirror dose times of medicine '%s'", medicine.UUID)
+		}
+	}
+
+	return nil
+}
+
+// GetMedicinesChangedSince loads the medicines of the pet which were created or changed after the given time
+func (r MedicineFirestoreRepository) GetMedicinesChangedSince(ctx context.Context, userUid string, petUuid string, since time.Time) ([]*Medicine, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	changedMedicineDocuments, err := r.medicinesCollection().
+		Where("petUuid", "==", petUuid).
+		Where("updatedAt", ">", since).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get changed medicines for pet %s", petUuid)
+	}
+
+	changedMedicines := []*Medicine{}
+	for _, medicine := range changedMedicineDocuments {
+		unmarshaledMedicine, err := r.unmarshalMedicine(medicine)
+		if err != nil {
+			return nil, err
+		}
+		changedMedicines = append(changedMedicines, unmarshaledMedicine)
+	}
+
+	return changedMedicines, nil
+}
+
+func (r MedicineFirestoreRepository) UpdateMedicine(ctx context.Context, userUid string, petUuid string, medicineUUID string, updateFn func(ctx context.Context, medicine *Medicine) (*Medicine, error)) ([]*Medicine, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	medicinesCollection := r.medicinesCollection()
 
-	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		documentRef := medicinesCollection.Doc(medicineUUID)
 
 		firestoreMedicine, err := tx.Get(documentRef)
 		if err != nil {
-			return errors.Wrap(err, "unable to medicine document for update")
+			return petDocumentLoadError("medicine", petUuid, medicineUUID, err)
 		}
 
 		medicine, err := r.unmarshalMedicine(firestoreMedicine)
 		if err != nil {
 			return err
 		}
-		petUuid = medicine.PetUUID.String()
+		beforeMedicine, err := r.unmarshalMedicine(firestoreMedicine)
+		if err != nil {
+			return err
+		}
+
+		err = checkPetDocument("medicine", petUuid, medicineUUID, medicine.PetUUID)
+		if err != nil {
+			return err
+		}
+
+		err = checkIfMatch(ctx, medicineUUID, medicine.Version)
+		if err != nil {
+			return err
+		}
 
 		updatedMedicine, err := updateFn(ctx, medicine)
 		if err != nil {
 			return err
 		}
 
-		return tx.Set(documentRef, updatedMedicine)
+		updatedMedicine.mirrorDoseTimes()
+		stampUpdated(beforeMedicine, updatedMedicine, time.Now())
+		err = tx.Set(documentRef, updatedMedicine)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_MEDICINE, ACTIVITY_ACTION_UPDATED, updatedMedicine.UUID, updatedMedicine.PetUUID, userUid), beforeMedicine, updatedMedicine)
+		if err != nil {
+			return err
+		}
+
+		err = writeMedicineRevision(r.firestoreClient, tx, newMedicineRevision(ACTIVITY_ACTION_UPDATED, updatedMedicine.UUID, updatedMedicine.PetUUID, userUid, updatedMedicine))
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update medicine")
@@ -111,18 +300,49 @@ func (r MedicineFirestoreRepository) UpdateMedicine(ctx context.Context, userUid
 	return petMedicines, nil
 }
 
-func (r MedicineFirestoreRepository) DeleteMedicine(ctx context.Context, userUid string, medicineUUID string) ([]*Medicine, error) {
-	firestoreMedicine, err := r.medicinesCollection().Doc(medicineUUID).Get(ctx)
+func (r MedicineFirestoreRepository) DeleteMedicine(ctx context.Context, userUid string, petUuid string, medicineUUID string) ([]*Medicine, error) {
+	medicine, err := r.GetMedicine(ctx, userUid, petUuid, medicineUUID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to load medicine with UUID '%s' before deletion", medicineUUID)
+		return nil, errors.Wrap(err, "failed to load medicine before deletion")
 	}
 
-	medicine, err := r.unmarshalMedicine(firestoreMedicine)
-	if err != nil {
-		return nil, err
-	}
+	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.medicinesCollection().Doc(medicineUUID)
 
-	_, err = r.medicinesCollection().Doc(medicineUUID).Delete(ctx)
+		err := checkDocumentIfMatch(ctx, tx, documentRef)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(documentRef)
+		if err != nil {
+			return err
+		}
+
+		err = writeTombstone(r.firestoreClient, tx, TOMBSTONE_KIND_MEDICINE, medicine.UUID, medicine.PetUUID)
+		if err != nil {
+			return err
+		}
+
+		trashItem := newTrashItem(TRASH_KIND_MEDICINE, medicine.UUID, medicine.PetUUID, userUid, time.Now())
+		trashItem.Medicine = medicine
+		err = writeTrashItem(r.firestoreClient, tx, trashItem)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_MEDICINE, ACTIVITY_ACTION_DELETED, medicine.UUID, medicine.PetUUID, userUid), medicine, nil)
+		if err != nil {
+			return err
+		}
+
+		err = writeMedicineRevision(r.firestoreClient, tx, newMedicineRevision(ACTIVITY_ACTION_DELETED, medicine.UUID, medicine.PetUUID, userUid, nil))
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to delete medicine with UUID '%s'", medicineUUID)
 	}
@@ -135,6 +355,95 @@ func (r MedicineFirestoreRepository) DeleteMedicine(ctx context.Context, userUid
 	return petMedicines, nil
 }
 
+// GetMedicineRevisions loads all revisions of the medicine of the pet, oldest first
+func (r MedicineFirestoreRepository) GetMedicineRevisions(ctx context.Context, userUid string, petUuid string, medicineUUID string) ([]*MedicineRevision, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	revisionDocuments, err := r.medicineRevisionsCollection().
+		Where("petUuid", "==", petUuid).
+		Where("medicineUuid", "==", medicineUUID).
+		OrderBy("changedAt", firestore.Asc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get revisions of medicine %s", medicineUUID)
+	}
+
+	revisions := []*MedicineRevision{}
+	for _, revisionDocument := range revisionDocuments {
+		revision, err := r.unmarshalMedicineRevision(revisionDocument)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+// GetMedicineRevision loads the revision of the medicine of the pet, the revision of another medicine is reported
+// as not found
+func (r MedicineFirestoreRepository) GetMedicineRevision(ctx context.Context, userUid string, petUuid string, medicineUUID string, revisionUUID string) (*MedicineRevision, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	firestoreRevision, err := r.medicineRevisionsCollection().Doc(revisionUUID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, NewNotFoundError("medicine_revision_not_found", errors.Wrapf(err, "no revision '%s' of medicine '%s'", revisionUUID, medicineUUID))
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get revision '%s' of medicine '%s'", revisionUUID, medicineUUID)
+	}
+
+	revision, err := r.unmarshalMedicineRevision(firestoreRevision)
+	if err != nil {
+		return nil, err
+	}
+	if revision.PetUUID.String() != petUuid || revision.MedicineUUID.String() != medicineUUID {
+		return nil, NewNotFoundError("medicine_revision_not_found", errors.Errorf("no revision '%s' of medicine '%s' of pet '%s'", revisionUUID, medicineUUID, petUuid))
+	}
+
+	return revision, nil
+}
+
+// GetMedicineRevisionAt loads the latest revision of the medicine of the pet which was written up to the given time
+func (r MedicineFirestoreRepository) GetMedicineRevisionAt(ctx context.Context, userUid string, petUuid string, medicineUUID string, at time.Time) (*MedicineRevision, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	revisionDocuments, err := r.medicineRevisionsCollection().
+		Where("petUuid", "==", petUuid).
+		Where("medicineUuid", "==", medicineUUID).
+		Where("changedAt", "<=", at).
+		OrderBy("changedAt", firestore.Desc).
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get revision of medicine %s at %s", medicineUUID, at.Format(time.RFC3339))
+	}
+	if len(revisionDocuments) == 0 {
+		return nil, NewNotFoundError("medicine_revision_not_found", errors.Errorf("no revision of medicine '%s' up to %s", medicineUUID, at.Format(time.RFC3339)))
+	}
+
+	return r.unmarshalMedicineRevision(revisionDocuments[0])
+}
+
+func (r MedicineFirestoreRepository) unmarshalMedicineRevision(doc *firestore.DocumentSnapshot) (*MedicineRevision, error) {
+	MedicineRevisionModel := MedicineRevision{}
+	err := doc.DataTo(&MedicineRevisionModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to medicine revision")
+	}
+
+	return &MedicineRevisionModel, nil
+}
+
 func (r MedicineFirestoreRepository) unmarshalMedicine(doc *firestore.DocumentSnapshot) (*Medicine, error) {
 	MedicineModel := Medicine{}
 	err := doc.DataTo(&MedicineModel)
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,27 +17,126 @@ const (
 	MEDICINE_UNIT_OTHER       PetMedicineUnit = "Other"
 )
 
+func (u PetMedicineUnit) IsValid() bool {
+	switch u {
+	case MEDICINE_UNIT_PILLS, MEDICINE_UNIT_MILLILITRES, MEDICINE_UNIT_UNITS, MEDICINE_UNIT_GRAMMS, MEDICINE_UNIT_OTHER:
+		return true
+	}
+
+	return false
+}
+
 type MedicineFrequency struct {
-	UUID      uuid.UUID `firestore:"uuid" json:"uuid"`
-	Time      string    `firestore:"time" json:"time"`
-	EveryDays int       `firestore:"everyDays" json:"everyDays"`
+	UUID      uuid.UUID `firestore:"uuid" json:"uuid" validate:"required"`
+	Time      string    `firestore:"time" json:"time" validate:"timeofday"`
+	EveryDays int       `firestore:"everyDays" json:"everyDays" validate:"gte=1,lte=365"`
 }
 
+// IsDueOn checks if the frequency is due on the day of the given time. Medicines which are not given daily
+// are counted from the first day of the unix epoch, as they have no start date.
+func (f MedicineFrequency) IsDueOn(t time.Time) bool {
+	if f.EveryDays <= 1 {
+		return true
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	daysSinceEpoch := int(day.Unix() / (24 * 60 * 60))
+
+	return daysSinceEpoch%f.EveryDays == 0
+}
+
+// EscalationPolicy defines who gets notified when a dose of the medicine is not marked as done.
+// The owner is reminded OwnerAfterMinutes after the dose was due, all other caretakers of the pet
+// are notified CaretakersAfterMinutes after the owner was reminded. Zero disables the step. Each
+// delay is limited to a day, so every missed dose is escalated within MaxEscalationWindow.
+type EscalationPolicy struct {
+	OwnerAfterMinutes      int `firestore:"ownerAfterMinutes" json:"ownerAfterMinutes" validate:"gte=0,lte=1440"`
+	CaretakersAfterMinutes int `firestore:"caretakersAfterMinutes" json:"caretakersAfterMinutes" validate:"gte=0,lte=1440"`
+}
+
+// MaxEscalationWindow is the longest time after which a missed dose is escalated to the caretakers
+const MaxEscalationWindow = 2 * 24 * time.Hour
+
 type Medicine struct {
 	UUID        uuid.UUID           `firestore:"uuid" json:"uuid"`
 	UserUID     string              `firestore:"userUid" json:"userUid"`
 	PetUUID     uuid.UUID           `firestore:"petUuid" json:"petUuid"`
-	Name        string              `firestore:"name" json:"name"`
-	Dosage      int                 `firestore:"dosage" json:"dosage"`
-	Unit        PetMedicineUnit     `firestore:"unit" json:"unit"`
-	Stock       int                 `firestore:"stock" json:"stock"`
-	Frequencies []MedicineFrequency `firestore:"frequencies" json:"frequencies"`
+	Name        string              `firestore:"name" json:"name" validate:"required,max=100"`
+	Dosage      int                 `firestore:"dosage" json:"dosage" validate:"gt=0"`
+	Unit        PetMedicineUnit     `firestore:"unit" json:"unit" validate:"enum"`
+	Stock       int                 `firestore:"stock" json:"stock" validate:"gte=0"`
+	Frequencies []MedicineFrequency `firestore:"frequencies" json:"frequencies" validate:"dive"`
+	Escalation  *EscalationPolicy   `firestore:"escalation" json:"escalation,omitempty"`
+	// DoseTimes mirrors the times of Frequencies, it is needed to query the medicines due at a time of day
+	DoseTimes []string  `firestore:"doseTimes" json:"-"`
+	UpdatedAt time.Time `firestore:"updatedAt" json:"updatedAt"`
+	// Version is incremented on every change, FieldChanges tells in which version each field was changed last
+	Version      int                    `firestore:"version" json:"version"`
+	FieldChanges map[string]FieldChange `firestore:"fieldChanges" json:"-"`
+}
+
+// DailyConsumption is the amount of the medicine used per day on average
+func (m *Medicine) DailyConsumption() float64 {
+	consumption := 0.0
+	for _, frequency := range m.Frequencies {
+		everyDays := frequency.EveryDays
+		if everyDays < 1 {
+			everyDays = 1
+		}
+		consumption += float64(m.Dosage) / float64(everyDays)
+	}
+
+	return consumption
+}
+
+// MedicineRevision is the medicine as it was after a change, revisions are never changed afterwards. Medicine is
+// nil in the revision of a deletion.
+type MedicineRevision struct {
+	UUID         uuid.UUID      `firestore:"uuid" json:"uuid"`
+	MedicineUUID uuid.UUID      `firestore:"medicineUuid" json:"medicineUuid"`
+	PetUUID      uuid.UUID      `firestore:"petUuid" json:"petUuid"`
+	Action       ActivityAction `firestore:"action" json:"action"`
+	ChangedAt    time.Time      `firestore:"changedAt" json:"changedAt"`
+	ChangedBy    string         `firestore:"changedBy" json:"changedBy"`
+	Medicine     *Medicine      `firestore:"medicine" json:"medicine"`
+}
+
+func newMedicineRevision(action ActivityAction, medicineUuid uuid.UUID, petUuid uuid.UUID, changedBy string, medicine *Medicine) *MedicineRevision {
+	return &MedicineRevision{
+		UUID:         uuid.New(),
+		MedicineUUID: medicineUuid,
+		PetUUID:      petUuid,
+		Action:       action,
+		ChangedAt:    time.Now(),
+		ChangedBy:    changedBy,
+		Medicine:     medicine,
+	}
+}
+
+// MedicineRevisionDiff lists the fields which changed from one revision of a medicine to another
+type MedicineRevisionDiff struct {
+	From    *MedicineRevision `json:"from"`
+	To      *MedicineRevision `json:"to"`
+	Changes []ActivityChange  `json:"changes"`
+}
+
+func (m *Medicine) mirrorDoseTimes() {
+	m.DoseTimes = []string{}
+	for _, frequency := range m.Frequencies {
+		m.DoseTimes = append(m.DoseTimes, frequency.Time)
+	}
 }
 
 type MedicineRepository interface {
 	AddMedicine(ctx context.Context, userUid string, petUuid string, petMedicine *Medicine) ([]*Medicine, error)
-	GetMedicine(ctx context.Context, userUid string, petMedicineUUID string) (*Medicine, error)
+	GetMedicine(ctx context.Context, userUid string, petUuid string, medicineUUID string) (*Medicine, error)
 	GetMedicines(ctx context.Context, userUid string, petUuid string) ([]*Medicine, error)
-	UpdateMedicine(ctx context.Context, userUid string, medicineUUID string, updateFn func(ctx context.Context, petMedicine *Medicine) (*Medicine, error)) ([]*Medicine, error)
-	DeleteMedicine(ctx context.Context, userUid string, medicineUUID string) ([]*Medicine, error)
+	GetMedicinesChangedSince(ctx context.Context, userUid string, petUuid string, since time.Time) ([]*Medicine, error)
+	GetMedicinesWithDoseTimes(ctx context.Context, doseTimes []string) ([]*Medicine, error)
+	MirrorDoseTimes(ctx context.Context) error
+	UpdateMedicine(ctx context.Context, userUid string, petUuid string, medicineUUID string, updateFn func(ctx context.Context, petMedicine *Medicine) (*Medicine, error)) ([]*Medicine, error)
+	DeleteMedicine(ctx context.Context, userUid string, petUuid string, medicineUUID string) ([]*Medicine, error)
+	GetMedicineRevisions(ctx context.Context, userUid string, petUuid string, medicineUUID string) ([]*MedicineRevision, error)
+	GetMedicineRevision(ctx context.Context, userUid string, petUuid string, medicineUUID string, revisionUUID string) (*MedicineRevision, error)
+	GetMedicineRevisionAt(ctx context.Context, userUid string, petUuid string, medicineUUID string, at time.Time) (*MedicineRevision, error)
 }
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxFirestoreBatchWrites is the maximum number of writes Firestore allows in one batch
+const maxFirestoreBatchWrites = 500
+
+// notificationClaimRetention is the time a claimed notification is remembered, the expireAt field of the claims can
+// be used as TTL of the collection
+const notificationClaimRetention = 30 * 24 * time.Hour
+
+// notificationClaimsNamespace is used to derive the document IDs of claims from the IDs of notifications
+var notificationClaimsNamespace = uuid.MustParse("9c2e6a41-7d3b-4f8e-a5c0-1b6d8e2f4a73")
+
+type NotificationQueueFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewNotificationQueueFirestoreRepository(firestoreClient *firestore.Client) NotificationQueueRepository {
+	return NotificationQueueFirestoreRepository{firestoreClient}
+}
+
+func (r NotificationQueueFirestoreRepository) notificationQueueCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("notificationQueue")
+}
+
+func (r NotificationQueueFirestoreRepository) notificationClaimsCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("notificationClaims")
+}
+
+func (r NotificationQueueFirestoreRepository) EnqueueNotification(ctx context.Context, notification *QueuedNotification) error {
+	notification.UUID = uuid.New()
+	notification.QueuedAt = time.Now()
+
+	_, err := r.notificationQueueCollection().Doc(notification.UUID.String()).Create(ctx, notification)
+	if err != nil {
+		return errors.Wrapf(err, "failed to enqueue %s notification for user '%s'", notification.NotificationType, notification.UserUID)
+	}
+
+	return nil
+}
+
+func (r NotificationQueueFirestoreRepository) GetQueuedNotificationsDueBefore(ctx context.Context, dueBefore time.Time) ([]*QueuedNotification, error) {
+	notificationDocuments, err := r.notificationQueueCollection().Where("deliverAfter", "<=", dueBefore).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get due queued notifications")
+	}
+
+	notifications := []*QueuedNotification{}
+	for _, notification := range notificationDocuments {
+		unmarshaledNotification, err := r.unmarshalQueuedNotification(notification)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, unmarshaledNotification)
+	}
+
+	return notifications, nil
+}
+
+func (r NotificationQueueFirestoreRepository) DeleteQueuedNotifications(ctx context.Context, notificationUuids []uuid.UUID) error {
+	for start := 0; start < len(notificationUuids); start += maxFirestoreBatchWrites {
+		end := start + maxFirestoreBatchWrites
+		if end > len(notificationUuids) {
+			end = len(notificationUuids)
+		}
+
+		batch := r.firestoreClient.Batch()
+		for _, notificationUuid := range notificationUuids[start:end] {
+			batch.Delete(r.notificationQueueCollection().Doc(notificationUuid.String()))
+		}
+
+		_, err := batch.Commit(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete %d queued notifications", end-start)
+		}
+	}
+
+	return nil
+}
+
+// RescheduleQueuedNotifications stores the queued notifications with their next delivery time and failed attempts
+func (r NotificationQueueFirestoreRepository) RescheduleQueuedNotifications(ctx context.Context, notifications []*QueuedNotification) error {
+	for start := 0; start < len(notifications); start += maxFirestoreBatchWrites {
+		end := start + maxFirestoreBatchWrites
+		if end > len(notifications) {
+			end = len(notifications)
+		}
+
+		batch := r.firestoreClient.Batch()
+		for _, notification := range notifications[start:end] {
+			batch.Set(r.notificationQueueCollection().Doc(notification.UUID.String()), notification)
+		}
+
+		_, err := batch.Commit(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to reschedule %d queued notifications", end-start)
+		}
+	}
+
+	return nil
+}
+
+func (r NotificationQueueFirestoreRepository) ClaimNotification(ctx context.Context, notificationId string) (bool, error) {
+	claimedAt := time.Now()
+	_, err := r.notificationClaimDocument(notificationId).Create(ctx, &NotificationClaim{
+		NotificationID: notificationId,
+		ClaimedAt:      claimedAt,
+		ExpireAt:       claimedAt.Add(notificationClaimRetention),
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to claim notification '%s'", notificationId)
+	}
+
+	return true, nil
+}
+
+func (r NotificationQueueFirestoreRepository) ReleaseNotification(ctx context.Context, notificationId string) error {
+	_, err := r.notificationClaimDocument(notificationId).Delete(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to release claim of notification '%s'", notificationId)
+	}
+
+	return nil
+}
+
+func (r NotificationQueueFirestoreRepository) notificationClaimDocument(notificationId string) *firestore.DocumentRef {
+	return r.notificationClaimsCollection().Doc(uuid.NewSHA1(notificationClaimsNamespace, []byte(notificationId)).String())
+}
+
+func (r NotificationQueueFirestoreRepository) unmarshalQueuedNotification(doc *firestore.DocumentSnapshot) (*QueuedNotification, error) {
+	QueuedNotificationModel := QueuedNotification{}
+	err := doc.DataTo(&QueuedNotificationModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to queued notification")
+	}
+
+	return &QueuedNotificationModel, nil
+}
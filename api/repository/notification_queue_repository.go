@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueuedNotification is a notification which is held back because of quiet hours or the daily digest
+type QueuedNotification struct {
+	UUID             uuid.UUID         `firestore:"uuid" json:"uuid"`
+	UserUID          string            `firestore:"userUid" json:"userUid"`
+	NotificationType string            `firestore:"notificationType" json:"notificationType"`
+	Data             map[string]string `firestore:"data" json:"data"`
+	QueuedAt         time.Time         `firestore:"queuedAt" json:"queuedAt"`
+	DeliverAfter     time.Time         `firestore:"deliverAfter" json:"deliverAfter"`
+	// Digest marks notifications which are sent batched in the digest instead of on their own
+	Digest bool `firestore:"digest" json:"digest"`
+	// FailedAttempts counts the attempts to send the notification which failed, it is retried until it was sent
+	FailedAttempts int `firestore:"failedAttempts" json:"failedAttempts"`
+	// SentChannels are the channels the notification was already sent on, a retry only sends it on the other channels
+	SentChannels []string `firestore:"sentChannels" json:"sentChannels"`
+}
+
+// NotificationClaim records that a notification with an ID was sent or queued, so it isn't sent a second time
+type NotificationClaim struct {
+	NotificationID string    `firestore:"notificationId" json:"notificationId"`
+	ClaimedAt      time.Time `firestore:"claimedAt" json:"claimedAt"`
+	// ExpireAt is the time after which the claim may be removed
+	ExpireAt time.Time `firestore:"expireAt" json:"expireAt"`
+}
+
+type NotificationQueueRepository interface {
+	EnqueueNotification(ctx context.Context, notification *QueuedNotification) error
+	GetQueuedNotificationsDueBefore(ctx context.Context, dueBefore time.Time) ([]*QueuedNotification, error)
+	DeleteQueuedNotifications(ctx context.Context, notificationUuids []uuid.UUID) error
+	RescheduleQueuedNotifications(ctx context.Context, notifications []*QueuedNotification) error
+	// ClaimNotification records that the notification with the ID is being sent, it returns false if it was claimed before
+	ClaimNotification(ctx context.Context, notificationId string) (bool, error)
+	// ReleaseNotification removes the claim of a notification which could not be sent, so it is sent when notified again
+	ReleaseNotification(ctx context.Context, notificationId string) error
+}
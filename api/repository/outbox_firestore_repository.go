@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+)
+
+const outboxCollection = "outbox"
+
+// writeOutbox stores the outbox entries of the context in the transaction, it is called by every transactional write
+// which may be the cause of an event through writeTransactionRecords. Entries are keyed by their ID, so retried
+// transactions don't duplicate them.
+func writeOutbox(ctx context.Context, firestoreClient *firestore.Client, tx *firestore.Transaction) error {
+	entriesFn, ok := ctx.Value(outboxContextKey{}).(OutboxEntriesFn)
+	if !ok {
+		return nil
+	}
+
+	entries, err := entriesFn()
+	if err != nil {
+		return errors.Wrap(err, "failed to build outbox entries")
+	}
+
+	for _, entry := range entries {
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+
+		err := tx.Set(firestoreClient.Collection(outboxCollection).Doc(entry.ID), entry)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write %s event '%s' to the outbox", entry.Name, entry.ID)
+		}
+	}
+
+	return nil
+}
+
+type OutboxFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewOutboxFirestoreRepository(firestoreClient *firestore.Client) OutboxRepository {
+	return OutboxFirestoreRepository{firestoreClient}
+}
+
+func (r OutboxFirestoreRepository) outboxCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection(outboxCollection)
+}
+
+func (r OutboxFirestoreRepository) GetOutboxEntries(ctx context.Context, limit int) ([]*OutboxEntry, error) {
+	entryDocuments, err := r.outboxCollection().OrderBy("createdAt", firestore.Asc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get outbox entries")
+	}
+
+	entries := []*OutboxEntry{}
+	for _, entry := range entryDocuments {
+		unmarshaledEntry, err := r.unmarshalOutboxEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, unmarshaledEntry)
+	}
+
+	return entries, nil
+}
+
+// MarkOutboxEntryHandled records that the subscriber handled the event of the entry
+func (r OutboxFirestoreRepository) MarkOutboxEntryHandled(ctx context.Context, entryId string, subscriberName string) error {
+	_, err := r.outboxCollection().Doc(entryId).Update(ctx, []firestore.Update{{Path: "handledBy", Value: firestore.ArrayUnion(subscriberName)}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to mark outbox entry '%s' as handled by '%s'", entryId, subscriberName)
+	}
+
+	return nil
+}
+
+func (r OutboxFirestoreRepository) DeleteOutboxEntries(ctx context.Context, entryIds []string) error {
+	for start := 0; start < len(entryIds); start += maxFirestoreBatchWrites {
+		end := start + maxFirestoreBatchWrites
+		if end > len(entryIds) {
+			end = len(entryIds)
+		}
+
+		batch := r.firestoreClient.Batch()
+		for _, entryId := range entryIds[start:end] {
+			batch.Delete(r.outboxCollection().Doc(entryId))
+		}
+
+		_, err := batch.Commit(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete %d published outbox entries", end-start)
+		}
+	}
+
+	return nil
+}
+
+func (r OutboxFirestoreRepository) unmarshalOutboxEntry(doc *firestore.DocumentSnapshot) (*OutboxEntry, error) {
+	OutboxEntryModel := OutboxEntry{}
+	err := doc.DataTo(&OutboxEntryModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to outbox entry")
+	}
+
+	return &OutboxEntryModel, nil
+}
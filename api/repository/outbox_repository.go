@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxEntry is an event stored in the same transaction as the data change it describes. The ID is the ID of the
+// event. The relay publishes every entry at least once, HandledBy holds the names of the subscribers which already
+// handled it, so they don't get it again if it is published once more.
+type OutboxEntry struct {
+	ID        string    `firestore:"id" json:"id"`
+	Name      string    `firestore:"name" json:"name"`
+	PetUUID   string    `firestore:"petUuid" json:"petUuid"`
+	Payload   string    `firestore:"payload" json:"payload"`
+	CreatedAt time.Time `firestore:"createdAt" json:"createdAt"`
+	HandledBy []string  `firestore:"handledBy" json:"handledBy"`
+}
+
+// OutboxEntriesFn builds the outbox entries of a data change, it is called inside the transaction after the
+// update functions ran, so it can describe the updated data
+type OutboxEntriesFn func() ([]*OutboxEntry, error)
+
+type outboxContextKey struct{}
+
+// WithOutbox adds the entries to the context, the next transactional write of a repository using the
+// context stores them in the outbox together with its data change
+func WithOutbox(ctx context.Context, entriesFn OutboxEntriesFn) context.Context {
+	return context.WithValue(ctx, outboxContextKey{}, entriesFn)
+}
+
+type OutboxRepository interface {
+	// GetOutboxEntries returns the oldest entries of the outbox
+	GetOutboxEntries(ctx context.Context, limit int) ([]*OutboxEntry, error)
+	MarkOutboxEntryHandled(ctx context.Context, entryId string, subscriberName string) error
+	DeleteOutboxEntries(ctx context.Context, entryIds []string) error
+}
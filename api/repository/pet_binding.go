@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A medicine, food or todo is always addressed together with its pet and the user, who has to have access to the pet.
+// A missing document and the document of another pet are both reported as not found, so a UUID of another pet can't
+// be told apart from one which doesn't exist.
+
+// checkPetAccess checks that the pet exists and the user has access to it
+func checkPetAccess(ctx context.Context, firestoreClient *firestore.Client, userUid string, petUuid string) error {
+	firestorePet, err := firestoreClient.Collection("pets").Doc(petUuid).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return NewNotFoundError("pet_not_found", errors.Wrapf(err, "pet '%s' not found", petUuid))
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pet with UUID '%s'", petUuid)
+	}
+
+	pet := Pet{}
+	err = firestorePet.DataTo(&pet)
+	if err != nil {
+		return errors.Wrap(err, "unable to unmarshal document to pet")
+	}
+
+	hasAccess, err := userHasPetAccess(ctx, firestoreClient, userUid, &pet)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return &NoAccessToPetError{
+			UserUid: userUid,
+			PetUuid: petUuid,
+		}
+	}
+
+	return nil
+}
+
+// userHasPetAccess checks if the user owns the pet, the pet is shared with the user who accepted the share or
+// the user is a member of the household the pet belongs to
+func userHasPetAccess(ctx context.Context, firestoreClient *firestore.Client, userUid string, pet *Pet) (bool, error) {
+	if pet.UserUID == userUid {
+		return true, nil
+	}
+
+	if share, isShared := pet.Share(userUid); isShared && share.ShareAccepted && share.IsActiveAt(time.Now()) {
+		return true, nil
+	}
+
+	if pet.HouseholdUUID == "" {
+		return false, nil
+	}
+
+	firestoreHousehold, err := firestoreClient.Collection("households").Doc(pet.HouseholdUUID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get household '%s' of pet '%s'", pet.HouseholdUUID, pet.UUID)
+	}
+
+	household := Household{}
+	err = firestoreHousehold.DataTo(&household)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to unmarshal document to household")
+	}
+
+	return household.IsMember(userUid), nil
+}
+
+// petDocumentLoadError reports the error of loading the document of a medicine, food or todo
+func petDocumentLoadError(kind string, petUuid string, documentUuid string, err error) error {
+	if status.Code(err) == codes.NotFound {
+		return NewNotFoundError(kind+"_not_found", errors.Wrapf(err, "%s '%s' of pet '%s' not found", kind, documentUuid, petUuid))
+	}
+
+	return errors.Wrapf(err, "failed to get %s with UUID '%s'", kind, documentUuid)
+}
+
+// checkPetDocument checks that the loaded medicine, food or todo belongs to the pet
+func checkPetDocument(kind string, petUuid string, documentUuid string, documentPetUUID uuid.UUID) error {
+	if documentPetUUID.String() != petUuid {
+		return NewNotFoundError(kind+"_not_found", fmt.Errorf("%s '%s' of pet '%s' not found", kind, documentUuid, petUuid))
+	}
+
+	return nil
+}
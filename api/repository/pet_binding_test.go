@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// newEmulatorClient connects to the firestore emulator, the tests are skipped if it isn't running
+func newEmulatorClient(t *testing.T) *firestore.Client {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set, the repository tests need the firestore emulator")
+	}
+
+	client, err := firestore.NewClient(context.Background(), "fur-meds-test")
+	if err != nil {
+		t.Fatalf("failed to connect to the firestore emulator: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func addTestPet(t *testing.T, client *firestore.Client, userUid string) string {
+	t.Helper()
+	pet := &Pet{Name: "Pet of " + userUid}
+	_, err := NewPetFirestoreRepository(client).AddPet(context.Background(), userUid, pet)
+	if err != nil {
+		t.Fatalf("failed to add pet: %v", err)
+	}
+
+	return pet.UUID.String()
+}
+
+func assertErrorKind(t *testing.T, operation string, err error, kind ErrorKind) {
+	t.Helper()
+	var domainError DomainError
+	if !errors.As(err, &domainError) || domainError.Kind() != kind {
+		t.Errorf("%s: expected error of kind %s, got %v", operation, kind, err)
+	}
+}
+
+// petBindingTestSetup adds a pet of the owner and a pet of another user, who must not reach the documents of the
+// owner's pet, neither through the owner's pet nor through the own pet
+type petBindingTestSetup struct {
+	client       *firestore.Client
+	ownerUid     string
+	ownerPetUuid string
+	otherUid     string
+	otherPetUuid string
+}
+
+func newPetBindingTestSetup(t *testing.T) petBindingTestSetup {
+	client := newEmulatorClient(t)
+	ownerUid := "owner-" + uuid.NewString()
+	otherUid := "other-" + uuid.NewString()
+
+	return petBindingTestSetup{
+		client:       client,
+		ownerUid:     ownerUid,
+		ownerPetUuid: addTestPet(t, client, ownerUid),
+		otherUid:     otherUid,
+		otherPetUuid: addTestPet(t, client, otherUid),
+	}
+}
+
+func TestMedicineIsBoundToPetAndUser(t *testing.T) {
+	setup := newPetBindingTestSetup(t)
+	ctx := context.Background()
+	repository := NewMedicineFirestoreRepository(setup.client)
+
+	medicine := &Medicine{Name: "Medicine", Dosage: 1, Unit: MEDICINE_UNIT_PILLS}
+	_, err := repository.AddMedicine(ctx, setup.ownerUid, setup.ownerPetUuid, medicine)
+	if err != nil {
+		t.Fatalf("failed to add medicine: %v", err)
+	}
+	medicineUuid := medicine.UUID.String()
+	unchanged := func(ctx context.Context, medicine *Medicine) (*Medicine, error) { return medicine, nil }
+
+	_, err = repository.GetMedicine(ctx, setup.ownerUid, setup.ownerPetUuid, medicineUuid)
+	if err != nil {
+		t.Fatalf("owner failed to get medicine: %v", err)
+	}
+
+	_, err = repository.GetMedicine(ctx, setup.otherUid, setup.otherPetUuid, medicineUuid)
+	assertErrorKind(t, "get medicine of other pet", err, ERROR_KIND_NOT_FOUND)
+	_, err = repository.UpdateMedicine(ctx, setup.otherUid, setup.otherPetUuid, medicineUuid, unchanged)
+	assertErrorKind(t, "update medicine of other pet", err, ERROR_KIND_NOT_FOUND)
+	_, err = repository.DeleteMedicine(ctx, setup.otherUid, setup.otherPetUuid, medicineUuid)
+	assertErrorKind(t, "delete medicine of other pet", err, ERROR_KIND_NOT_FOUND)
+
+	_, err = repository.GetMedicine(ctx, setup.otherUid, setup.ownerPetUuid, medicineUuid)
+	assertErrorKind(t, "get medicine of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.GetMedicines(ctx, setup.otherUid, setup.ownerPetUuid)
+	assertErrorKind(t, "get medicines of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.UpdateMedicine(ctx, setup.otherUid, setup.ownerPetUuid, medicineUuid, unchanged)
+	assertErrorKind(t, "update medicine of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.DeleteMedicine(ctx, setup.otherUid, setup.ownerPetUuid, medicineUuid)
+	assertErrorKind(t, "delete medicine of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.AddMedicine(ctx, setup.otherUid, setup.ownerPetUuid, &Medicine{Name: "Medicine", Dosage: 1, Unit: MEDICINE_UNIT_PILLS})
+	assertErrorKind(t, "add medicine to foreign pet", err, ERROR_KIND_FORBIDDEN)
+}
+
+func TestFoodIsBoundToPetAndUser(t *testing.T) {
+	setup := newPetBindingTestSetup(t)
+	ctx := context.Background()
+	repository := NewFoodFirestoreRepository(setup.client)
+
+	food := &Food{Name: "Food", Dosage: 1, Unit: FOOD_UNIT_GRAMMS}
+	_, err := repository.AddFood(ctx, setup.ownerUid, setup.ownerPetUuid, food)
+	if err != nil {
+		t.Fatalf("failed to add food: %v", err)
+	}
+	foodUuid := food.UUID.String()
+	unchanged := func(ctx context.Context, food *Food) (*Food, error) { return food, nil }
+
+	_, err = repository.GetFood(ctx, setup.ownerUid, setup.ownerPetUuid, foodUuid)
+	if err != nil {
+		t.Fatalf("owner failed to get food: %v", err)
+	}
+
+	_, err = repository.GetFood(ctx, setup.otherUid, setup.otherPetUuid, foodUuid)
+	assertErrorKind(t, "get food of other pet", err, ERROR_KIND_NOT_FOUND)
+	_, err = repository.UpdateFood(ctx, setup.otherUid, setup.otherPetUuid, foodUuid, unchanged)
+	assertErrorKind(t, "update food of other pet", err, ERROR_KIND_NOT_FOUND)
+	_, err = repository.DeleteFood(ctx, setup.otherUid, setup.otherPetUuid, foodUuid)
+	assertErrorKind(t, "delete food of other pet", err, ERROR_KIND_NOT_FOUND)
+
+	_, err = repository.GetFood(ctx, setup.otherUid, setup.ownerPetUuid, foodUuid)
+	assertErrorKind(t, "get food of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.GetFoods(ctx, setup.otherUid, setup.ownerPetUuid)
+	assertErrorKind(t, "get foods of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.UpdateFood(ctx, setup.otherUid, setup.ownerPetUuid, foodUuid, unchanged)
+	assertErrorKind(t, "update food of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.DeleteFood(ctx, setup.otherUid, setup.ownerPetUuid, foodUuid)
+	assertErrorKind(t, "delete food of foreign pet", err, ERROR_KIND_FORBIDDEN)
+}
+
+func TestToDoIsBoundToPetAndUser(t *testing.T) {
+	setup := newPetBindingTestSetup(t)
+	ctx := context.Background()
+	repository := NewTodoFirestoreRepository(setup.client)
+
+	todo := &ToDo{UUID: uuid.New(), UserUID: setup.ownerUid, PetUUID: uuid.MustParse(setup.ownerPetUuid), Text: "Todo", Status: TODO_STATUS_OPEN}
+	_, err := repository.AddToDo(ctx, todo)
+	if err != nil {
+		t.Fatalf("failed to add todo: %v", err)
+	}
+	todoUuid := todo.UUID.String()
+	unchanged := func(ctx context.Context, todo *ToDo) (*ToDo, error) { return todo, nil }
+
+	_, err = repository.GetToDo(ctx, setup.ownerUid, setup.ownerPetUuid, todoUuid)
+	if err != nil {
+		t.Fatalf("owner failed to get todo: %v", err)
+	}
+
+	_, err = repository.GetToDo(ctx, setup.otherUid, setup.otherPetUuid, todoUuid)
+	assertErrorKind(t, "get todo of other pet", err, ERROR_KIND_NOT_FOUND)
+	_, err = repository.UpdateToDo(ctx, setup.otherUid, setup.otherPetUuid, todoUuid, unchanged)
+	assertErrorKind(t, "update todo of other pet", err, ERROR_KIND_NOT_FOUND)
+
+	_, err = repository.GetToDo(ctx, setup.otherUid, setup.ownerPetUuid, todoUuid)
+	assertErrorKind(t, "get todo of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.GetToDosForPet(ctx, setup.otherUid, setup.ownerPetUuid)
+	assertErrorKind(t, "get todos of foreign pet", err, ERROR_KIND_FORBIDDEN)
+	_, err = repository.UpdateToDo(ctx, setup.otherUid, setup.ownerPetUuid, todoUuid, unchanged)
+	assertErrorKind(t, "update todo of foreign pet", err, ERROR_KIND_FORBIDDEN)
+}
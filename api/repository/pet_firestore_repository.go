@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type NoAccessToPetError struct {
@@ -18,6 +21,9 @@ func (e *NoAccessToPetError) Error() string {
 	return fmt.Sprintf("user '%s' has no access to pet '%s'", e.UserUid, e.PetUuid)
 }
 
+// maxFirestoreInQueryValues is the maximum number of values firestore accepts for an 'in' query
+const maxFirestoreInQueryValues = 10
+
 type PetFirestoreRepository struct {
 	firestoreClient *firestore.Client
 }
@@ -30,15 +36,36 @@ func (r PetFirestoreRepository) petsCollection() *firestore.CollectionRef {
 	return r.firestoreClient.Collection("pets")
 }
 
+func (r PetFirestoreRepository) householdsCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("households")
+}
+
 func (r PetFirestoreRepository) AddPet(ctx context.Context, userUid string, pet *Pet) ([]*Pet, error) {
 	collection := r.petsCollection()
 
-	petUUID := uuid.New()
-	pet.UUID = petUUID
+	// the UUID may be chosen by the client, e.g. for a pet created while it was offline
+	if pet.UUID == uuid.Nil {
+		pet.UUID = uuid.New()
+	}
+	petUUID := pet.UUID
 	pet.UserUID = userUid
+	// a pet is added to a household only through the household, which checks the membership of the user
+	pet.HouseholdUUID = ""
+	stampCreated(pet, time.Now())
+	pet.mirrorSharedWithUserUids()
 
 	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		return tx.Create(collection.Doc(petUUID.String()), pet)
+		err := tx.Create(collection.Doc(petUUID.String()), pet)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_PET, ACTIVITY_ACTION_CREATED, pet.UUID, pet.UUID, userUid), nil, pet)
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to add pet")
@@ -63,23 +90,23 @@ func (r PetFirestoreRepository) GetPet(ctx context.Context, userUid string, petU
 		return nil, err
 	}
 
-	userIsOwnerOfPet := pet.UserUID == userUid
-	userIsSharedUserOfPet := false
-	if pet.SharedWithUsers != nil {
-		for _, sharedUser := range pet.SharedWithUsers {
-			if sharedUser.UserUid == userUid {
-				userIsSharedUserOfPet = true
-			}
-		}
+	hasAccess, err := r.userHasAccess(ctx, userUid, pet)
+	if err != nil {
+		return nil, err
 	}
 
-	if !userIsOwnerOfPet && !userIsSharedUserOfPet {
+	if !hasAccess {
 		return nil, &NoAccessToPetError{
 			UserUid: userUid,
 			PetUuid: petUUID,
 		}
 	}
 
+	err = r.addMedicinesAndFoods(ctx, []*Pet{pet})
+	if err != nil {
+		return nil, err
+	}
+
 	return pet, nil
 }
 
@@ -89,68 +116,211 @@ func (r PetFirestoreRepository) GetPets(ctx context.Context, userUid string) ([]
 		return nil, errors.Wrap(err, "failed to get all pets for user")
 	}
 
-	allSharedPetDocumentsForUser, err := r.petsCollection().
-		Where(
-			"sharedWithUsers",
-			"array-contains",
-			PetShares{
-				UserUid:       userUid,
-				ShareAccepted: true,
-			},
-		).
-		Documents(ctx).GetAll()
+	allSharedPetDocumentsForUser, err := r.sharedPetDocuments(ctx, userUid, true)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get all pets shared with user")
 	}
 
+	allHouseholdPetDocumentsForUser, err := r.householdPetDocuments(ctx, userUid)
+	if err != nil {
+		return nil, err
+	}
+
 	var allPets []*Pet
-	for _, pet := range allUserPetDocuments {
-		unmarshaledPet, err := r.unmarshalPet(pet)
-		if err != nil {
-			return nil, err
+	addedPets := map[string]bool{}
+	for _, documents := range [][]*firestore.DocumentSnapshot{allUserPetDocuments, allHouseholdPetDocumentsForUser} {
+		for _, pet := range documents {
+			if addedPets[pet.Ref.ID] {
+				continue
+			}
+
+			unmarshaledPet, err := r.unmarshalPet(pet)
+			if err != nil {
+				return nil, err
+			}
+			allPets = append(allPets, unmarshaledPet)
+			addedPets[pet.Ref.ID] = true
 		}
-		allPets = append(allPets, unmarshaledPet)
 	}
 
+	now := time.Now()
 	for _, pet := range allSharedPetDocumentsForUser {
+		if addedPets[pet.Ref.ID] {
+			continue
+		}
+
 		unmarshaledPet, err := r.unmarshalPet(pet)
 		if err != nil {
 			return nil, err
 		}
+
+		share, isShared := unmarshaledPet.Share(userUid)
+		if !isShared || !share.ShareAccepted || !share.IsActiveAt(now) {
+			continue
+		}
+
 		allPets = append(allPets, unmarshaledPet)
+		addedPets[pet.Ref.ID] = true
+	}
+
+	err = r.addMedicinesAndFoods(ctx, allPets)
+	if err != nil {
+		return nil, err
 	}
 
 	return allPets, nil
 }
 
-func (r PetFirestoreRepository) GetOpenSharedPets(ctx context.Context, userUid string) ([]*Pet, error) {
-	allOpenSharedPetDocumentsForUser, err := r.petsCollection().
+// addMedicinesAndFoods sets the UUIDs of the medicines and foods of the pets, which are not stored with the pets
+func (r PetFirestoreRepository) addMedicinesAndFoods(ctx context.Context, pets []*Pet) error {
+	petsByUuid := map[string]*Pet{}
+	petUuids := []string{}
+	for _, pet := range pets {
+		petsByUuid[pet.UUID.String()] = pet
+		petUuids = append(petUuids, pet.UUID.String())
+	}
+
+	for start := 0; start < len(petUuids); start += maxFirestoreInQueryValues {
+		end := start + maxFirestoreInQueryValues
+		if end > len(petUuids) {
+			end = len(petUuids)
+		}
+
+		medicineDocuments, err := r.firestoreClient.Collection("medicines").Where("petUuid", "in", petUuids[start:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return errors.Wrap(err, "failed to get medicines of pets")
+		}
+		for _, medicineDocument := range medicineDocuments {
+			medicine := Medicine{}
+			err = medicineDocument.DataTo(&medicine)
+			if err != nil {
+				return errors.Wrap(err, "unable to unmarshal document to medicine")
+			}
+			if pet, ok := petsByUuid[medicine.PetUUID.String()]; ok {
+				pet.Medicines = append(pet.Medicines, medicine.UUID)
+			}
+		}
+
+		foodDocuments, err := r.firestoreClient.Collection("foods").Where("petUuid", "in", petUuids[start:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return errors.Wrap(err, "failed to get foods of pets")
+		}
+		for _, foodDocument := range foodDocuments {
+			food := Food{}
+			err = foodDocument.DataTo(&food)
+			if err != nil {
+				return errors.Wrap(err, "unable to unmarshal document to food")
+			}
+			if pet, ok := petsByUuid[food.PetUUID.String()]; ok {
+				pet.Foods = append(pet.Foods, food.UUID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sharedPetDocuments loads the pets shared with the user. Shares without a validity period
+// are matched as a whole, time-boxed shares are found by the mirrored share UIDs of the pet.
+func (r PetFirestoreRepository) sharedPetDocuments(ctx context.Context, userUid string, shareAccepted bool) ([]*firestore.DocumentSnapshot, error) {
+	sharedPetDocuments, err := r.petsCollection().
 		Where(
 			"sharedWithUsers",
 			"array-contains",
 			PetShares{
 				UserUid:       userUid,
-				ShareAccepted: false,
+				ShareAccepted: shareAccepted,
 			},
 		).
 		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	timeBoxedSharedPetDocuments, err := r.petsCollection().Where("sharedWithUserUids", "array-contains", userUid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sharedPetDocuments, timeBoxedSharedPetDocuments...), nil
+}
+
+// householdPetDocuments loads the pets of all households the user is a member of
+func (r PetFirestoreRepository) householdPetDocuments(ctx context.Context, userUid string) ([]*firestore.DocumentSnapshot, error) {
+	householdDocuments, err := r.householdsCollection().Where("memberUids", "array-contains", userUid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get all households of user")
+	}
+
+	householdUuids := []string{}
+	for _, household := range householdDocuments {
+		householdUuids = append(householdUuids, household.Ref.ID)
+	}
+
+	var householdPetDocuments []*firestore.DocumentSnapshot
+	for start := 0; start < len(householdUuids); start += maxFirestoreInQueryValues {
+		end := start + maxFirestoreInQueryValues
+		if end > len(householdUuids) {
+			end = len(householdUuids)
+		}
+
+		petDocuments, err := r.petsCollection().Where("householdUuid", "in", householdUuids[start:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get all pets of the user's households")
+		}
+		householdPetDocuments = append(householdPetDocuments, petDocuments...)
+	}
+
+	return householdPetDocuments, nil
+}
+
+func (r PetFirestoreRepository) GetOpenSharedPets(ctx context.Context, userUid string) ([]*Pet, error) {
+	allOpenSharedPetDocumentsForUser, err := r.sharedPetDocuments(ctx, userUid, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get all open shared pets for user")
 	}
 
+	now := time.Now()
 	var resultPets []*Pet
+	addedPets := map[string]bool{}
 	for _, pet := range allOpenSharedPetDocumentsForUser {
+		if addedPets[pet.Ref.ID] {
+			continue
+		}
+
 		unmarshaledPet, err := r.unmarshalPet(pet)
 		if err != nil {
 			return nil, err
 		}
+
+		share, isShared := unmarshaledPet.Share(userUid)
+		if !isShared || share.ShareAccepted || share.IsExpiredAt(now) {
+			continue
+		}
+
 		resultPets = append(resultPets, unmarshaledPet)
+		addedPets[pet.Ref.ID] = true
 	}
 
 	return resultPets, nil
 }
 
 func (r PetFirestoreRepository) UpdatePet(ctx context.Context, userUid string, petUUID string, updateFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Pet, error) {
+	return r.updatePet(ctx, userUid, petUUID, r.userHasAccess, updateFn)
+}
+
+// UpdateInvitedPet updates the pet on behalf of a user it is shared with, no matter if the share was accepted yet.
+// It is meant for answering an invite only, the invited user has no access to the pet before accepting.
+func (r PetFirestoreRepository) UpdateInvitedPet(ctx context.Context, userUid string, petUUID string, updateFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Pet, error) {
+	isInvited := func(ctx context.Context, userUid string, pet *Pet) (bool, error) {
+		_, isShared := pet.Share(userUid)
+		return isShared, nil
+	}
+
+	return r.updatePet(ctx, userUid, petUUID, isInvited, updateFn)
+}
+
+func (r PetFirestoreRepository) updatePet(ctx context.Context, userUid string, petUUID string, hasAccessFn func(ctx context.Context, userUid string, pet *Pet) (bool, error), updateFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Pet, error) {
 	petsCollection := r.petsCollection()
 
 	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
@@ -165,29 +335,45 @@ func (r PetFirestoreRepository) UpdatePet(ctx context.Context, userUid string, p
 		if err != nil {
 			return err
 		}
-		userIsOwnerOfPet := pet.UserUID == userUid
-		userIsSharedUserOfPet := false
-		if pet.SharedWithUsers != nil {
-			for _, sharedUser := range pet.SharedWithUsers {
-				if sharedUser.UserUid == userUid {
-					userIsSharedUserOfPet = true
-				}
-			}
+		beforePet, err := r.unmarshalPet(firestorePet)
+		if err != nil {
+			return err
+		}
+		hasAccess, err := hasAccessFn(ctx, userUid, pet)
+		if err != nil {
+			return err
 		}
 
-		if !userIsOwnerOfPet && !userIsSharedUserOfPet {
+		if !hasAccess {
 			return &NoAccessToPetError{
 				UserUid: userUid,
 				PetUuid: petUUID,
 			}
 		}
 
+		err = checkIfMatch(ctx, petUUID, pet.Version)
+		if err != nil {
+			return err
+		}
+
 		updatedPet, err := updateFn(ctx, pet)
 		if err != nil {
 			return err
 		}
+		updatedPet.mirrorSharedWithUserUids()
+		stampUpdated(beforePet, updatedPet, time.Now())
+
+		err = tx.Set(documentRef, updatedPet)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_PET, ACTIVITY_ACTION_UPDATED, updatedPet.UUID, updatedPet.UUID, userUid), beforePet, updatedPet)
+		if err != nil {
+			return err
+		}
 
-		return tx.Set(documentRef, updatedPet)
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update pet")
@@ -202,23 +388,50 @@ func (r PetFirestoreRepository) UpdatePet(ctx context.Context, userUid string, p
 }
 
 func (r PetFirestoreRepository) DeletePet(ctx context.Context, userUid string, petUUID string) ([]*Pet, error) {
-	firestorePet, err := r.petsCollection().Doc(petUUID).Get(ctx)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to load pet with UUID '%s' before deletion", petUUID)
-	}
+	// the pet is moved to the trash, its medicines, foods and todos are kept until the pet is purged
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.petsCollection().Doc(petUUID)
 
-	pet, err := r.unmarshalPet(firestorePet)
-	if err != nil {
-		return nil, err
-	}
-	if pet.UserUID != userUid {
-		return nil, &NoAccessToPetError{
-			UserUid: userUid,
-			PetUuid: petUUID,
+		firestorePet, err := tx.Get(documentRef)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load pet with UUID '%s' before deletion", petUUID)
+		}
+
+		pet, err := r.unmarshalPet(firestorePet)
+		if err != nil {
+			return err
+		}
+		if pet.UserUID != userUid {
+			return &NoAccessToPetError{
+				UserUid: userUid,
+				PetUuid: petUUID,
+			}
+		}
+
+		err = checkIfMatch(ctx, petUUID, pet.Version)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(documentRef)
+		if err != nil {
+			return err
+		}
+
+		trashItem := newTrashItem(TRASH_KIND_PET, pet.UUID, pet.UUID, userUid, time.Now())
+		trashItem.Pet = pet
+		err = writeTrashItem(r.firestoreClient, tx, trashItem)
+		if err != nil {
+			return err
+		}
+
+		err = writeActivity(r.firestoreClient, tx, newActivity(ACTIVITY_KIND_PET, ACTIVITY_ACTION_DELETED, pet.UUID, pet.UUID, userUid), pet, nil)
+		if err != nil {
+			return err
 		}
-	}
 
-	_, err = r.petsCollection().Doc(petUUID).Delete(ctx)
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to delete pet with UUID '%s'", petUUID)
 	}
@@ -248,6 +461,57 @@ func (r PetFirestoreRepository) UserHasAccessToPet(ctx context.Context, userUid
 	return false, errors.New("something went wrong on checking if user has access to pet")
 }
 
+// GetPetCaretakers loads the pet together with the UIDs of all users currently caring for it, which are
+// the owner, the users with an accepted and active share and the members of the pet's household
+func (r PetFirestoreRepository) GetPetCaretakers(ctx context.Context, petUuid string) (*Pet, []string, error) {
+	firestorePet, err := r.petsCollection().Doc(petUuid).Get(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get pet with UUID '%s'", petUuid)
+	}
+
+	pet, err := r.unmarshalPet(firestorePet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	caretakers := []string{pet.UserUID}
+	isCaretaker := map[string]bool{pet.UserUID: true}
+	for _, share := range pet.SharedWithUsers {
+		if share.ShareAccepted && share.IsActiveAt(now) && !isCaretaker[share.UserUid] {
+			caretakers = append(caretakers, share.UserUid)
+			isCaretaker[share.UserUid] = true
+		}
+	}
+
+	if pet.HouseholdUUID != "" {
+		firestoreHousehold, err := r.householdsCollection().Doc(pet.HouseholdUUID).Get(ctx)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return nil, nil, errors.Wrapf(err, "failed to get household '%s' of pet '%s'", pet.HouseholdUUID, petUuid)
+		}
+		if err == nil {
+			household := Household{}
+			err = firestoreHousehold.DataTo(&household)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "unable to unmarshal document to household")
+			}
+
+			for _, member := range household.Members {
+				if !isCaretaker[member.UserUid] {
+					caretakers = append(caretakers, member.UserUid)
+					isCaretaker[member.UserUid] = true
+				}
+			}
+		}
+	}
+
+	return pet, caretakers, nil
+}
+
+func (r PetFirestoreRepository) userHasAccess(ctx context.Context, userUid string, pet *Pet) (bool, error) {
+	return userHasPetAccess(ctx, r.firestoreClient, userUid, pet)
+}
+
 func (r PetFirestoreRepository) unmarshalPet(doc *firestore.DocumentSnapshot) (*Pet, error) {
 	PetModel := Pet{}
 	err := doc.DataTo(&PetModel)
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,8 +15,27 @@ const (
 	ANIMAL_SPECIES_OTHER AnimalSpecies = "Other"
 )
 
+func (s AnimalSpecies) IsValid() bool {
+	return s == ANIMAL_SPECIES_CAT || s == ANIMAL_SPECIES_DOG || s == ANIMAL_SPECIES_OTHER
+}
+
+// PetRole is the role of a user with access to a pet, the owner may do everything a caretaker may do
+type PetRole string
+
+const (
+	PET_ROLE_OWNER     PetRole = "Owner"
+	PET_ROLE_CARETAKER PetRole = "Caretaker"
+)
+
+// Allows tells if the role includes the permissions of the required role
+func (r PetRole) Allows(requiredRole PetRole) bool {
+	return r == PET_ROLE_OWNER || r == requiredRole
+}
+
 type SharePetInviteRequest struct {
-	UserMailToInvite string `json:"userMailToInvite"`
+	UserMailToInvite string     `json:"userMailToInvite"`
+	ValidFrom        *time.Time `json:"validFrom,omitempty"`
+	ValidUntil       *time.Time `json:"validUntil,omitempty"`
 }
 
 type PetShareAnswer string
@@ -32,23 +52,135 @@ type AnswerPetShareRequest struct {
 type PetShares struct {
 	UserUid       string `firestore:"userUid" json:"userUid"`
 	ShareAccepted bool   `firestore:"shareAccepted" json:"shareAccepted"`
+	// ValidFrom and ValidUntil limit the share to a period of time, e.g. for a pet sitter
+	ValidFrom  *time.Time `firestore:"validFrom,omitempty" json:"validFrom,omitempty"`
+	ValidUntil *time.Time `firestore:"validUntil,omitempty" json:"validUntil,omitempty"`
+	InvitedAt  *time.Time `firestore:"invitedAt,omitempty" json:"invitedAt,omitempty"`
+}
+
+// IsActiveAt checks if the share is within its validity period at the given time
+func (s PetShares) IsActiveAt(t time.Time) bool {
+	if s.ValidFrom != nil && t.Before(*s.ValidFrom) {
+		return false
+	}
+	if s.ValidUntil != nil && !t.Before(*s.ValidUntil) {
+		return false
+	}
+
+	return true
+}
+
+// IsExpiredAt checks if the validity period of the share has ended at the given time
+func (s PetShares) IsExpiredAt(t time.Time) bool {
+	return s.ValidUntil != nil && !t.Before(*s.ValidUntil)
+}
+
+type PetArchiveReason string
+
+const (
+	PET_ARCHIVE_REASON_DECEASED PetArchiveReason = "Deceased"
+	PET_ARCHIVE_REASON_REHOMED  PetArchiveReason = "Rehomed"
+	PET_ARCHIVE_REASON_OTHER    PetArchiveReason = "Other"
+)
+
+func (r PetArchiveReason) IsValid() bool {
+	return r == PET_ARCHIVE_REASON_DECEASED || r == PET_ARCHIVE_REASON_REHOMED || r == PET_ARCHIVE_REASON_OTHER
+}
+
+// PetArchive tells since when and why a pet is archived, e.g. because it passed away. An archived pet is kept as a
+// read-only record, it has no reminders or todos anymore.
+type PetArchive struct {
+	ArchivedAt time.Time        `firestore:"archivedAt" json:"archivedAt"`
+	Reason     PetArchiveReason `firestore:"reason" json:"reason" validate:"required,enum"`
+}
+
+type VetContact struct {
+	Name    string `firestore:"name" json:"name" validate:"required,max=100"`
+	Phone   string `firestore:"phone" json:"phone,omitempty"`
+	Email   string `firestore:"email" json:"email,omitempty" validate:"omitempty,email"`
+	Address string `firestore:"address" json:"address,omitempty"`
 }
 
 type Pet struct {
 	UUID            uuid.UUID   `firestore:"uuid" json:"uuid"`
 	UserUID         string      `firestore:"userUid" json:"userUid"`
 	SharedWithUsers []PetShares `firestore:"sharedWithUsers" json:"sharedWithUsers"`
-	Name            string      `firestore:"name" json:"name"`
+	// SharedWithUserUids mirrors the UIDs of SharedWithUsers, it is needed to query shares with a validity period
+	SharedWithUserUids []string `firestore:"sharedWithUserUids" json:"-"`
+	Name               string   `firestore:"name" json:"name" validate:"required,max=100"`
+	HouseholdUUID      string   `firestore:"householdUuid" json:"householdUuid,omitempty" validate:"omitempty,uuid"`
+
+	Species AnimalSpecies `firestore:"species" json:"species,omitempty" validate:"omitempty,enum"`
+	Image   string        `firestore:"image" json:"image,omitempty"`
+	// Medicines and Foods are not stored with the pet, they are derived from the medicines and foods of the pet on reading it
+	Medicines []uuid.UUID `firestore:"-" json:"medicines,omitempty"`
+	Foods     []uuid.UUID `firestore:"-" json:"foods,omitempty"`
+
+	VetContacts    []VetContact `firestore:"vetContacts" json:"vetContacts,omitempty" validate:"dive"`
+	EmergencyNotes string       `firestore:"emergencyNotes" json:"emergencyNotes,omitempty"`
+
+	Archive *PetArchive `firestore:"archive,omitempty" json:"archive,omitempty"`
+
+	UpdatedAt time.Time `firestore:"updatedAt" json:"updatedAt"`
+	// Version is incremented on every change, FieldChanges tells in which version each field was changed last
+	Version      int                    `firestore:"version" json:"version"`
+	FieldChanges map[string]FieldChange `firestore:"fieldChanges" json:"-"`
+}
+
+// Share returns the share of the pet with the given user, if there is one
+func (p *Pet) Share(userUid string) (PetShares, bool) {
+	for _, share := range p.SharedWithUsers {
+		if share.UserUid == userUid {
+			return share, true
+		}
+	}
+
+	return PetShares{}, false
+}
+
+// Role returns the role of a user with access to the pet, everyone besides the owner cares for the pet through a
+// share or the household
+func (p *Pet) Role(userUid string) PetRole {
+	if p.UserUID == userUid {
+		return PET_ROLE_OWNER
+	}
+
+	return PET_ROLE_CARETAKER
+}
+
+func (p *Pet) IsArchived() bool {
+	return p.Archive != nil
+}
+
+func (p *Pet) mirrorSharedWithUserUids() {
+	p.SharedWithUserUids = []string{}
+	for _, share := range p.SharedWithUsers {
+		p.SharedWithUserUids = append(p.SharedWithUserUids, share.UserUid)
+	}
+}
+
+type PetSummary struct {
+	UUID    uuid.UUID     `json:"uuid"`
+	Name    string        `json:"name"`
+	Species AnimalSpecies `json:"species,omitempty"`
+	Image   string        `json:"image,omitempty"`
+}
 
-	Species   AnimalSpecies `firestore:"species" json:"species,omitempty"`
-	Image     string        `firestore:"image" json:"image,omitempty"`
-	Medicines []uuid.UUID   `firestore:"medicines" json:"medicines,omitempty"`
-	Foods     []uuid.UUID   `firestore:"foods" json:"foods,omitempty"`
+func (p *Pet) Summary() PetSummary {
+	return PetSummary{
+		UUID:    p.UUID,
+		Name:    p.Name,
+		Species: p.Species,
+		Image:   p.Image,
+	}
 }
 
-type PetShareInvites struct {
-	Pet        Pet    `json:"pet"`
-	OwnerEmail string `json:"ownerEmail"`
+type PetShareInvite struct {
+	Pet        PetSummary `json:"pet"`
+	OwnerEmail string     `json:"ownerEmail"`
+	InvitedAt  *time.Time `json:"invitedAt,omitempty"`
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
 }
 
 type PetRepository interface {
@@ -57,6 +189,8 @@ type PetRepository interface {
 	GetPets(ctx context.Context, userUid string) ([]*Pet, error)
 	GetOpenSharedPets(ctx context.Context, userUid string) ([]*Pet, error)
 	UpdatePet(ctx context.Context, userUid string, petUUID string, updateFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Pet, error)
+	UpdateInvitedPet(ctx context.Context, userUid string, petUUID string, updateFn func(ctx context.Context, pet *Pet) (*Pet, error)) ([]*Pet, error)
 	DeletePet(ctx context.Context, userUid string, petUUID string) ([]*Pet, error)
 	UserHasAccessToPet(ctx context.Context, userUid string, petUuid string) (bool, error)
+	GetPetCaretakers(ctx context.Context, petUuid string) (*Pet, []string, error)
 }
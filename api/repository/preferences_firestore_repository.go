@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PreferencesFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewPreferencesFirestoreRepository(firestoreClient *firestore.Client) PreferencesRepository {
+	return PreferencesFirestoreRepository{firestoreClient}
+}
+
+func (r PreferencesFirestoreRepository) preferencesCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("preferences")
+}
+
+func (r PreferencesFirestoreRepository) GetPreferences(ctx context.Context, userUid string) (*UserPreferences, error) {
+	firestorePreferences, err := r.preferencesCollection().Doc(userUid).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return &UserPreferences{UserUID: userUid, Notifications: map[string]NotificationPreference{}}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get preferences of user '%s'", userUid)
+	}
+
+	return r.unmarshalPreferences(firestorePreferences)
+}
+
+func (r PreferencesFirestoreRepository) SetPreferences(ctx context.Context, userUid string, preferences *UserPreferences) (*UserPreferences, error) {
+	preferences.UserUID = userUid
+	if preferences.Notifications == nil {
+		preferences.Notifications = map[string]NotificationPreference{}
+	}
+
+	_, err := r.preferencesCollection().Doc(userUid).Set(ctx, preferences)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set preferences of user '%s'", userUid)
+	}
+
+	return r.GetPreferences(ctx, userUid)
+}
+
+func (r PreferencesFirestoreRepository) unmarshalPreferences(doc *firestore.DocumentSnapshot) (*UserPreferences, error) {
+	PreferencesModel := UserPreferences{}
+	err := doc.DataTo(&PreferencesModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to user preferences")
+	}
+
+	return &PreferencesModel, nil
+}
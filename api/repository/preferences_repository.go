@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+)
+
+// QuietHours is a daily period in the user's timezone in which notifications are held back, times are formatted as HH:MM.
+// The period may span midnight, e.g. from 22:00 until 07:00.
+type QuietHours struct {
+	Start string `firestore:"start" json:"start"`
+	End   string `firestore:"end" json:"end"`
+}
+
+// NotificationPreference configures the delivery of one notification type. Without any channels, the
+// notifications of the type are sent on all channels. Disabled notifications aren't sent at all.
+type NotificationPreference struct {
+	Disabled   bool        `firestore:"disabled" json:"disabled"`
+	Channels   []string    `firestore:"channels" json:"channels"`
+	QuietHours *QuietHours `firestore:"quietHours" json:"quietHours,omitempty"`
+}
+
+// DigestPreference configures the daily digest, which batches all non-urgent notifications of a day
+// into one message sent at Time (HH:MM) in the user's timezone
+type DigestPreference struct {
+	Enabled  bool     `firestore:"enabled" json:"enabled"`
+	Time     string   `firestore:"time" json:"time"`
+	Channels []string `firestore:"channels" json:"channels"`
+}
+
+type UserPreferences struct {
+	UserUID  string `firestore:"userUid" json:"userUid"`
+	Language string `firestore:"language" json:"language"`
+	// Timezone is an IANA timezone name like "Europe/Berlin", it is used for quiet hours and the digest
+	Timezone string `firestore:"timezone" json:"timezone"`
+	// Notifications holds the preferences per notification type, types without preferences use the defaults
+	Notifications map[string]NotificationPreference `firestore:"notifications" json:"notifications"`
+	Digest        DigestPreference                  `firestore:"digest" json:"digest"`
+}
+
+// NotificationPreference returns the preference of the notification type or the default if the user didn't configure it
+func (p *UserPreferences) NotificationPreference(notificationType string) NotificationPreference {
+	preference, ok := p.Notifications[notificationType]
+	if !ok {
+		return NotificationPreference{}
+	}
+
+	return preference
+}
+
+type PreferencesRepository interface {
+	// GetPreferences returns the preferences of the user, users who never saved any get the defaults
+	GetPreferences(ctx context.Context, userUid string) (*UserPreferences, error)
+	SetPreferences(ctx context.Context, userUid string, preferences *UserPreferences) (*UserPreferences, error)
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tombstonesCollection  = "tombstones"
+	mutationLogCollection = "mutationLog"
+)
+
+// writeTransactionRecords stores the records kept together with a data change, which are the outbox entries and the
+// mutation log entry of the context. It is called by every transactional write of a document.
+func writeTransactionRecords(ctx context.Context, firestoreClient *firestore.Client, tx *firestore.Transaction) error {
+	err := writeOutbox(ctx, firestoreClient, tx)
+	if err != nil {
+		return err
+	}
+
+	return writeMutationLog(ctx, firestoreClient, tx)
+}
+
+func writeMutationLog(ctx context.Context, firestoreClient *firestore.Client, tx *firestore.Transaction) error {
+	entryFn, ok := ctx.Value(mutationLogContextKey{}).(MutationLogEntryFn)
+	if !ok {
+		return nil
+	}
+
+	entry := entryFn()
+	if entry == nil {
+		return nil
+	}
+
+	// the entry is created, so a mutation which was applied concurrently fails the whole transaction as already existing
+	err := tx.Create(firestoreClient.Collection(mutationLogCollection).Doc(entry.ID), entry)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write result of mutation '%s' to the mutation log", entry.Result.MutationID)
+	}
+
+	return nil
+}
+
+// writeTombstone records the deletion of a document in the transaction which deletes it
+func writeTombstone(firestoreClient *firestore.Client, tx *firestore.Transaction, kind TombstoneKind, documentUuid uuid.UUID, petUuid uuid.UUID) error {
+	deletedAt := time.Now()
+	err := tx.Set(firestoreClient.Collection(tombstonesCollection).Doc(documentUuid.String()), Tombstone{
+		UUID:        documentUuid,
+		Kind:        kind,
+		PetUUID:     petUuid,
+		DeletedAt:   deletedAt,
+		DeleteAfter: deletedAt.Add(TombstoneRetention),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to write tombstone of %s '%s'", kind, documentUuid)
+	}
+
+	return nil
+}
+
+type SyncFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewSyncFirestoreRepository(firestoreClient *firestore.Client) SyncRepository {
+	return SyncFirestoreRepository{firestoreClient}
+}
+
+func (r SyncFirestoreRepository) syncCursorsCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("syncCursors")
+}
+
+func (r SyncFirestoreRepository) tombstonesCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection(tombstonesCollection)
+}
+
+func (r SyncFirestoreRepository) mutationLogCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection(mutationLogCollection)
+}
+
+func (r SyncFirestoreRepository) GetSyncCursor(ctx context.Context, cursorId string) (*SyncCursor, error) {
+	firestoreCursor, err := r.syncCursorsCollection().Doc(cursorId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get sync cursor '%s'", cursorId)
+	}
+
+	SyncCursorModel := SyncCursor{}
+	err = firestoreCursor.DataTo(&SyncCursorModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to sync cursor")
+	}
+
+	return &SyncCursorModel, nil
+}
+
+func (r SyncFirestoreRepository) AddSyncCursor(ctx context.Context, cursor *SyncCursor) error {
+	_, err := r.syncCursorsCollection().Doc(cursor.ID).Create(ctx, cursor)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add sync cursor '%s'", cursor.ID)
+	}
+
+	return nil
+}
+
+// GetTombstones loads the deletions of the pet's documents after the given time
+func (r SyncFirestoreRepository) GetTombstones(ctx context.Context, petUuid string, since time.Time) ([]*Tombstone, error) {
+	tombstoneDocuments, err := r.tombstonesCollection().
+		Where("petUuid", "==", petUuid).
+		Where("deletedAt", ">", since).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get tombstones for pet %s", petUuid)
+	}
+
+	tombstones := []*Tombstone{}
+	for _, tombstone := range tombstoneDocuments {
+		TombstoneModel := Tombstone{}
+		err := tombstone.DataTo(&TombstoneModel)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal document to tombstone")
+		}
+		tombstones = append(tombstones, &TombstoneModel)
+	}
+
+	return tombstones, nil
+}
+
+func (r SyncFirestoreRepository) GetMutationResult(ctx context.Context, userUid string, mutationId string) (*MutationResult, error) {
+	firestoreEntry, err := r.mutationLogCollection().Doc(MutationLogID(userUid, mutationId)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get result of mutation '%s'", mutationId)
+	}
+
+	MutationLogEntryModel := MutationLogEntry{}
+	err = firestoreEntry.DataTo(&MutationLogEntryModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to mutation log entry")
+	}
+
+	return &MutationLogEntryModel.Result, nil
+}
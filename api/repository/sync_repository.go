@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TombstoneKind string
+
+const (
+	TOMBSTONE_KIND_PET      TombstoneKind = "Pet"
+	TOMBSTONE_KIND_MEDICINE TombstoneKind = "Medicine"
+	TOMBSTONE_KIND_FOOD     TombstoneKind = "Food"
+	TOMBSTONE_KIND_TODO     TombstoneKind = "ToDo"
+)
+
+// TombstoneRetention is how long deletions are kept for the sync, a client which didn't sync for longer
+// gets a full reset instead of the changes
+const TombstoneRetention = 30 * 24 * time.Hour
+
+// Tombstone records the deletion of a document, so offline clients can remove it on their next sync
+type Tombstone struct {
+	UUID        uuid.UUID     `firestore:"uuid" json:"uuid"`
+	Kind        TombstoneKind `firestore:"kind" json:"kind"`
+	PetUUID     uuid.UUID     `firestore:"petUuid" json:"petUuid"`
+	DeletedAt   time.Time     `firestore:"deletedAt" json:"deletedAt"`
+	DeleteAfter time.Time     `firestore:"deleteAfter" json:"-"`
+}
+
+// SyncCursor remembers up to when a client of the user synced and which pets the user had access to at that time
+type SyncCursor struct {
+	ID          string    `firestore:"id" json:"id"`
+	UserUID     string    `firestore:"userUid" json:"userUid"`
+	Since       time.Time `firestore:"since" json:"since"`
+	PetUuids    []string  `firestore:"petUuids" json:"petUuids"`
+	CreatedAt   time.Time `firestore:"createdAt" json:"createdAt"`
+	DeleteAfter time.Time `firestore:"deleteAfter" json:"-"`
+}
+
+// SyncChanges are the changes since the cursor of the request. If Reset is set, the client has to replace all
+// of its data with the returned documents. Cursor has to be sent with the next sync. Todos expire without a
+// tombstone, clients drop them once their deleteAfter passed.
+type SyncChanges struct {
+	Cursor     string       `json:"cursor"`
+	Reset      bool         `json:"reset"`
+	Pets       []*Pet       `json:"pets"`
+	Medicines  []*Medicine  `json:"medicines"`
+	Foods      []*Food      `json:"foods"`
+	ToDos      []*ToDo      `json:"todos"`
+	Tombstones []*Tombstone `json:"tombstones"`
+}
+
+type MutationKind string
+
+const (
+	MUTATION_KIND_PET      MutationKind = "Pet"
+	MUTATION_KIND_MEDICINE MutationKind = "Medicine"
+	MUTATION_KIND_FOOD     MutationKind = "Food"
+	MUTATION_KIND_DOSE     MutationKind = "Dose"
+	MUTATION_KIND_TODO     MutationKind = "ToDo"
+)
+
+type MutationOperation string
+
+const (
+	MUTATION_OPERATION_CREATE MutationOperation = "Create"
+	MUTATION_OPERATION_UPDATE MutationOperation = "Update"
+	MUTATION_OPERATION_DELETE MutationOperation = "Delete"
+)
+
+type ConflictStrategy string
+
+const (
+	// CONFLICT_STRATEGY_REJECT rejects a mutation if one of its fields was changed on the server since its base version
+	CONFLICT_STRATEGY_REJECT ConflictStrategy = "Reject"
+	// CONFLICT_STRATEGY_LAST_WRITER_WINS keeps the value of a conflicting field which was changed last
+	CONFLICT_STRATEGY_LAST_WRITER_WINS ConflictStrategy = "LastWriterWins"
+)
+
+// Mutation is a change the client made while it was offline. ID is generated by the client and identifies the mutation
+// on retries. BaseVersion is the version of the document the client changed, MutatedAt the time the client changed it.
+// Fields holds the changed fields by their JSON names, a dose is recorded by the fields medicineUuid, frequencyUuid and dueAt.
+type Mutation struct {
+	ID           string                     `json:"id"`
+	Kind         MutationKind               `json:"kind"`
+	Operation    MutationOperation          `json:"operation"`
+	DocumentUUID uuid.UUID                  `json:"documentUuid"`
+	PetUUID      uuid.UUID                  `json:"petUuid"`
+	BaseVersion  int                        `json:"baseVersion"`
+	MutatedAt    time.Time                  `json:"mutatedAt"`
+	Fields       map[string]json.RawMessage `json:"fields"`
+}
+
+type MutationBatch struct {
+	ConflictStrategy ConflictStrategy `json:"conflictStrategy"`
+	Mutations        []*Mutation      `json:"mutations"`
+}
+
+type MutationStatus string
+
+const (
+	MUTATION_STATUS_APPLIED MutationStatus = "Applied"
+	// MUTATION_STATUS_MERGED means the mutation was applied, but some of its fields lost against newer changes on the server
+	MUTATION_STATUS_MERGED   MutationStatus = "Merged"
+	MUTATION_STATUS_CONFLICT MutationStatus = "Conflict"
+	MUTATION_STATUS_FAILED   MutationStatus = "Failed"
+)
+
+// FieldConflict is a field of a mutation which was changed on the server after the base version of the mutation
+type FieldConflict struct {
+	Field           string          `firestore:"field" json:"field"`
+	ClientValue     json.RawMessage `firestore:"clientValue" json:"clientValue"`
+	ServerValue     json.RawMessage `firestore:"serverValue" json:"serverValue"`
+	ServerChangedAt time.Time       `firestore:"serverChangedAt" json:"serverChangedAt"`
+	ServerVersion   int             `firestore:"serverVersion" json:"serverVersion"`
+}
+
+// MutationResult reports the outcome of a mutation, on conflicts it carries the current document of the server
+type MutationResult struct {
+	MutationID     string          `firestore:"mutationId" json:"mutationId"`
+	Status         MutationStatus  `firestore:"status" json:"status"`
+	Kind           MutationKind    `firestore:"kind" json:"kind"`
+	DocumentUUID   uuid.UUID       `firestore:"documentUuid" json:"documentUuid"`
+	Version        int             `firestore:"version" json:"version,omitempty"`
+	Conflicts      []FieldConflict `firestore:"conflicts" json:"conflicts,omitempty"`
+	ServerDocument interface{}     `firestore:"-" json:"serverDocument,omitempty"`
+	// ErrorCode and Error tell why a mutation failed, Error is a generic message for errors of the server
+	ErrorCode string `firestore:"errorCode" json:"errorCode,omitempty"`
+	Error     string `firestore:"error" json:"error,omitempty"`
+}
+
+// MutationRetention is how long the results of applied mutations are kept to answer retries of their batch
+const MutationRetention = 30 * 24 * time.Hour
+
+// mutationsNamespace is used to derive the IDs of the mutation log entries from the IDs the clients chose
+var mutationsNamespace = uuid.MustParse("3b0f3c52-8c1e-4d5f-9f6a-6a2d9e7c4b18")
+
+// MutationLogEntry stores the result of an applied mutation together with its data change
+type MutationLogEntry struct {
+	ID          string         `firestore:"id"`
+	UserUID     string         `firestore:"userUid"`
+	Result      MutationResult `firestore:"result"`
+	CreatedAt   time.Time      `firestore:"createdAt"`
+	DeleteAfter time.Time      `firestore:"deleteAfter"`
+}
+
+// MutationLogID derives the ID of the log entry of a mutation, the IDs chosen by the clients are only unique per user
+func MutationLogID(userUid string, mutationId string) string {
+	return uuid.NewSHA1(mutationsNamespace, []byte(userUid+"/"+mutationId)).String()
+}
+
+// MutationLogEntryFn builds the log entry of a mutation, it is called inside the transaction after the update functions ran
+type MutationLogEntryFn func() *MutationLogEntry
+
+type mutationLogContextKey struct{}
+
+// WithMutationLog adds the log entry to the context, the next transactional write of a repository using the
+// context stores it together with its data change, so a retried mutation is never applied twice
+func WithMutationLog(ctx context.Context, entryFn MutationLogEntryFn) context.Context {
+	return context.WithValue(ctx, mutationLogContextKey{}, entryFn)
+}
+
+type SyncRepository interface {
+	// GetSyncCursor returns nil if the cursor doesn't exist (anymore)
+	GetSyncCursor(ctx context.Context, cursorId string) (*SyncCursor, error)
+	AddSyncCursor(ctx context.Context, cursor *SyncCursor) error
+	GetTombstones(ctx context.Context, petUuid string, since time.Time) ([]*Tombstone, error)
+	// GetMutationResult returns the result of an already applied mutation of the user, or nil if it wasn't applied yet
+	GetMutationResult(ctx context.Context, userUid string, mutationId string) (*MutationResult, error)
+}
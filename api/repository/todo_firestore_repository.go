@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ToDoFirestoreRepository struct {
@@ -19,7 +22,51 @@ func (r ToDoFirestoreRepository) todosCollection() *firestore.CollectionRef {
 	return r.firestoreClient.Collection("todos")
 }
 
-func (r ToDoFirestoreRepository) GetToDosForPet(ctx context.Context, petUuid string) ([]*ToDo, error) {
+// AddToDo creates the todo and tells if it was created, a todo which already exists is left untouched
+func (r ToDoFirestoreRepository) AddToDo(ctx context.Context, todo *ToDo) (bool, error) {
+	stampCreated(todo, time.Now())
+	_, err := r.todosCollection().Doc(todo.UUID.String()).Create(ctx, todo)
+	if status.Code(err) == codes.AlreadyExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to add todo '%s'", todo.UUID)
+	}
+
+	return true, nil
+}
+
+// GetToDo loads the todo of the pet, the todo of another pet is reported as not found
+func (r ToDoFirestoreRepository) GetToDo(ctx context.Context, userUid string, petUuid string, todoUuid string) (*ToDo, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	firestoreToDo, err := r.todosCollection().Doc(todoUuid).Get(ctx)
+	if err != nil {
+		return nil, petDocumentLoadError("todo", petUuid, todoUuid, err)
+	}
+
+	todo, err := r.unmarshalToDo(firestoreToDo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkPetDocument("todo", petUuid, todoUuid, todo.PetUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+func (r ToDoFirestoreRepository) GetToDosForPet(ctx context.Context, userUid string, petUuid string) ([]*ToDo, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
 	petToDoDocuments, err := r.todosCollection().Where("petUuid", "==", petUuid).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get all todos for pet %s", petUuid)
@@ -37,6 +84,107 @@ func (r ToDoFirestoreRepository) GetToDosForPet(ctx context.Context, petUuid str
 	return petToDos, nil
 }
 
+// GetToDosForPetChangedSince loads the todos of the pet which were created or changed after the given time
+func (r ToDoFirestoreRepository) GetToDosForPetChangedSince(ctx context.Context, userUid string, petUuid string, since time.Time) ([]*ToDo, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	changedToDoDocuments, err := r.todosCollection().
+		Where("petUuid", "==", petUuid).
+		Where("updatedAt", ">", since).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get changed todos for pet %s", petUuid)
+	}
+
+	changedToDos := []*ToDo{}
+	for _, todo := range changedToDoDocuments {
+		unmarshaledToDo, err := r.unmarshalToDo(todo)
+		if err != nil {
+			return nil, err
+		}
+		changedToDos = append(changedToDos, unmarshaledToDo)
+	}
+
+	return changedToDos, nil
+}
+
+// GetOpenToDosDueBetween loads the open todos which were due in the given period, older todos aren't loaded, so
+// the todos missed long ago don't slow down every run of the scheduler
+func (r ToDoFirestoreRepository) GetOpenToDosDueBetween(ctx context.Context, dueAfter time.Time, dueBefore time.Time) ([]*ToDo, error) {
+	openToDoDocuments, err := r.todosCollection().
+		Where("status", "==", TODO_STATUS_OPEN).
+		Where("dueAt", ">=", dueAfter).
+		Where("dueAt", "<=", dueBefore).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get open todos")
+	}
+
+	openToDos := []*ToDo{}
+	for _, todo := range openToDoDocuments {
+		unmarshaledToDo, err := r.unmarshalToDo(todo)
+		if err != nil {
+			return nil, err
+		}
+		openToDos = append(openToDos, unmarshaledToDo)
+	}
+
+	return openToDos, nil
+}
+
+func (r ToDoFirestoreRepository) UpdateToDo(ctx context.Context, userUid string, petUuid string, todoUuid string, updateFn func(ctx context.Context, todo *ToDo) (*ToDo, error)) (*ToDo, error) {
+	err := checkPetAccess(ctx, r.firestoreClient, userUid, petUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedToDo *ToDo
+
+	err = r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.todosCollection().Doc(todoUuid)
+
+		firestoreToDo, err := tx.Get(documentRef)
+		if err != nil {
+			return petDocumentLoadError("todo", petUuid, todoUuid, err)
+		}
+
+		todo, err := r.unmarshalToDo(firestoreToDo)
+		if err != nil {
+			return err
+		}
+		beforeToDo, err := r.unmarshalToDo(firestoreToDo)
+		if err != nil {
+			return err
+		}
+
+		err = checkPetDocument("todo", petUuid, todoUuid, todo.PetUUID)
+		if err != nil {
+			return err
+		}
+
+		updatedToDo, err = updateFn(ctx, todo)
+		if err != nil {
+			return err
+		}
+
+		stampUpdated(beforeToDo, updatedToDo, time.Now())
+		err = tx.Set(documentRef, updatedToDo)
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update todo")
+	}
+
+	return updatedToDo, nil
+}
+
 func (r ToDoFirestoreRepository) unmarshalToDo(doc *firestore.DocumentSnapshot) (*ToDo, error) {
 	ToDoModel := ToDo{}
 	err := doc.DataTo(&ToDoModel)
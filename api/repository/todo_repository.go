@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +22,45 @@ type ToDo struct {
 	Text        string     `firestore:"text" json:"text"`
 	Status      ToDoStatus `firestore:"status" json:"status"`
 	DeleteAfter time.Time  `firestore:"deleteAfter" json:"deleteAfter"`
+
+	MedicineUUID  uuid.UUID  `firestore:"medicineUuid" json:"medicineUuid,omitempty"`
+	FrequencyUUID uuid.UUID  `firestore:"frequencyUuid" json:"frequencyUuid,omitempty"`
+	DueAt         time.Time  `firestore:"dueAt" json:"dueAt"`
+	CompletedBy   string     `firestore:"completedBy" json:"completedBy,omitempty"`
+	CompletedAt   *time.Time `firestore:"completedAt" json:"completedAt,omitempty"`
+	// OwnerRemindedAt and CaretakersNotifiedAt record the escalations of a missed dose which were already sent
+	OwnerRemindedAt      *time.Time `firestore:"ownerRemindedAt" json:"ownerRemindedAt,omitempty"`
+	CaretakersNotifiedAt *time.Time `firestore:"caretakersNotifiedAt" json:"caretakersNotifiedAt,omitempty"`
+	UpdatedAt            time.Time  `firestore:"updatedAt" json:"updatedAt"`
+	// Version is incremented on every change, FieldChanges tells in which version each field was changed last
+	Version      int                    `firestore:"version" json:"version"`
+	FieldChanges map[string]FieldChange `firestore:"fieldChanges" json:"-"`
+}
+
+// dosesNamespace is used to derive the UUIDs of medicine todos, so every dose has exactly one todo
+var dosesNamespace = uuid.MustParse("8f8e6b7e-3f0c-4f43-9a57-2b7e5c1d0a61")
+
+// DoseToDoUUID derives the UUID of the todo for a medicine dose due at the given time
+func DoseToDoUUID(medicineUuid uuid.UUID, frequencyUuid uuid.UUID, dueAt time.Time) uuid.UUID {
+	return uuid.NewSHA1(dosesNamespace, []byte(medicineUuid.String()+frequencyUuid.String()+dueAt.UTC().Format(time.RFC3339)))
+}
+
+// DoseToDoRetention is the time a todo for a dose is kept after it was due
+const DoseToDoRetention = 7 * 24 * time.Hour
+
+// NewDoseToDo builds the open todo for the dose of the medicine due at the given time
+func NewDoseToDo(pet *Pet, medicine *Medicine, frequencyUuid uuid.UUID, dueAt time.Time) *ToDo {
+	return &ToDo{
+		UUID:          DoseToDoUUID(medicine.UUID, frequencyUuid, dueAt),
+		UserUID:       pet.UserUID,
+		PetUUID:       pet.UUID,
+		Text:          fmt.Sprintf("%d %s %s", medicine.Dosage, medicine.Unit, medicine.Name),
+		Status:        TODO_STATUS_OPEN,
+		DeleteAfter:   dueAt.Add(DoseToDoRetention),
+		MedicineUUID:  medicine.UUID,
+		FrequencyUUID: frequencyUuid,
+		DueAt:         dueAt,
+	}
 }
 
 type SetToDoStatusRequest struct {
@@ -28,5 +68,10 @@ type SetToDoStatusRequest struct {
 }
 
 type TodoRepository interface {
-	GetToDosForPet(ctx context.Context, petUuid string) ([]*ToDo, error)
+	AddToDo(ctx context.Context, todo *ToDo) (bool, error)
+	GetToDo(ctx context.Context, userUid string, petUuid string, todoUuid string) (*ToDo, error)
+	GetToDosForPet(ctx context.Context, userUid string, petUuid string) ([]*ToDo, error)
+	GetToDosForPetChangedSince(ctx context.Context, userUid string, petUuid string, since time.Time) ([]*ToDo, error)
+	GetOpenToDosDueBetween(ctx context.Context, dueAfter time.Time, dueBefore time.Time) ([]*ToDo, error)
+	UpdateToDo(ctx context.Context, userUid string, petUuid string, todoUuid string, updateFn func(ctx context.Context, todo *ToDo) (*ToDo, error)) (*ToDo, error)
 }
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const trashCollection = "trash"
+
+// petDocumentsDeleteBatchSize is the number of documents of a pet deleted per batch, firestore accepts at most 500
+// writes in a batch or transaction
+const petDocumentsDeleteBatchSize = 400
+
+// writeTrashItem keeps the deleted document in the transaction which deletes it
+func writeTrashItem(firestoreClient *firestore.Client, tx *firestore.Transaction, trashItem *TrashItem) error {
+	err := tx.Set(firestoreClient.Collection(trashCollection).Doc(trashItem.UUID.String()), trashItem)
+	if err != nil {
+		return errors.Wrapf(err, "failed to move %s '%s' to the trash", trashItem.Kind, trashItem.UUID)
+	}
+
+	return nil
+}
+
+type TrashFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewTrashFirestoreRepository(firestoreClient *firestore.Client) TrashRepository {
+	return TrashFirestoreRepository{firestoreClient}
+}
+
+func (r TrashFirestoreRepository) trashCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection(trashCollection)
+}
+
+// GetTrashedPets loads the deleted pets of the owner
+func (r TrashFirestoreRepository) GetTrashedPets(ctx context.Context, ownerUid string) ([]*TrashItem, error) {
+	trashedPetDocuments, err := r.trashCollection().
+		Where("kind", "==", TRASH_KIND_PET).
+		Where("pet.userUid", "==", ownerUid).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deleted pets of user")
+	}
+
+	return r.unmarshalTrashItems(trashedPetDocuments)
+}
+
+// GetTrashItemsForPet loads the deleted medicines and foods of the pet
+func (r TrashFirestoreRepository) GetTrashItemsForPet(ctx context.Context, petUuid string) ([]*TrashItem, error) {
+	petTrashDocuments, err := r.trashCollection().
+		Where("petUuid", "==", petUuid).
+		Where("kind", "in", []TrashKind{TRASH_KIND_MEDICINE, TRASH_KIND_FOOD}).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get deleted documents of pet %s", petUuid)
+	}
+
+	return r.unmarshalTrashItems(petTrashDocuments)
+}
+
+func (r TrashFirestoreRepository) GetTrashItem(ctx context.Context, trashItemUuid string) (*TrashItem, error) {
+	firestoreTrashItem, err := r.trashCollection().Doc(trashItemUuid).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, NewNotFoundError("trash_item_not_found", errors.Wrapf(err, "no deleted document with UUID '%s' in the trash", trashItemUuid))
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get deleted document with UUID '%s'", trashItemUuid)
+	}
+
+	return r.unmarshalTrashItem(firestoreTrashItem)
+}
+
+// RestoreTrashItem moves the deleted document back out of the trash. A restored medicine or food counts as changed
+// at the time of the restore, so offline clients get it again with their next sync.
+func (r TrashFirestoreRepository) RestoreTrashItem(ctx context.Context, userUid string, trashItemUuid string) error {
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		trashItemRef := r.trashCollection().Doc(trashItemUuid)
+
+		firestoreTrashItem, err := tx.Get(trashItemRef)
+		if status.Code(err) == codes.NotFound {
+			return NewNotFoundError("trash_item_not_found", errors.Wrapf(err, "no deleted document with UUID '%s' in the trash", trashItemUuid))
+		}
+		if err != nil {
+			return err
+		}
+
+		trashItem, err := r.unmarshalTrashItem(firestoreTrashItem)
+		if err != nil {
+			return err
+		}
+
+		restoredAt := time.Now()
+		restoredActivity := newActivity(ActivityKind(trashItem.Kind), ACTIVITY_ACTION_RESTORED, trashItem.UUID, trashItem.PetUUID, userUid)
+		switch trashItem.Kind {
+		case TRASH_KIND_PET:
+			trashItem.Pet.UpdatedAt = restoredAt
+			err = tx.Create(r.firestoreClient.Collection("pets").Doc(trashItemUuid), trashItem.Pet)
+			if err == nil {
+				err = writeActivity(r.firestoreClient, tx, restoredActivity, nil, trashItem.Pet)
+			}
+		case TRASH_KIND_MEDICINE:
+			trashItem.Medicine.UpdatedAt = restoredAt
+			err = tx.Create(r.firestoreClient.Collection("medicines").Doc(trashItemUuid), trashItem.Medicine)
+			if err == nil {
+				err = writeActivity(r.firestoreClient, tx, restoredActivity, nil, trashItem.Medicine)
+			}
+			if err == nil {
+				err = writeMedicineRevision(r.firestoreClient, tx, newMedicineRevision(ACTIVITY_ACTION_RESTORED, trashItem.UUID, trashItem.PetUUID, userUid, trashItem.Medicine))
+			}
+		case TRASH_KIND_FOOD:
+			trashItem.Food.UpdatedAt = restoredAt
+			err = tx.Create(r.firestoreClient.Collection("foods").Doc(trashItemUuid), trashItem.Food)
+			if err == nil {
+				err = writeActivity(r.firestoreClient, tx, restoredActivity, nil, trashItem.Food)
+			}
+		default:
+			err = fmt.Errorf("unknown kind '%s' of deleted document", trashItem.Kind)
+		}
+		if err != nil {
+			return err
+		}
+
+		// the tombstone of the deletion would remove the restored document from offline clients again
+		err = tx.Delete(r.firestoreClient.Collection(tombstonesCollection).Doc(trashItemUuid))
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(trashItemRef)
+		if err != nil {
+			return err
+		}
+
+		return writeTransactionRecords(ctx, r.firestoreClient, tx)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore deleted document with UUID '%s'", trashItemUuid)
+	}
+
+	return nil
+}
+
+// GetTrashItemsToPurge loads the deleted documents whose retention ended
+func (r TrashFirestoreRepository) GetTrashItemsToPurge(ctx context.Context, now time.Time) ([]*TrashItem, error) {
+	expiredTrashDocuments, err := r.trashCollection().Where("purgeAfter", "<=", now).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deleted documents to purge")
+	}
+
+	return r.unmarshalTrashItems(expiredTrashDocuments)
+}
+
+// PurgeTrashItem deletes the deleted document for good, together with all documents of a pet or the revisions of a
+// medicine
+func (r TrashFirestoreRepository) PurgeTrashItem(ctx context.Context, trashItem *TrashItem) error {
+	// the documents of the pet or medicine are deleted first, so a failed purge leaves it in the trash to be purged again
+	switch trashItem.Kind {
+	case TRASH_KIND_PET:
+		err := r.deletePetDocuments(ctx, trashItem.PetUUID.String())
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete documents of pet with UUID '%s'", trashItem.PetUUID)
+		}
+	case TRASH_KIND_MEDICINE:
+		err := r.deleteDocuments(ctx, r.firestoreClient.Collection(medicineRevisionsCollection).Where("medicineUuid", "==", trashItem.UUID.String()))
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete revisions of medicine with UUID '%s'", trashItem.UUID)
+		}
+	}
+
+	_, err := r.trashCollection().Doc(trashItem.UUID.String()).Delete(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to purge deleted document with UUID '%s'", trashItem.UUID)
+	}
+
+	return nil
+}
+
+// deletePetDocuments deletes the medicines, foods, todos, activities, medicine revisions and tombstones of the pet,
+// as well as its deleted medicines and foods. The trash item of the pet itself is deleted by the caller.
+func (r TrashFirestoreRepository) deletePetDocuments(ctx context.Context, petUuid string) error {
+	return r.deleteDocuments(
+		ctx,
+		r.firestoreClient.Collection("medicines").Where("petUuid", "==", petUuid),
+		r.firestoreClient.Collection("foods").Where("petUuid", "==", petUuid),
+		r.firestoreClient.Collection("todos").Where("petUuid", "==", petUuid),
+		r.firestoreClient.Collection(activitiesCollection).Where("petUuid", "==", petUuid),
+		r.firestoreClient.Collection(medicineRevisionsCollection).Where("petUuid", "==", petUuid),
+		r.firestoreClient.Collection(tombstonesCollection).Where("petUuid", "==", petUuid),
+		r.trashCollection().Where("petUuid", "==", petUuid).Where("kind", "in", []TrashKind{TRASH_KIND_MEDICINE, TRASH_KIND_FOOD}),
+	)
+}
+
+// deleteDocuments deletes the documents of the queries in batches, so pets with a long history of todos don't exceed
+// the writes firestore accepts at once
+func (r TrashFirestoreRepository) deleteDocuments(ctx context.Context, queries ...firestore.Query) error {
+	for _, query := range queries {
+		for {
+			documents, err := query.Limit(petDocumentsDeleteBatchSize).Documents(ctx).GetAll()
+			if err != nil {
+				return err
+			}
+			if len(documents) == 0 {
+				break
+			}
+
+			batch := r.firestoreClient.Batch()
+			for _, document := range documents {
+				batch.Delete(document.Ref)
+			}
+			_, err = batch.Commit(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r TrashFirestoreRepository) unmarshalTrashItems(docs []*firestore.DocumentSnapshot) ([]*TrashItem, error) {
+	trashItems := []*TrashItem{}
+	for _, doc := range docs {
+		trashItem, err := r.unmarshalTrashItem(doc)
+		if err != nil {
+			return nil, err
+		}
+		trashItems = append(trashItems, trashItem)
+	}
+
+	return trashItems, nil
+}
+
+func (r TrashFirestoreRepository) unmarshalTrashItem(doc *firestore.DocumentSnapshot) (*TrashItem, error) {
+	TrashItemModel := TrashItem{}
+	err := doc.DataTo(&TrashItemModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to trash item")
+	}
+
+	return &TrashItemModel, nil
+}
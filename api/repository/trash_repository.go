@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TrashKind string
+
+const (
+	TRASH_KIND_PET      TrashKind = "Pet"
+	TRASH_KIND_MEDICINE TrashKind = "Medicine"
+	TRASH_KIND_FOOD     TrashKind = "Food"
+)
+
+// TrashRetention is how long a deleted pet, medicine or food can be restored before it is purged
+const TrashRetention = 30 * 24 * time.Hour
+
+// TrashItem keeps a deleted pet, medicine or food until it is restored or purged. Only the document of the
+// deleted kind is set. The medicines, foods and todos of a deleted pet are kept in place and purged with the pet.
+type TrashItem struct {
+	UUID       uuid.UUID `firestore:"uuid" json:"uuid"`
+	Kind       TrashKind `firestore:"kind" json:"kind"`
+	PetUUID    uuid.UUID `firestore:"petUuid" json:"petUuid"`
+	DeletedAt  time.Time `firestore:"deletedAt" json:"deletedAt"`
+	DeletedBy  string    `firestore:"deletedBy" json:"deletedBy"`
+	PurgeAfter time.Time `firestore:"purgeAfter" json:"purgeAfter"`
+
+	Pet      *Pet      `firestore:"pet,omitempty" json:"pet,omitempty"`
+	Medicine *Medicine `firestore:"medicine,omitempty" json:"medicine,omitempty"`
+	Food     *Food     `firestore:"food,omitempty" json:"food,omitempty"`
+}
+
+func newTrashItem(kind TrashKind, documentUuid uuid.UUID, petUuid uuid.UUID, deletedBy string, deletedAt time.Time) *TrashItem {
+	return &TrashItem{
+		UUID:       documentUuid,
+		Kind:       kind,
+		PetUUID:    petUuid,
+		DeletedAt:  deletedAt,
+		DeletedBy:  deletedBy,
+		PurgeAfter: deletedAt.Add(TrashRetention),
+	}
+}
+
+type TrashRepository interface {
+	GetTrashedPets(ctx context.Context, ownerUid string) ([]*TrashItem, error)
+	GetTrashItemsForPet(ctx context.Context, petUuid string) ([]*TrashItem, error)
+	GetTrashItem(ctx context.Context, trashItemUuid string) (*TrashItem, error)
+	RestoreTrashItem(ctx context.Context, userUid string, trashItemUuid string) error
+	GetTrashItemsToPurge(ctx context.Context, now time.Time) ([]*TrashItem, error)
+	PurgeTrashItem(ctx context.Context, trashItem *TrashItem) error
+}
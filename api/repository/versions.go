@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+)
+
+// FieldChange records the document version and the time a field was changed last, it is used to detect
+// and resolve conflicts of offline changes field by field
+type FieldChange struct {
+	Version   int       `firestore:"version" json:"version"`
+	ChangedAt time.Time `firestore:"changedAt" json:"changedAt"`
+}
+
+// untrackedFields are maintained by the repositories themselves and are not tracked as field changes
+var untrackedFields = map[string]bool{
+	"Version":            true,
+	"UpdatedAt":          true,
+	"FieldChanges":       true,
+	"SharedWithUserUids": true,
+	"DoseTimes":          true,
+}
+
+// stampCreated sets the first version of a new document, document has to be a pointer to a versioned struct
+func stampCreated(document interface{}, now time.Time) {
+	value := reflect.ValueOf(document).Elem()
+	value.FieldByName("Version").SetInt(1)
+	value.FieldByName("UpdatedAt").Set(reflect.ValueOf(now))
+	value.FieldByName("FieldChanges").Set(reflect.ValueOf(map[string]FieldChange{}))
+}
+
+// stampUpdated increments the version of the updated document and records the fields which changed compared
+// to the document before the update. Both have to be pointers to the same versioned struct.
+func stampUpdated(before interface{}, after interface{}, now time.Time) {
+	beforeValue := reflect.ValueOf(before).Elem()
+	afterValue := reflect.ValueOf(after).Elem()
+
+	version := int(beforeValue.FieldByName("Version").Int()) + 1
+	fieldChanges := map[string]FieldChange{}
+	for name, change := range beforeValue.FieldByName("FieldChanges").Interface().(map[string]FieldChange) {
+		fieldChanges[name] = change
+	}
+
+	for index := 0; index < afterValue.NumField(); index++ {
+		field := afterValue.Type().Field(index)
+		if !field.IsExported() || untrackedFields[field.Name] {
+			continue
+		}
+
+		if !reflect.DeepEqual(beforeValue.Field(index).Interface(), afterValue.Field(index).Interface()) {
+			fieldChanges[fieldName(field)] = FieldChange{Version: version, ChangedAt: now}
+		}
+	}
+
+	afterValue.FieldByName("Version").SetInt(int64(version))
+	afterValue.FieldByName("UpdatedAt").Set(reflect.ValueOf(now))
+	afterValue.FieldByName("FieldChanges").Set(reflect.ValueOf(fieldChanges))
+}
+
+// fieldName is the name of the field in the stored documents, it is the same as in the JSON of the API
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("firestore"), ",")
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// ETag is the entity tag of a document version, it is returned in the ETag header of the API
+func ETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+type PreconditionFailedError struct {
+	DocumentUuid string
+	ETag         string
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("document '%s' was changed, its current entity tag is %s", e.DocumentUuid, e.ETag)
+}
+
+type ifMatchContextKey struct{}
+
+// WithIfMatch adds the entity tags of an If-Match header to the context, the next transactional update or delete of
+// a repository using the context fails with a PreconditionFailedError if the document matches none of them
+func WithIfMatch(ctx context.Context, etags []string) context.Context {
+	return context.WithValue(ctx, ifMatchContextKey{}, etags)
+}
+
+// checkIfMatch compares the version of a document read in a transaction with the entity tags of the context. Weak
+// entity tags never match, "*" matches every version.
+func checkIfMatch(ctx context.Context, documentUuid string, version int) error {
+	etags, ok := ctx.Value(ifMatchContextKey{}).([]string)
+	if !ok {
+		return nil
+	}
+
+	etag := ETag(version)
+	for _, ifMatch := range etags {
+		if ifMatch == "*" || ifMatch == etag {
+			return nil
+		}
+	}
+
+	return &PreconditionFailedError{DocumentUuid: documentUuid, ETag: etag}
+}
+
+// checkDocumentIfMatch reads the version of the document in the transaction and compares it with the entity tags
+// of the context, it is used by deletes which don't read the document otherwise
+func checkDocumentIfMatch(ctx context.Context, tx *firestore.Transaction, documentRef *firestore.DocumentRef) error {
+	if _, ok := ctx.Value(ifMatchContextKey{}).([]string); !ok {
+		return nil
+	}
+
+	document, err := tx.Get(documentRef)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get document '%s' to check its version", documentRef.ID)
+	}
+
+	// documents written before they were versioned have no version
+	var version int64
+	if value, err := document.DataAt("version"); err == nil {
+		version, _ = value.(int64)
+	}
+
+	return checkIfMatch(ctx, documentRef.ID, int(version))
+}
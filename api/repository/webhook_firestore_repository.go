@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type NoAccessToWebhookError struct {
+	UserUid     string
+	WebhookUuid string
+}
+
+func (e *NoAccessToWebhookError) Error() string {
+	return fmt.Sprintf("user '%s' has no access to webhook '%s'", e.UserUid, e.WebhookUuid)
+}
+
+type WebhookFirestoreRepository struct {
+	firestoreClient *firestore.Client
+}
+
+func NewWebhookFirestoreRepository(firestoreClient *firestore.Client) WebhookRepository {
+	return WebhookFirestoreRepository{firestoreClient}
+}
+
+func (r WebhookFirestoreRepository) webhooksCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("webhooks")
+}
+
+func (r WebhookFirestoreRepository) webhookDeliveriesCollection() *firestore.CollectionRef {
+	return r.firestoreClient.Collection("webhookDeliveries")
+}
+
+func (r WebhookFirestoreRepository) AddWebhook(ctx context.Context, userUid string, webhook *Webhook) ([]*Webhook, error) {
+	webhook.UUID = uuid.New()
+	webhook.UserUID = userUid
+	webhook.CreatedAt = time.Now()
+
+	_, err := r.webhooksCollection().Doc(webhook.UUID.String()).Create(ctx, webhook)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add webhook")
+	}
+
+	userWebhooks, err := r.GetWebhooks(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's webhooks after new webhook was added")
+	}
+
+	return userWebhooks, nil
+}
+
+func (r WebhookFirestoreRepository) GetWebhook(ctx context.Context, userUid string, webhookUuid string) (*Webhook, error) {
+	firestoreWebhook, err := r.webhooksCollection().Doc(webhookUuid).Get(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get webhook with UUID '%s'", webhookUuid)
+	}
+
+	webhook, err := r.unmarshalWebhook(firestoreWebhook)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.UserUID != userUid {
+		return nil, &NoAccessToWebhookError{UserUid: userUid, WebhookUuid: webhookUuid}
+	}
+
+	return webhook, nil
+}
+
+func (r WebhookFirestoreRepository) GetWebhooks(ctx context.Context, userUid string) ([]*Webhook, error) {
+	webhookDocuments, err := r.webhooksCollection().Where("userUid", "==", userUid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get all webhooks for user")
+	}
+
+	return r.unmarshalWebhooks(webhookDocuments)
+}
+
+func (r WebhookFirestoreRepository) GetSubscribedWebhooks(ctx context.Context, userUids []string, eventType string) ([]*Webhook, error) {
+	webhooks := []*Webhook{}
+	for start := 0; start < len(userUids); start += maxFirestoreInQueryValues {
+		end := start + maxFirestoreInQueryValues
+		if end > len(userUids) {
+			end = len(userUids)
+		}
+
+		webhookDocuments, err := r.webhooksCollection().
+			Where("userUid", "in", userUids[start:end]).
+			Where("events", "array-contains", eventType).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get webhooks subscribed to '%s'", eventType)
+		}
+
+		subscribedWebhooks, err := r.unmarshalWebhooks(webhookDocuments)
+		if err != nil {
+			return nil, err
+		}
+		for _, webhook := range subscribedWebhooks {
+			if webhook.Active {
+				webhooks = append(webhooks, webhook)
+			}
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (r WebhookFirestoreRepository) UpdateWebhook(ctx context.Context, userUid string, webhookUuid string, updateFn func(ctx context.Context, webhook *Webhook) (*Webhook, error)) ([]*Webhook, error) {
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.webhooksCollection().Doc(webhookUuid)
+
+		firestoreWebhook, err := tx.Get(documentRef)
+		if err != nil {
+			return errors.Wrap(err, "unable to get webhook document for update")
+		}
+
+		webhook, err := r.unmarshalWebhook(firestoreWebhook)
+		if err != nil {
+			return err
+		}
+		if webhook.UserUID != userUid {
+			return &NoAccessToWebhookError{UserUid: userUid, WebhookUuid: webhookUuid}
+		}
+
+		updatedWebhook, err := updateFn(ctx, webhook)
+		if err != nil {
+			return err
+		}
+
+		return tx.Set(documentRef, updatedWebhook)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update webhook")
+	}
+
+	userWebhooks, err := r.GetWebhooks(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's webhooks after webhook was updated")
+	}
+
+	return userWebhooks, nil
+}
+
+func (r WebhookFirestoreRepository) DeleteWebhook(ctx context.Context, userUid string, webhookUuid string) ([]*Webhook, error) {
+	_, err := r.GetWebhook(ctx, userUid, webhookUuid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load webhook with UUID '%s' before deletion", webhookUuid)
+	}
+
+	_, err = r.webhooksCollection().Doc(webhookUuid).Delete(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete webhook with UUID '%s'", webhookUuid)
+	}
+
+	userWebhooks, err := r.GetWebhooks(ctx, userUid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get updated list of the user's webhooks after webhook was deleted")
+	}
+
+	return userWebhooks, nil
+}
+
+// AddWebhookDelivery stores the delivery, a delivery with a preset UUID which already exists is not added again
+func (r WebhookFirestoreRepository) AddWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	if delivery.UUID == uuid.Nil {
+		delivery.UUID = uuid.New()
+	}
+	delivery.CreatedAt = time.Now()
+	if delivery.Attempts == nil {
+		delivery.Attempts = []WebhookDeliveryAttempt{}
+	}
+
+	_, err := r.webhookDeliveriesCollection().Doc(delivery.UUID.String()).Create(ctx, delivery)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to add delivery of event '%s' to webhook '%s'", delivery.EventID, delivery.WebhookUUID)
+	}
+
+	return nil
+}
+
+func (r WebhookFirestoreRepository) GetWebhookDelivery(ctx context.Context, userUid string, deliveryUuid string) (*WebhookDelivery, error) {
+	firestoreDelivery, err := r.webhookDeliveriesCollection().Doc(deliveryUuid).Get(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get webhook delivery with UUID '%s'", deliveryUuid)
+	}
+
+	delivery, err := r.unmarshalWebhookDelivery(firestoreDelivery)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.UserUID != userUid {
+		return nil, &NoAccessToWebhookError{UserUid: userUid, WebhookUuid: delivery.WebhookUUID.String()}
+	}
+
+	return delivery, nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook, newest first
+func (r WebhookFirestoreRepository) GetWebhookDeliveries(ctx context.Context, userUid string, webhookUuid string, limit int) ([]*WebhookDelivery, error) {
+	_, err := r.GetWebhook(ctx, userUid, webhookUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryDocuments, err := r.webhookDeliveriesCollection().
+		Where("webhookUuid", "==", webhookUuid).
+		OrderBy("createdAt", firestore.Desc).
+		Limit(limit).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get deliveries of webhook '%s'", webhookUuid)
+	}
+
+	return r.unmarshalWebhookDeliveries(deliveryDocuments)
+}
+
+func (r WebhookFirestoreRepository) GetPendingWebhookDeliveriesDueBefore(ctx context.Context, dueBefore time.Time) ([]*WebhookDelivery, error) {
+	deliveryDocuments, err := r.webhookDeliveriesCollection().
+		Where("status", "==", WEBHOOK_DELIVERY_STATUS_PENDING).
+		Where("nextAttemptAt", "<=", dueBefore).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pending webhook deliveries")
+	}
+
+	return r.unmarshalWebhookDeliveries(deliveryDocuments)
+}
+
+func (r WebhookFirestoreRepository) UpdateWebhookDelivery(ctx context.Context, deliveryUuid string, updateFn func(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error)) error {
+	err := r.firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		documentRef := r.webhookDeliveriesCollection().Doc(deliveryUuid)
+
+		firestoreDelivery, err := tx.Get(documentRef)
+		if err != nil {
+			return errors.Wrap(err, "unable to get webhook delivery document for update")
+		}
+
+		delivery, err := r.unmarshalWebhookDelivery(firestoreDelivery)
+		if err != nil {
+			return err
+		}
+
+		updatedDelivery, err := updateFn(ctx, delivery)
+		if err != nil {
+			return err
+		}
+
+		return tx.Set(documentRef, updatedDelivery)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update webhook delivery '%s'", deliveryUuid)
+	}
+
+	return nil
+}
+
+func (r WebhookFirestoreRepository) unmarshalWebhooks(docs []*firestore.DocumentSnapshot) ([]*Webhook, error) {
+	webhooks := []*Webhook{}
+	for _, doc := range docs {
+		webhook, err := r.unmarshalWebhook(doc)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (r WebhookFirestoreRepository) unmarshalWebhook(doc *firestore.DocumentSnapshot) (*Webhook, error) {
+	WebhookModel := Webhook{}
+	err := doc.DataTo(&WebhookModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to webhook")
+	}
+
+	return &WebhookModel, nil
+}
+
+func (r WebhookFirestoreRepository) unmarshalWebhookDeliveries(docs []*firestore.DocumentSnapshot) ([]*WebhookDelivery, error) {
+	deliveries := []*WebhookDelivery{}
+	for _, doc := range docs {
+		delivery, err := r.unmarshalWebhookDelivery(doc)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (r WebhookFirestoreRepository) unmarshalWebhookDelivery(doc *firestore.DocumentSnapshot) (*WebhookDelivery, error) {
+	WebhookDeliveryModel := WebhookDelivery{}
+	err := doc.DataTo(&WebhookDeliveryModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal document to webhook delivery")
+	}
+
+	return &WebhookDeliveryModel, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Webhook struct {
+	UUID    uuid.UUID `firestore:"uuid" json:"uuid"`
+	UserUID string    `firestore:"userUid" json:"userUid"`
+	URL     string    `firestore:"url" json:"url"`
+	// Secret is the key of the HMAC-SHA256 signature of every delivery
+	Secret string `firestore:"secret" json:"secret"`
+	// Events are the types of events the webhook is subscribed to
+	Events    []string  `firestore:"events" json:"events"`
+	Active    bool      `firestore:"active" json:"active"`
+	CreatedAt time.Time `firestore:"createdAt" json:"createdAt"`
+}
+
+// WebhookUpdate holds the changes of a webhook, fields which are not given keep their value
+type WebhookUpdate struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+func (w *Webhook) IsSubscribedTo(eventType string) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WEBHOOK_DELIVERY_STATUS_PENDING   WebhookDeliveryStatus = "Pending"
+	WEBHOOK_DELIVERY_STATUS_SUCCEEDED WebhookDeliveryStatus = "Succeeded"
+	WEBHOOK_DELIVERY_STATUS_FAILED    WebhookDeliveryStatus = "Failed"
+)
+
+// WebhookDeliveryAttempt is one request sent to the webhook endpoint
+type WebhookDeliveryAttempt struct {
+	AttemptedAt time.Time `firestore:"attemptedAt" json:"attemptedAt"`
+	StatusCode  int       `firestore:"statusCode" json:"statusCode"`
+	Error       string    `firestore:"error" json:"error,omitempty"`
+	DurationMs  int64     `firestore:"durationMs" json:"durationMs"`
+}
+
+// WebhookDelivery is an event sent to a webhook, it is retried with backoff until it succeeds or runs out of attempts
+type WebhookDelivery struct {
+	UUID        uuid.UUID `firestore:"uuid" json:"uuid"`
+	WebhookUUID uuid.UUID `firestore:"webhookUuid" json:"webhookUuid"`
+	UserUID     string    `firestore:"userUid" json:"userUid"`
+	EventID     string    `firestore:"eventId" json:"eventId"`
+	EventType   string    `firestore:"eventType" json:"eventType"`
+	// Payload is the JSON body sent to the webhook
+	Payload       string                   `firestore:"payload" json:"payload"`
+	Status        WebhookDeliveryStatus    `firestore:"status" json:"status"`
+	Attempts      []WebhookDeliveryAttempt `firestore:"attempts" json:"attempts"`
+	NextAttemptAt time.Time                `firestore:"nextAttemptAt" json:"nextAttemptAt"`
+	CreatedAt     time.Time                `firestore:"createdAt" json:"createdAt"`
+	// RedeliveryOf is the UUID of the delivery which was sent again by this one
+	RedeliveryOf *uuid.UUID `firestore:"redeliveryOf" json:"redeliveryOf,omitempty"`
+}
+
+type WebhookRepository interface {
+	AddWebhook(ctx context.Context, userUid string, webhook *Webhook) ([]*Webhook, error)
+	GetWebhook(ctx context.Context, userUid string, webhookUuid string) (*Webhook, error)
+	GetWebhooks(ctx context.Context, userUid string) ([]*Webhook, error)
+	// GetSubscribedWebhooks returns the active webhooks of the users which are subscribed to the event type
+	GetSubscribedWebhooks(ctx context.Context, userUids []string, eventType string) ([]*Webhook, error)
+	UpdateWebhook(ctx context.Context, userUid string, webhookUuid string, updateFn func(ctx context.Context, webhook *Webhook) (*Webhook, error)) ([]*Webhook, error)
+	DeleteWebhook(ctx context.Context, userUid string, webhookUuid string) ([]*Webhook, error)
+
+	AddWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, userUid string, deliveryUuid string) (*WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, userUid string, webhookUuid string, limit int) ([]*WebhookDelivery, error)
+	GetPendingWebhookDeliveriesDueBefore(ctx context.Context, dueBefore time.Time) ([]*WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, deliveryUuid string, updateFn func(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error)) error
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cafo13/fur-meds/api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseETags splits the entity tags of an If-Match or If-None-Match header
+func parseETags(header string) []string {
+	etags := []string{}
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag != "" {
+			etags = append(etags, etag)
+		}
+	}
+
+	return etags
+}
+
+// withIfMatch adds the entity tags of the If-Match header to the context, so the repository rejects the write
+// if the document was changed in the meantime
+func withIfMatch(ctx *gin.Context) context.Context {
+	header := ctx.GetHeader("If-Match")
+	if header == "" {
+		return ctx
+	}
+
+	return repository.WithIfMatch(ctx, parseETags(header))
+}
+
+// notModified sets the ETag header of the returned document version and answers with 304 if it matches the
+// If-None-Match header, which uses the weak comparison
+func notModified(ctx *gin.Context, version int) bool {
+	etag := repository.ETag(version)
+	ctx.Header("ETag", etag)
+
+	for _, ifNoneMatch := range parseETags(ctx.GetHeader("If-None-Match")) {
+		if ifNoneMatch == "*" || strings.TrimPrefix(ifNoneMatch, "W/") == etag {
+			ctx.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
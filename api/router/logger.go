@@ -0,0 +1,30 @@
+package router
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLogger logs every request like the default logger of gin, but without the access token WebSocket
+// requests carry in their query
+func requestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			auth.RedactAccessToken(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
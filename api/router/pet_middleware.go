@@ -0,0 +1,71 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/cafo13/fur-meds/api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PetMiddleware loads the pet of the petUuid parameter once per request and checks that the user has the required
+// role for it. The pet is stored in the context for the routes below, a missing pet is reported as not found.
+// Nested in a group of the middleware it only checks the role for the pet which was already loaded.
+func (r Router) PetMiddleware(requiredRole repository.PetRole) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pet, err := r.loadPet(ctx, requiredRole)
+		if err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set("pet", pet)
+		ctx.Next()
+	}
+}
+
+func (r Router) loadPet(ctx *gin.Context, requiredRole repository.PetRole) (*repository.Pet, error) {
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pet, ok := ctx.Value("pet").(*repository.Pet)
+	if ok {
+		return pet, checkPetRole(pet, user.UID, requiredRole)
+	}
+
+	petUuid := ctx.Params.ByName("petUuid")
+	_, err = uuid.Parse(petUuid)
+	if err != nil {
+		return nil, repository.NewNotFoundError("pet_not_found", errors.Wrapf(err, "pet UUID '%s' of request URL is invalid", petUuid))
+	}
+
+	pet, err = r.PetHandler.Get(ctx, user.UID, petUuid)
+	if status.Code(errors.Cause(err)) == codes.NotFound {
+		return nil, repository.NewNotFoundError("pet_not_found", err)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error on loading pet with UUID '%s'", petUuid)
+	}
+
+	return pet, checkPetRole(pet, user.UID, requiredRole)
+}
+
+func checkPetRole(pet *repository.Pet, userUid string, requiredRole repository.PetRole) error {
+	if !pet.Role(userUid).Allows(requiredRole) {
+		return repository.NewForbiddenError("pet_role_required", fmt.Errorf("user needs the role '%s' for pet '%s'", requiredRole, pet.UUID))
+	}
+
+	return nil
+}
+
+// petFromCtx returns the pet which was loaded by the PetMiddleware
+func petFromCtx(ctx *gin.Context) *repository.Pet {
+	return ctx.MustGet("pet").(*repository.Pet)
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/cafo13/fur-meds/api/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const problemContentType = "application/problem+json"
+
+// internalErrorDetail replaces the message of server errors, which may tell internals of the server. The error itself is
+// only logged.
+const internalErrorDetail = "The request could not be processed because of an error on the server."
+
+// Problem is the body of an error response as defined by RFC 7807, Code is a machine-readable identifier of the error
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance"`
+	Code     string `json:"code"`
+	// Violations lists every invalid field of a request which failed the validation
+	Violations []validation.Violation `json:"violations,omitempty"`
+}
+
+var errorKindStatus = map[repository.ErrorKind]int{
+	repository.ERROR_KIND_NOT_FOUND:           http.StatusNotFound,
+	repository.ERROR_KIND_FORBIDDEN:           http.StatusForbidden,
+	repository.ERROR_KIND_CONFLICT:            http.StatusConflict,
+	repository.ERROR_KIND_VALIDATION:          http.StatusUnprocessableEntity,
+	repository.ERROR_KIND_PRECONDITION_FAILED: http.StatusPreconditionFailed,
+	repository.ERROR_KIND_TOO_MANY_REQUESTS:   http.StatusTooManyRequests,
+}
+
+// ErrorMiddleware renders the last error a route added to the context as problem details. Routes only add the
+// error and return, the status and code are derived from the domain error in the chain of wrapped errors.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+
+		err := ctx.Errors.Last().Err
+		log.Error(err)
+
+		var preconditionFailedError *repository.PreconditionFailedError
+		if errors.As(err, &preconditionFailedError) {
+			ctx.Header("ETag", preconditionFailedError.ETag)
+		}
+
+		statusCode, code := problemStatus(err)
+		problem := Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(statusCode),
+			Status:   statusCode,
+			Detail:   err.Error(),
+			Instance: ctx.Request.URL.Path,
+			Code:     code,
+		}
+		if statusCode >= http.StatusInternalServerError {
+			problem.Detail = internalErrorDetail
+		}
+
+		var validationError *validation.Error
+		if errors.As(err, &validationError) {
+			problem.Violations = validationError.Violations
+		}
+
+		// the JSON renderer keeps a content type which is already set
+		ctx.Header("Content-Type", problemContentType)
+		ctx.JSON(statusCode, problem)
+	}
+}
+
+// problemStatus finds the status and code of an error, errors of firestore which weren't mapped by the
+// repositories are reported by their gRPC code
+func problemStatus(err error) (int, string) {
+	var domainError repository.DomainError
+	if errors.As(err, &domainError) {
+		if statusCode, ok := errorKindStatus[domainError.Kind()]; ok {
+			return statusCode, domainError.Code()
+		}
+	}
+
+	var grpcError interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcError) {
+		switch grpcError.GRPCStatus().Code() {
+		case codes.NotFound:
+			return http.StatusNotFound, "not_found"
+		case codes.AlreadyExists:
+			return http.StatusConflict, "already_exists"
+		}
+	}
+
+	return http.StatusInternalServerError, "internal_error"
+}
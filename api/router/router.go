@@ -1,10 +1,14 @@
 package router
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/cafo13/fur-meds/api/auth"
+	"github.com/cafo13/fur-meds/api/caresheet"
 	"github.com/cafo13/fur-meds/api/cors"
 	"github.com/cafo13/fur-meds/api/handler"
 	"github.com/cafo13/fur-meds/api/repository"
@@ -12,15 +16,23 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
 )
 
-var petAccessError = errors.New("user has no access to pet")
-
 type HandlerSet struct {
-	PetHandler      handler.PetHandler
-	MedicineHandler handler.MedicineHandler
-	FoodHandler     handler.FoodHandler
-	TodoHandler     handler.TodoHandler
+	PetHandler         handler.PetHandler
+	MedicineHandler    handler.MedicineHandler
+	FoodHandler        handler.FoodHandler
+	TodoHandler        handler.TodoHandler
+	HouseholdHandler   handler.HouseholdHandler
+	CareSheetHandler   handler.CareSheetHandler
+	DeviceHandler      handler.DeviceHandler
+	PreferencesHandler handler.PreferencesHandler
+	WebhookHandler     handler.WebhookHandler
+	StreamHandler      handler.StreamHandler
+	SyncHandler        handler.SyncHandler
+	TrashHandler       handler.TrashHandler
+	ActivityHandler    handler.ActivityHandler
 }
 type Router struct {
 	Router         *gin.Engine
@@ -34,8 +46,12 @@ func NewRouter(
 	corsMiddleware cors.CORSMiddleware,
 	handlerSet HandlerSet,
 ) Router {
+	// the engine is set up like gin.Default(), but logs the requests without their access tokens
+	engine := gin.New()
+	engine.Use(requestLogger(), gin.Recovery())
+
 	return Router{
-		Router:         gin.Default(),
+		Router:         engine,
 		AuthMiddleware: authMiddleware,
 		CORSMiddleware: corsMiddleware,
 		HandlerSet:     handlerSet,
@@ -47,15 +63,22 @@ func (r Router) GetPets(ctx *gin.Context) {
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	pets, err := r.PetHandler.GetAllForUser(ctx, user.UID)
+	includeArchived := false
+	if includeArchivedParameter := ctx.Query("includeArchived"); includeArchivedParameter != "" {
+		includeArchived, err = strconv.ParseBool(includeArchivedParameter)
+		if err != nil {
+			ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting includeArchived from request URL")))
+			return
+		}
+	}
+
+	pets, err := r.PetHandler.GetAllForUser(ctx, user.UID, includeArchived)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusOK, pets)
@@ -66,43 +89,11 @@ func (r Router) GetPets(ctx *gin.Context) {
 func (r Router) GetPet(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "GET")
 
-	user, err := r.AuthMiddleware.UserFromCtx(ctx)
-	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
-		return
-	}
-
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
-	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
-		return
-	}
-
-	pets, err := r.PetHandler.Get(ctx, user.UID, petUuid)
-	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+	pet := petFromCtx(ctx)
+	if notModified(ctx, pet.Version) {
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, pets)
+		ctx.IndentedJSON(http.StatusOK, pet)
 		return
 	}
 }
@@ -112,24 +103,20 @@ func (r Router) AddPet(ctx *gin.Context) {
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	pet := &repository.Pet{}
-	err = ctx.BindJSON(&pet)
+	err = ctx.ShouldBindJSON(&pet)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting pet from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting pet from json body")))
 		return
 	}
 
 	pets, err := r.PetHandler.Create(ctx, user.UID, pet)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusCreated, pets)
@@ -142,47 +129,20 @@ func (r Router) AddPetMedicine(ctx *gin.Context) {
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	medicine := &repository.Medicine{}
-	err = ctx.BindJSON(&medicine)
-	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting pet medicine from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
-		return
-	}
-
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	err = ctx.ShouldBindJSON(&medicine)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting pet medicine from json body")))
 		return
 	}
 
-	pets, err := r.MedicineHandler.Create(ctx, user.UID, petUuid, medicine)
+	pets, err := r.MedicineHandler.Create(ctx, user.UID, petFromCtx(ctx), medicine)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusCreated, pets)
@@ -195,47 +155,20 @@ func (r Router) AddPetFood(ctx *gin.Context) {
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	food := &repository.Food{}
-	err = ctx.BindJSON(&food)
-	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting pet food from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
-		return
-	}
-
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	err = ctx.ShouldBindJSON(&food)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting pet food from json body")))
 		return
 	}
 
-	pets, err := r.FoodHandler.Create(ctx, user.UID, petUuid, food)
+	pets, err := r.FoodHandler.Create(ctx, user.UID, petFromCtx(ctx), food)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusCreated, pets)
@@ -247,59 +180,61 @@ func (r Router) UpdatePet(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "PUT")
 
 	pet := &repository.Pet{}
-	err := ctx.BindJSON(&pet)
+	err := ctx.ShouldBindJSON(&pet)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting pet from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting pet from json body")))
 		return
 	}
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("uuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	petUuid := petFromCtx(ctx).UUID.String()
+	pets, err := r.PetHandler.Update(withIfMatch(ctx), user.UID, petUuid, pet)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on updating pet"))
+		return
+	} else {
+		for _, updatedPet := range pets {
+			if updatedPet.UUID.String() == petUuid {
+				ctx.Header("ETag", repository.ETag(updatedPet.Version))
+			}
+		}
+		ctx.IndentedJSON(http.StatusOK, pets)
 		return
 	}
+}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+func (r Router) PatchPet(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PATCH")
+
+	patch := map[string]json.RawMessage{}
+	err := ctx.ShouldBindJSON(&patch)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting merge patch of pet from json body")))
 		return
 	}
 
-	_, err = r.PetHandler.Get(ctx, user.UID, petUuid)
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		errorMsg := fmt.Sprintf("error on loading pet with UUID '%s'", petUuid)
-		log.Error(errorMsg)
-		ctx.JSON(http.StatusNotFound, gin.H{"Message": errorMsg})
+		ctx.Error(err)
 		return
 	}
 
-	pets, err := r.PetHandler.Update(ctx, user.UID, petUuid, pet)
+	petUuid := petFromCtx(ctx).UUID.String()
+	pets, err := r.PetHandler.Patch(withIfMatch(ctx), user.UID, petUuid, patch)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on updating pet")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": wrappedError})
+		ctx.Error(errors.Wrap(err, "error on patching pet"))
 		return
 	} else {
+		for _, updatedPet := range pets {
+			if updatedPet.UUID.String() == petUuid {
+				ctx.Header("ETag", repository.ETag(updatedPet.Version))
+			}
+		}
 		ctx.IndentedJSON(http.StatusOK, pets)
 		return
 	}
@@ -309,68 +244,62 @@ func (r Router) UpdatePetMedicine(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "PUT")
 
 	medicine := &repository.Medicine{}
-	err := ctx.BindJSON(&medicine)
+	err := ctx.ShouldBindJSON(&medicine)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting medicine from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting medicine from json body")))
 		return
 	}
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	medicineUuid := ctx.Params.ByName("uuid")
+	medicines, err := r.MedicineHandler.Update(withIfMatch(ctx), user.UID, petFromCtx(ctx), medicineUuid, medicine)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on updating medicine"))
 		return
-	}
-
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	} else {
+		for _, updatedMedicine := range medicines {
+			if updatedMedicine.UUID.String() == medicineUuid {
+				ctx.Header("ETag", repository.ETag(updatedMedicine.Version))
+			}
+		}
+		ctx.IndentedJSON(http.StatusOK, medicines)
 		return
 	}
+}
 
-	medicineUuid := ctx.Params.ByName("uuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting medicine UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+func (r Router) PatchPetMedicine(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PATCH")
+
+	patch := map[string]json.RawMessage{}
+	err := ctx.ShouldBindJSON(&patch)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting merge patch of medicine from json body")))
 		return
 	}
 
-	_, err = r.MedicineHandler.Get(ctx, user.UID, medicineUuid)
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		errorMsg := fmt.Sprintf("error on loading medicine with UUID '%s'", medicineUuid)
-		log.Error(errorMsg)
-		ctx.JSON(http.StatusNotFound, gin.H{"Message": errorMsg})
+		ctx.Error(err)
 		return
 	}
 
-	pets, err := r.MedicineHandler.Update(ctx, user.UID, medicineUuid, medicine)
+	medicineUuid := ctx.Params.ByName("uuid")
+	medicines, err := r.MedicineHandler.Patch(withIfMatch(ctx), user.UID, petFromCtx(ctx), medicineUuid, patch)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on updating medicine")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": wrappedError})
+		ctx.Error(errors.Wrap(err, "error on patching medicine"))
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, pets)
+		for _, updatedMedicine := range medicines {
+			if updatedMedicine.UUID.String() == medicineUuid {
+				ctx.Header("ETag", repository.ETag(updatedMedicine.Version))
+			}
+		}
+		ctx.IndentedJSON(http.StatusOK, medicines)
 		return
 	}
 }
@@ -379,65 +308,85 @@ func (r Router) UpdatePetFood(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "PUT")
 
 	food := &repository.Food{}
-	err := ctx.BindJSON(&food)
+	err := ctx.ShouldBindJSON(&food)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting food from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting food from json body")))
 		return
 	}
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	foodUuid := ctx.Params.ByName("uuid")
+	foods, err := r.FoodHandler.Update(withIfMatch(ctx), user.UID, petFromCtx(ctx), foodUuid, food)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on updating food"))
+		return
+	} else {
+		for _, updatedFood := range foods {
+			if updatedFood.UUID.String() == foodUuid {
+				ctx.Header("ETag", repository.ETag(updatedFood.Version))
+			}
+		}
+		ctx.IndentedJSON(http.StatusOK, foods)
 		return
 	}
+}
+
+func (r Router) PatchPetFood(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PATCH")
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	patch := map[string]json.RawMessage{}
+	err := ctx.ShouldBindJSON(&patch)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting merge patch of food from json body")))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	foodUuid := ctx.Params.ByName("uuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting food UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	foods, err := r.FoodHandler.Patch(withIfMatch(ctx), user.UID, petFromCtx(ctx), foodUuid, patch)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on patching food"))
+		return
+	} else {
+		for _, updatedFood := range foods {
+			if updatedFood.UUID.String() == foodUuid {
+				ctx.Header("ETag", repository.ETag(updatedFood.Version))
+			}
+		}
+		ctx.IndentedJSON(http.StatusOK, foods)
+		return
+	}
+}
+
+func (r Router) ArchivePet(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	archive := &repository.PetArchive{}
+	err := ctx.ShouldBindJSON(&archive)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting pet archive from json body")))
 		return
 	}
 
-	_, err = r.FoodHandler.Get(ctx, user.UID, foodUuid)
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		errorMsg := fmt.Sprintf("error on loading food with UUID '%s'", foodUuid)
-		log.Error(errorMsg)
-		ctx.JSON(http.StatusNotFound, gin.H{"Message": errorMsg})
+		ctx.Error(err)
 		return
 	}
 
-	pets, err := r.FoodHandler.Update(ctx, user.UID, foodUuid, food)
+	pets, err := r.PetHandler.Archive(withIfMatch(ctx), user.UID, petFromCtx(ctx).UUID.String(), archive)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on updating food")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": wrappedError})
+		ctx.Error(errors.Wrap(err, "error on archiving pet"))
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusOK, pets)
@@ -445,88 +394,80 @@ func (r Router) UpdatePetFood(ctx *gin.Context) {
 	}
 }
 
-func (r Router) DeletePet(ctx *gin.Context) {
+func (r Router) UnarchivePet(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "DELETE")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("uuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	pets, err := r.PetHandler.Unarchive(withIfMatch(ctx), user.UID, petFromCtx(ctx).UUID.String())
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on unarchiving pet"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, pets)
 		return
 	}
+}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
-		return
+func (r Router) GetPetActivity(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	limit := 0
+	if limitParameter := ctx.Query("limit"); limitParameter != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParameter)
+		if err != nil {
+			ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting limit from request URL")))
+			return
+		}
 	}
 
-	pets, err := r.PetHandler.Delete(ctx, user.UID, petUuid)
+	activities, err := r.ActivityHandler.GetAllForPet(ctx, petFromCtx(ctx), ctx.Query("cursor"), limit)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, pets)
+		ctx.IndentedJSON(http.StatusOK, activities)
 		return
 	}
 }
 
-func (r Router) DeletePetMedicine(ctx *gin.Context) {
+func (r Router) DeletePet(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "DELETE")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	pets, err := r.PetHandler.Delete(withIfMatch(ctx), user.UID, petFromCtx(ctx))
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, pets)
 		return
 	}
+}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+func (r Router) DeletePetMedicine(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	petMedicineUUID := ctx.Params.ByName("uuid")
-	pets, err := r.MedicineHandler.Delete(ctx, user.UID, petMedicineUUID)
+	pets, err := r.MedicineHandler.Delete(withIfMatch(ctx), user.UID, petFromCtx(ctx), petMedicineUUID)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusOK, pets)
@@ -534,115 +475,122 @@ func (r Router) DeletePetMedicine(ctx *gin.Context) {
 	}
 }
 
-func (r Router) DeletePetFood(ctx *gin.Context) {
-	ctx.Header("Access-Control-Allow-Methods", "DELETE")
+func (r Router) GetPetMedicineRevisions(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	revisions, err := r.MedicineHandler.GetRevisions(ctx, user.UID, petFromCtx(ctx), ctx.Params.ByName("uuid"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, revisions)
 		return
 	}
+}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
-	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+func (r Router) DiffPetMedicineRevisions(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	fromRevisionUuid, toRevisionUuid := ctx.Query("from"), ctx.Query("to")
+	if fromRevisionUuid == "" || toRevisionUuid == "" {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.New("the revisions to compare have to be given as from and to in the request URL")))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	petFoodUUID := ctx.Params.ByName("uuid")
-	pets, err := r.FoodHandler.Delete(ctx, user.UID, petFoodUUID)
+	diff, err := r.MedicineHandler.DiffRevisions(ctx, user.UID, petFromCtx(ctx), ctx.Params.ByName("uuid"), fromRevisionUuid, toRevisionUuid)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, pets)
+		ctx.IndentedJSON(http.StatusOK, diff)
 		return
 	}
 }
 
-func (r Router) InviteToSharePet(ctx *gin.Context) {
-	ctx.Header("Access-Control-Allow-Methods", "POST")
+func (r Router) GetPetMedicineAt(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
 
-	sharePetInviteRequest := &repository.SharePetInviteRequest{}
-	err := ctx.BindJSON(&sharePetInviteRequest)
+	at, err := time.Parse(time.RFC3339, ctx.Query("at"))
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting share pet invite request from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting point in time from request URL")))
 		return
 	}
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request body")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	medicine, err := r.MedicineHandler.GetAt(ctx, user.UID, petFromCtx(ctx), ctx.Params.ByName("uuid"), at)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, medicine)
 		return
 	}
+}
+
+func (r Router) DeletePetFood(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	petFoodUUID := ctx.Params.ByName("uuid")
+	pets, err := r.FoodHandler.Delete(withIfMatch(ctx), user.UID, petFromCtx(ctx), petFoodUUID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, pets)
+		return
+	}
+}
+
+func (r Router) InviteToSharePet(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	sharePetInviteRequest := &repository.SharePetInviteRequest{}
+	err := ctx.ShouldBindJSON(&sharePetInviteRequest)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting share pet invite request from json body")))
 		return
 	}
 
-	pet, err := r.PetHandler.Get(ctx, user.UID, petUuid)
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		errorMsg := fmt.Sprintf("error on loading pet with UUID '%s'", pet.UUID)
-		log.Error(errorMsg)
-		ctx.JSON(http.StatusNotFound, gin.H{"Message": errorMsg})
+		ctx.Error(err)
 		return
 	}
 
+	petUuid := petFromCtx(ctx).UUID.String()
 	userUidToSharePetWith, err := r.AuthMiddleware.GetUserUidByMail(ctx, sharePetInviteRequest.UserMailToInvite)
 	if err != nil {
-		errorMsg := fmt.Sprintf("error on getting UID of user '%s' to invite to pet share for pet with UUID '%s'", sharePetInviteRequest.UserMailToInvite, pet.UUID)
-		log.Error(errorMsg)
-		ctx.JSON(http.StatusNotFound, gin.H{"Message": errorMsg})
+		ctx.Error(repository.NewNotFoundError("user_not_found", errors.Wrapf(err, "error on getting UID of user '%s' to invite to pet share for pet with UUID '%s'", sharePetInviteRequest.UserMailToInvite, petUuid)))
 		return
 	}
 
-	pets, err := r.PetHandler.CreatePetShareInvite(ctx, user.UID, petUuid, userUidToSharePetWith)
+	pets, err := r.PetHandler.CreatePetShareInvite(ctx, user.UID, petUuid, userUidToSharePetWith, sharePetInviteRequest.ValidFrom, sharePetInviteRequest.ValidUntil)
 
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error inviting user to accept share of pet")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": wrappedError})
+		ctx.Error(errors.Wrap(err, "error inviting user to accept share of pet"))
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusOK, pets)
@@ -654,35 +602,28 @@ func (r Router) AnswerPetShareInvite(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "POST")
 
 	petShareRequestAnswer := &repository.AnswerPetShareRequest{}
-	err := ctx.BindJSON(&petShareRequestAnswer)
+	err := ctx.ShouldBindJSON(&petShareRequestAnswer)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting pet share request answer from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting pet share request answer from json body")))
 		return
 	}
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	petUuid := ctx.Params.ByName("petUuid")
 	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request body")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting pet UUID from request body")))
 		return
 	}
 
 	pets, err := r.PetHandler.AnswerPetShareInvite(ctx, user.UID, petUuid, petShareRequestAnswer.Answer)
 
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error answering pet share invite")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": wrappedError})
+		ctx.Error(errors.Wrap(err, "error answering pet share invite"))
 		return
 	} else {
 		ctx.IndentedJSON(http.StatusOK, pets)
@@ -695,290 +636,909 @@ func (r Router) GetPetShareInvites(ctx *gin.Context) {
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request body")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	openSharedPets, err := r.PetHandler.GetOpenSharedPets(ctx, user.UID)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	ownerUids := []string{}
+	for _, pet := range openSharedPets {
+		ownerUids = append(ownerUids, pet.UserUID)
+	}
+
+	owners, err := r.AuthMiddleware.GetUsersByUids(ctx, ownerUids)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on getting owners of shared pets"))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
-		return
+	petShareInvites := []*repository.PetShareInvite{}
+	for _, pet := range openSharedPets {
+		share, _ := pet.Share(user.UID)
+		petShareInvite := &repository.PetShareInvite{
+			Pet:        pet.Summary(),
+			InvitedAt:  share.InvitedAt,
+			ValidFrom:  share.ValidFrom,
+			ValidUntil: share.ValidUntil,
+		}
+		if owner, ok := owners[pet.UserUID]; ok {
+			petShareInvite.OwnerEmail = owner.Email
+		}
+		petShareInvites = append(petShareInvites, petShareInvite)
 	}
 
-	openSharedPets, err := r.PetHandler.GetOpenSharedPets(ctx, user.UID)
-	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+	ctx.IndentedJSON(http.StatusOK, petShareInvites)
+}
+
+func (r Router) GetPetMedicines(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	petMedicines, err := r.MedicineHandler.GetAllForPet(ctx, user.UID, petFromCtx(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, petMedicines)
+		return
+	}
+}
+
+func (r Router) GetPetMedicine(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	medicineUuid := ctx.Params.ByName("uuid")
+	medicine, err := r.MedicineHandler.Get(ctx, user.UID, petFromCtx(ctx), medicineUuid)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else if notModified(ctx, medicine.Version) {
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, medicine)
+		return
+	}
+}
+
+func (r Router) GetPetFoods(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	petFoods, err := r.FoodHandler.GetAllForPet(ctx, user.UID, petFromCtx(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, petFoods)
+		return
+	}
+}
+
+func (r Router) GetPetFood(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	foodUuid := ctx.Params.ByName("uuid")
+	food, err := r.FoodHandler.Get(ctx, user.UID, petFromCtx(ctx), foodUuid)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else if notModified(ctx, food.Version) {
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, food)
+		return
+	}
+}
+
+func (r Router) GetToDos(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	todos, err := r.TodoHandler.GetAllForUser(ctx, user.UID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, todos)
+		return
+	}
+}
+
+func (r Router) SetToDoStatus(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	setToDoStatusRequest := &repository.SetToDoStatusRequest{}
+	err := ctx.ShouldBindJSON(&setToDoStatusRequest)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting set todo status request from json body")))
+		return
+	}
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	todos, err := r.TodoHandler.SetToDoStatus(ctx, user.UID, ctx.Params.ByName("uuid"), setToDoStatusRequest.NewStatus)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, todos)
+		return
+	}
+}
+
+func (r Router) GetHouseholds(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	households, err := r.HouseholdHandler.GetAllForUser(ctx, user.UID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, households)
+		return
+	}
+}
+
+func (r Router) GetHousehold(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	household, err := r.HouseholdHandler.Get(ctx, user.UID, householdUuid)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, household)
+		return
+	}
+}
+
+func (r Router) AddHousehold(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	household := &repository.Household{}
+	err = ctx.ShouldBindJSON(&household)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting household from json body")))
+		return
+	}
+
+	households, err := r.HouseholdHandler.Create(ctx, user.UID, household)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusCreated, households)
+		return
+	}
+}
+
+func (r Router) UpdateHousehold(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PUT")
+
+	household := &repository.Household{}
+	err := ctx.ShouldBindJSON(&household)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting household from json body")))
+		return
+	}
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	households, err := r.HouseholdHandler.Update(ctx, user.UID, householdUuid, household)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on updating household"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, households)
+		return
+	}
+}
+
+func (r Router) DeleteHousehold(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	households, err := r.HouseholdHandler.Delete(ctx, user.UID, householdUuid)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, households)
+		return
+	}
+}
+
+func (r Router) AddHouseholdMember(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	addHouseholdMemberRequest := &repository.AddHouseholdMemberRequest{}
+	err := ctx.ShouldBindJSON(&addHouseholdMemberRequest)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting add household member request from json body")))
+		return
+	}
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	memberUid, err := r.AuthMiddleware.GetUserUidByMail(ctx, addHouseholdMemberRequest.UserMailToAdd)
+	if err != nil {
+		ctx.Error(repository.NewNotFoundError("user_not_found", errors.Wrapf(err, "error on getting UID of user '%s' to add to household with UUID '%s'", addHouseholdMemberRequest.UserMailToAdd, householdUuid)))
+		return
+	}
+
+	households, err := r.HouseholdHandler.AddMember(ctx, user.UID, householdUuid, memberUid, addHouseholdMemberRequest.Role)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on adding member to household"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, households)
+		return
+	}
+}
+
+func (r Router) RemoveHouseholdMember(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	memberUid := ctx.Params.ByName("memberUid")
+	if len(memberUid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting member UID from request URL")))
+		return
+	}
+
+	households, err := r.HouseholdHandler.RemoveMember(ctx, user.UID, householdUuid, memberUid)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on removing member from household"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, households)
+		return
+	}
+}
+
+func (r Router) AddHouseholdPet(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PUT")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	petUuid := ctx.Params.ByName("petUuid")
+	if len(petUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting pet UUID from request URL")))
+		return
+	}
+
+	pets, err := r.HouseholdHandler.AddPet(ctx, user.UID, householdUuid, petUuid)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on adding pet to household"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, pets)
+		return
+	}
+}
+
+func (r Router) RemoveHouseholdPet(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	householdUuid := ctx.Params.ByName("householdUuid")
+	if len(householdUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting household UUID from request URL")))
+		return
+	}
+
+	petUuid := ctx.Params.ByName("petUuid")
+	if len(petUuid) == 0 {
+		ctx.Error(repository.NewValidationError("missing_parameter", errors.New("error on getting pet UUID from request URL")))
+		return
+	}
+
+	pets, err := r.HouseholdHandler.RemovePet(ctx, user.UID, householdUuid, petUuid)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on removing pet from household"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, pets)
+		return
+	}
+}
+
+func (r Router) GetCareSheet(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	from := time.Now()
+	if fromParameter := ctx.Query("from"); fromParameter != "" {
+		from, err = time.Parse("2006-01-02", fromParameter)
+		if err != nil {
+			ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting care sheet start date from request URL")))
+			return
+		}
+	}
+
+	until, err := time.Parse("2006-01-02", ctx.Query("until"))
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting care sheet end date from request URL")))
+		return
+	}
+
+	sheet, err := r.CareSheetHandler.Create(ctx, user.UID, ctx.QueryArray("pet"), from, until)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	switch ctx.DefaultQuery("format", "html") {
+	case "html":
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+		err = caresheet.RenderHTML(ctx.Writer, sheet)
+	case "pdf":
+		ctx.Header("Content-Type", "application/pdf")
+		ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"care-sheet-%s.pdf\"", sheet.From.Format("2006-01-02")))
+		err = caresheet.RenderPDF(ctx.Writer, sheet)
+	default:
+		ctx.Error(repository.NewValidationError("invalid_care_sheet_format", errors.New("care sheet format has to be 'html' or 'pdf'")))
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+}
+
+func (r Router) GetDevices(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	devices, err := r.DeviceHandler.GetAllForUser(ctx, user.UID)
+	if err != nil {
+		ctx.Error(err)
 		return
 	} else {
-		var petShareInvites []*repository.PetShareInvites
-		for _, pet := range openSharedPets {
-			ownerUser, err := r.AuthMiddleware.GetUserByUid(ctx, pet.UserUID)
-			if err != nil {
-				errorMsg := fmt.Sprintf("error on getting UID of pet owner user '%s'", pet.UserUID)
-				log.Error(errorMsg)
-				ctx.JSON(http.StatusInternalServerError, gin.H{"Message": errorMsg})
-				return
-			}
-			petShareInvites = append(petShareInvites, &repository.PetShareInvites{
-				Pet:        *pet,
-				OwnerEmail: ownerUser.Email,
-			})
-		}
-		ctx.IndentedJSON(http.StatusOK, petShareInvites)
+		ctx.IndentedJSON(http.StatusOK, devices)
 		return
 	}
 }
 
-func (r Router) GetPetMedicines(ctx *gin.Context) {
-	ctx.Header("Access-Control-Allow-Methods", "GET")
+func (r Router) RegisterDevice(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	registerDeviceRequest := &repository.RegisterDeviceRequest{}
+	err := ctx.ShouldBindJSON(&registerDeviceRequest)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting register device request from json body")))
+		return
+	}
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request body")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	devices, err := r.DeviceHandler.Register(ctx, user.UID, registerDeviceRequest)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on registering device"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, devices)
 		return
 	}
+}
+
+func (r Router) UnregisterDevice(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	unregisterDeviceRequest := &repository.UnregisterDeviceRequest{}
+	err := ctx.ShouldBindJSON(&unregisterDeviceRequest)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting unregister device request from json body")))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	petMedicines, err := r.MedicineHandler.GetAllForPet(ctx, user.UID, petUuid)
+	devices, err := r.DeviceHandler.Unregister(ctx, user.UID, unregisterDeviceRequest.Token)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on unregistering device"))
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, petMedicines)
+		ctx.IndentedJSON(http.StatusOK, devices)
 		return
 	}
 }
 
-func (r Router) GetPetMedicine(ctx *gin.Context) {
+func (r Router) GetPreferences(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "GET")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	preferences, err := r.PreferencesHandler.Get(ctx, user.UID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, preferences)
 		return
 	}
+}
+
+func (r Router) SetPreferences(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PUT")
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	preferences := &repository.UserPreferences{}
+	err := ctx.ShouldBindJSON(&preferences)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting preferences from json body")))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	medicineUuid := ctx.Params.ByName("uuid")
-	medicine, err := r.MedicineHandler.Get(ctx, user.UID, medicineUuid)
+	updatedPreferences, err := r.PreferencesHandler.Set(ctx, user.UID, preferences)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on setting preferences"))
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, medicine)
+		ctx.IndentedJSON(http.StatusOK, updatedPreferences)
 		return
 	}
 }
 
-func (r Router) GetPetFoods(ctx *gin.Context) {
+func (r Router) GetWebhooks(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "GET")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request body")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	webhooks, err := r.WebhookHandler.GetAllForUser(ctx, user.UID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, webhooks)
 		return
 	}
+}
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+func (r Router) AddWebhook(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	webhook := &repository.Webhook{}
+	err := ctx.ShouldBindJSON(&webhook)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting webhook from json body")))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	petFoods, err := r.FoodHandler.GetAllForPet(ctx, user.UID, petUuid)
+	webhooks, err := r.WebhookHandler.Create(ctx, user.UID, webhook)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on adding webhook"))
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, petFoods)
+		ctx.IndentedJSON(http.StatusOK, webhooks)
 		return
 	}
 }
 
-func (r Router) GetPetFood(ctx *gin.Context) {
+func (r Router) GetWebhook(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "GET")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	petUuid := ctx.Params.ByName("petUuid")
-	if len(petUuid) == 0 {
-		err := errors.New("error on getting pet UUID from request URL")
-		log.Error(err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	webhook, err := r.WebhookHandler.Get(ctx, user.UID, ctx.Params.ByName("webhookUuid"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, webhook)
 		return
 	}
+}
+
+func (r Router) UpdateWebhook(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "PUT")
 
-	hasAccess, err := r.PetHandler.UserHasAccess(ctx, user.UID, petUuid)
+	webhookUpdate := &repository.WebhookUpdate{}
+	err := ctx.ShouldBindJSON(&webhookUpdate)
 	if err != nil {
-		err := errors.New("error on checking if user has access to pet")
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting webhook from json body")))
 		return
 	}
 
-	if !hasAccess {
-		err := petAccessError
-		log.Error(err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"Error": err.Error()})
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	foodUuid := ctx.Params.ByName("uuid")
-	food, err := r.FoodHandler.Get(ctx, user.UID, foodUuid)
+	webhooks, err := r.WebhookHandler.Update(ctx, user.UID, ctx.Params.ByName("webhookUuid"), webhookUpdate)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(errors.Wrap(err, "error on updating webhook"))
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, food)
+		ctx.IndentedJSON(http.StatusOK, webhooks)
 		return
 	}
 }
 
-func (r Router) GetToDos(ctx *gin.Context) {
+func (r Router) DeleteWebhook(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "DELETE")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	webhooks, err := r.WebhookHandler.Delete(ctx, user.UID, ctx.Params.ByName("webhookUuid"))
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on deleting webhook"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, webhooks)
+		return
+	}
+}
+
+func (r Router) GetWebhookDeliveries(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "GET")
 
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	todos, err := r.TodoHandler.GetAllForUser(ctx, user.UID)
+	deliveries, err := r.WebhookHandler.GetDeliveries(ctx, user.UID, ctx.Params.ByName("webhookUuid"))
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, todos)
+		ctx.IndentedJSON(http.StatusOK, deliveries)
 		return
 	}
 }
 
-func (r Router) SetToDoStatus(ctx *gin.Context) {
+func (r Router) RedeliverWebhookDelivery(ctx *gin.Context) {
 	ctx.Header("Access-Control-Allow-Methods", "POST")
 
-	setToDoStatusRequest := &repository.SetToDoStatusRequest{}
-	err := ctx.BindJSON(&setToDoStatusRequest)
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		wrappedError := errors.Wrap(err, "error on getting set todo status request from json body")
-		log.Error(wrappedError)
-		ctx.JSON(http.StatusBadRequest, gin.H{"Error": wrappedError})
+		ctx.Error(err)
 		return
 	}
 
+	deliveries, err := r.WebhookHandler.Redeliver(ctx, user.UID, ctx.Params.ByName("webhookUuid"), ctx.Params.ByName("deliveryUuid"))
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on redelivering webhook delivery"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, deliveries)
+		return
+	}
+}
+
+func (r Router) GetTrash(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
 	user, err := r.AuthMiddleware.UserFromCtx(ctx)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	todos, err := r.TodoHandler.SetToDoStatus(ctx, user.UID, setToDoStatusRequest.NewStatus)
+	trashItems, err := r.TrashHandler.GetAllForUser(ctx, user.UID)
 	if err != nil {
-		log.Error(err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		ctx.Error(err)
 		return
 	} else {
-		ctx.IndentedJSON(http.StatusOK, todos)
+		ctx.IndentedJSON(http.StatusOK, trashItems)
+		return
+	}
+}
+
+func (r Router) RestoreTrashItem(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	trashItems, err := r.TrashHandler.Restore(ctx, user.UID, ctx.Params.ByName("uuid"))
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on restoring deleted document"))
 		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, trashItems)
+		return
+	}
+}
+
+func (r Router) GetSyncChanges(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	changes, err := r.SyncHandler.GetChanges(ctx, user.UID, ctx.Query("since"))
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on getting changes for sync"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, changes)
+		return
+	}
+}
+
+func (r Router) ApplySyncMutations(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "POST")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	batch := &repository.MutationBatch{}
+	err = ctx.ShouldBindJSON(batch)
+	if err != nil {
+		ctx.Error(repository.NewValidationError("invalid_request", errors.Wrap(err, "error on getting mutations from json body")))
+		return
+	}
+
+	results, err := r.SyncHandler.ApplyMutations(ctx, user.UID, batch)
+	if err != nil {
+		ctx.Error(errors.Wrap(err, "error on applying mutations"))
+		return
+	} else {
+		ctx.IndentedJSON(http.StatusOK, results)
+		return
+	}
+}
+
+// streamKeepAliveInterval is how often a comment is sent on an idle stream, so proxies don't close the connection
+const streamKeepAliveInterval = 25 * time.Second
+
+func (r Router) StreamEvents(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	connection := r.StreamHandler.Connect(ctx.Request.Context(), user.UID, ctx.GetHeader("Last-Event-ID"))
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-keepAlive.C:
+			fmt.Fprint(ctx.Writer, ": keep-alive\n\n")
+		case message, ok := <-connection.Messages():
+			if !ok {
+				return
+			}
+			if message.ID != "" {
+				fmt.Fprintf(ctx.Writer, "id: %s\n", message.ID)
+			}
+			fmt.Fprintf(ctx.Writer, "event: %s\ndata: %s\n\n", message.Name, message.Data)
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+func (r Router) ConnectWebSocket(ctx *gin.Context) {
+	ctx.Header("Access-Control-Allow-Methods", "GET")
+
+	user, err := r.AuthMiddleware.UserFromCtx(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	displayName := user.Email
+	userRecord, err := r.AuthMiddleware.GetUserByUid(ctx, user.UID)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "unable to get display name of user '%s'", user.UID))
+	} else if userRecord != nil && userRecord.DisplayName != "" {
+		displayName = userRecord.DisplayName
+	}
+
+	session, err := r.StreamHandler.OpenSession(user.UID, displayName)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	defer session.Close()
+
+	server := websocket.Server{
+		// browsers send the origin of the page opening the WebSocket, other clients don't send one
+		Handshake: func(config *websocket.Config, request *http.Request) error {
+			origin := request.Header.Get("Origin")
+			if origin != "" && !r.CORSMiddleware.AllowsOrigin(origin) {
+				return fmt.Errorf("origin '%s' is not allowed to open a stream", origin)
+			}
+
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			session.Serve(ctx.Request.Context(), conn)
+		},
 	}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
 }
 
 func (r Router) StartRouter(port string) {
 	r.Router.Use(r.CORSMiddleware.Middleware())
+	r.Router.Use(ErrorMiddleware())
 	r.Router.Use(r.AuthMiddleware.Middleware())
 
 	v1 := r.Router.Group("/api/v1")
@@ -989,48 +1549,137 @@ func (r Router) StartRouter(port string) {
 
 			pets.GET("/", r.GetPets)
 
-			pets.GET("/:uuid", r.GetPet)
+			pet := pets.Group("/:petUuid", r.PetMiddleware(repository.PET_ROLE_CARETAKER))
+			{
+				pet.GET("", r.GetPet)
+
+				pet.PUT("", r.UpdatePet)
 
-			pets.PUT("/:uuid", r.UpdatePet)
+				pet.PATCH("", r.PatchPet)
 
-			pets.DELETE("/:uuid", r.DeletePet)
+				pet.DELETE("", r.PetMiddleware(repository.PET_ROLE_OWNER), r.DeletePet)
 
-			medicines := pets.Group("/:petUuid/medicines")
-			{
-				medicines.POST("/", r.AddPetMedicine)
+				pet.POST("/archive", r.PetMiddleware(repository.PET_ROLE_OWNER), r.ArchivePet)
+
+				pet.DELETE("/archive", r.PetMiddleware(repository.PET_ROLE_OWNER), r.UnarchivePet)
+
+				pet.GET("/activity", r.GetPetActivity)
+
+				medicines := pet.Group("/medicines")
+				{
+					medicines.POST("/", r.AddPetMedicine)
+
+					medicines.GET("/", r.GetPetMedicines)
+
+					medicines.GET("/:uuid", r.GetPetMedicine)
+
+					medicines.PUT("/:uuid", r.UpdatePetMedicine)
+
+					medicines.PATCH("/:uuid", r.PatchPetMedicine)
+
+					medicines.DELETE("/:uuid", r.DeletePetMedicine)
+
+					medicines.GET("/:uuid/revisions", r.GetPetMedicineRevisions)
+
+					medicines.GET("/:uuid/revisions/diff", r.DiffPetMedicineRevisions)
+
+					medicines.GET("/:uuid/history", r.GetPetMedicineAt)
+				}
+
+				foods := pet.Group("/foods")
+				{
+					foods.POST("/", r.AddPetFood)
+
+					foods.GET("/", r.GetPetFoods)
+
+					foods.GET("/:uuid", r.GetPetFood)
+
+					foods.PUT("/:uuid", r.UpdatePetFood)
+
+					foods.PATCH("/:uuid", r.PatchPetFood)
+
+					foods.DELETE("/:uuid", r.DeletePetFood)
+				}
+
+				pet.POST("/shares/invites/", r.PetMiddleware(repository.PET_ROLE_OWNER), r.InviteToSharePet)
+			}
+
+			// the invited user has no access to the pet before answering, so the pet isn't loaded by the middleware
+			pets.POST("/:petUuid/shares/invites/answer", r.AnswerPetShareInvite)
+		}
+
+		households := v1.Group("/households")
+		{
+			households.POST("/", r.AddHousehold)
+
+			households.GET("/", r.GetHouseholds)
 
-				medicines.GET("/", r.GetPetMedicines)
+			households.GET("/:householdUuid", r.GetHousehold)
 
-				medicines.GET("/:uuid", r.GetPetMedicine)
+			households.PUT("/:householdUuid", r.UpdateHousehold)
 
-				medicines.PUT("/:uuid", r.UpdatePetMedicine)
+			households.DELETE("/:householdUuid", r.DeleteHousehold)
+
+			members := households.Group("/:householdUuid/members")
+			{
+				members.POST("/", r.AddHouseholdMember)
 
-				medicines.DELETE("/:uuid", r.DeletePetMedicine)
+				members.DELETE("/:memberUid", r.RemoveHouseholdMember)
 			}
 
-			foods := pets.Group("/:petUuid/foods")
+			householdPets := households.Group("/:householdUuid/pets")
 			{
-				foods.POST("/", r.AddPetFood)
+				householdPets.PUT("/:petUuid", r.AddHouseholdPet)
+
+				householdPets.DELETE("/:petUuid", r.RemoveHouseholdPet)
+			}
+		}
+
+		v1.GET("/caresheet", r.GetCareSheet)
+
+		v1.GET("/stream", r.StreamEvents)
+
+		v1.GET("/ws", r.ConnectWebSocket)
+
+		v1.GET("/sync", r.GetSyncChanges)
+
+		v1.POST("/sync/mutations", r.ApplySyncMutations)
 
-				foods.GET("/", r.GetPetFoods)
+		shares := v1.Group("/shares")
+		{
+			shares.GET("/invites", r.GetPetShareInvites)
+		}
 
-				foods.GET("/:uuid", r.GetPetFood)
+		me := v1.Group("/me")
+		{
+			devices := me.Group("/devices")
+			{
+				devices.GET("/", r.GetDevices)
 
-				foods.PUT("/:uuid", r.UpdatePetFood)
+				devices.POST("/", r.RegisterDevice)
 
-				foods.DELETE("/:uuid", r.DeletePetFood)
+				devices.DELETE("/", r.UnregisterDevice)
 			}
 
-			shares := pets.Group("/:petUuid/shares")
+			me.GET("/preferences", r.GetPreferences)
+
+			me.PUT("/preferences", r.SetPreferences)
+
+			webhooks := me.Group("/webhooks")
 			{
-				invites := shares.Group("/invites")
-				{
-					invites.POST("/", r.InviteToSharePet)
+				webhooks.GET("/", r.GetWebhooks)
 
-					invites.GET("/", r.GetPetShareInvites)
+				webhooks.POST("/", r.AddWebhook)
 
-					invites.POST("/answer", r.AnswerPetShareInvite)
-				}
+				webhooks.GET("/:webhookUuid", r.GetWebhook)
+
+				webhooks.PUT("/:webhookUuid", r.UpdateWebhook)
+
+				webhooks.DELETE("/:webhookUuid", r.DeleteWebhook)
+
+				webhooks.GET("/:webhookUuid/deliveries", r.GetWebhookDeliveries)
+
+				webhooks.POST("/:webhookUuid/deliveries/:deliveryUuid/redeliver", r.RedeliverWebhookDelivery)
 			}
 		}
 
@@ -1040,6 +1689,13 @@ func (r Router) StartRouter(port string) {
 
 			todos.POST("/:uuid/status", r.SetToDoStatus)
 		}
+
+		trash := v1.Group("/trash")
+		{
+			trash.GET("/", r.GetTrash)
+
+			trash.POST("/:uuid/restore", r.RestoreTrashItem)
+		}
 	}
 
 	r.Router.Run(":" + port)
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/notify"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DoseReminder creates a todo for every due medicine dose and notifies the caretakers of the pet about it. Doses are
+// due at the time of their frequency in the timezone of the pet owner, location is used for owners without one.
+type DoseReminder struct {
+	medicineRepository    repository.MedicineRepository
+	petRepository         repository.PetRepository
+	todoRepository        repository.TodoRepository
+	preferencesRepository repository.PreferencesRepository
+	notifier              notify.Notifier
+	location              *time.Location
+}
+
+func NewDoseReminder(medicineRepository repository.MedicineRepository, petRepository repository.PetRepository, todoRepository repository.TodoRepository, preferencesRepository repository.PreferencesRepository, notifier notify.Notifier, location *time.Location) *DoseReminder {
+	return &DoseReminder{medicineRepository, petRepository, todoRepository, preferencesRepository, notifier, location}
+}
+
+// Start checks for due doses at the beginning of every minute until the context is done
+func (d *DoseReminder) Start(ctx context.Context) {
+	go func() {
+		// medicines written before their dose times were mirrored wouldn't be found as due otherwise
+		err := d.medicineRepository.MirrorDoseTimes(ctx)
+		if err != nil {
+			log.Error(errors.Wrap(err, "failed to mirror dose times of medicines"))
+		}
+
+		everyMinute(ctx, "remind due doses", d.RemindDueDoses)
+	}()
+}
+
+// RemindDueDoses handles all medicine doses due in the minute of the given time. Only the medicines with a dose at
+// the current time of day in any timezone are loaded, the timezone of the pet owner decides if they are due.
+func (d *DoseReminder) RemindDueDoses(ctx context.Context, now time.Time) error {
+	now = now.Truncate(time.Minute)
+
+	medicines, err := d.medicineRepository.GetMedicinesWithDoseTimes(ctx, currentTimesOfDay(now))
+	if err != nil {
+		return err
+	}
+
+	ownerLocations := map[string]*time.Location{}
+	for _, medicine := range medicines {
+		pet, caretakers, err := d.petRepository.GetPetCaretakers(ctx, medicine.PetUUID.String())
+		if status.Code(errors.Cause(err)) == codes.NotFound {
+			// the pet is in the trash, its medicines are kept in case it is restored
+			continue
+		}
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to get caretakers of pet '%s' for due medicine '%s'", medicine.PetUUID, medicine.UUID))
+			continue
+		}
+		if pet.IsArchived() {
+			continue
+		}
+
+		location, ok := ownerLocations[pet.UserUID]
+		if !ok {
+			location = preferredLocation(ctx, d.preferencesRepository, pet.UserUID, d.location)
+			ownerLocations[pet.UserUID] = location
+		}
+		ownerNow := now.In(location)
+
+		for _, frequency := range medicine.Frequencies {
+			if frequency.Time != ownerNow.Format("15:04") || !frequency.IsDueOn(ownerNow) {
+				continue
+			}
+
+			// another instance or a restart within the same minute may have created the todo and notified already
+			created, err := d.todoRepository.AddToDo(ctx, repository.NewDoseToDo(pet, medicine, frequency.UUID, ownerNow))
+			if err != nil {
+				log.Error(errors.Wrapf(err, "failed to add todo for due medicine '%s'", medicine.UUID))
+				continue
+			}
+			if !created {
+				continue
+			}
+
+			for _, caretaker := range caretakers {
+				err := d.notifier.Notify(ctx, notify.Notification{
+					Type:    notify.NOTIFICATION_TYPE_DOSE_DUE,
+					UserUid: caretaker,
+					Data: map[string]string{
+						"PetUuid":      pet.UUID.String(),
+						"PetName":      pet.Name,
+						"MedicineUuid": medicine.UUID.String(),
+						"MedicineName": medicine.Name,
+						"Dosage":       fmt.Sprint(medicine.Dosage),
+						"Unit":         string(medicine.Unit),
+						"Time":         frequency.Time,
+					},
+				})
+				if err != nil {
+					log.Error(err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// currentTimesOfDay returns the time of day (HH:MM) it is at the given time in any timezone, timezones are between 12
+// hours behind and 14 hours ahead of UTC and offset by multiples of 15 minutes
+func currentTimesOfDay(t time.Time) []string {
+	timesOfDay := []string{}
+	addedTimesOfDay := map[string]bool{}
+	for offset := -12 * time.Hour; offset <= 14*time.Hour; offset += 15 * time.Minute {
+		timeOfDay := t.UTC().Add(offset).Format("15:04")
+		if !addedTimesOfDay[timeOfDay] {
+			timesOfDay = append(timesOfDay, timeOfDay)
+			addedTimesOfDay[timeOfDay] = true
+		}
+	}
+
+	return timesOfDay
+}
+
+// preferredLocation is the timezone of the user's preferences, falling back to the given location for users
+// without a timezone or with one which can't be loaded
+func preferredLocation(ctx context.Context, preferencesRepository repository.PreferencesRepository, userUid string, fallback *time.Location) *time.Location {
+	preferences, err := preferencesRepository.GetPreferences(ctx, userUid)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "failed to get timezone of user '%s'", userUid))
+		return fallback
+	}
+	if preferences.Timezone == "" {
+		return fallback
+	}
+
+	location, err := time.LoadLocation(preferences.Timezone)
+	if err != nil {
+		return fallback
+	}
+
+	return location
+}
@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/notify"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MissedDoseEscalator notifies the owner and afterwards the other caretakers of a pet when the todo
+// of a dose is still open after the delays of the medicine's escalation policy. Marking the todo as
+// done cancels all further escalation steps.
+type MissedDoseEscalator struct {
+	todoRepository        repository.TodoRepository
+	medicineRepository    repository.MedicineRepository
+	petRepository         repository.PetRepository
+	preferencesRepository repository.PreferencesRepository
+	notifier              notify.Notifier
+	location              *time.Location
+}
+
+func NewMissedDoseEscalator(todoRepository repository.TodoRepository, medicineRepository repository.MedicineRepository, petRepository repository.PetRepository, preferencesRepository repository.PreferencesRepository, notifier notify.Notifier, location *time.Location) *MissedDoseEscalator {
+	return &MissedDoseEscalator{todoRepository, medicineRepository, petRepository, preferencesRepository, notifier, location}
+}
+
+// errNotEscalated aborts the update of a todo whose escalation step is not sent, so the todo is left unchanged
+var errNotEscalated = errors.New("escalation step is not sent")
+
+// Start checks for missed doses at the beginning of every minute until the context is done
+func (e *MissedDoseEscalator) Start(ctx context.Context) {
+	everyMinute(ctx, "escalate missed doses", e.EscalateMissedDoses)
+}
+
+// EscalateMissedDoses runs the due escalation steps for all open dose todos. Todos due longer ago than the longest
+// escalation policy have no steps left and aren't loaded.
+func (e *MissedDoseEscalator) EscalateMissedDoses(ctx context.Context, now time.Time) error {
+	todos, err := e.todoRepository.GetOpenToDosDueBetween(ctx, now.Add(-repository.MaxEscalationWindow), now)
+	if err != nil {
+		return err
+	}
+
+	for _, todo := range todos {
+		if todo.MedicineUUID == uuid.Nil || todo.CaretakersNotifiedAt != nil {
+			continue
+		}
+
+		// the todos of a pet or medicine which was deleted are kept until they expire, they aren't escalated
+		medicine, err := e.medicineRepository.GetMedicine(ctx, todo.UserUID, todo.PetUUID.String(), todo.MedicineUUID.String())
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to get medicine of todo '%s' for escalation", todo.UUID))
+			continue
+		}
+		if medicine.Escalation == nil {
+			continue
+		}
+
+		pet, _, err := e.petRepository.GetPetCaretakers(ctx, todo.PetUUID.String())
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to get pet of todo '%s' for escalation", todo.UUID))
+			continue
+		}
+		if pet.IsArchived() {
+			continue
+		}
+
+		err = e.escalate(ctx, todo, medicine, now)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to escalate missed dose of todo '%s'", todo.UUID))
+		}
+	}
+
+	return nil
+}
+
+func (e *MissedDoseEscalator) escalate(ctx context.Context, todo *repository.ToDo, medicine *repository.Medicine, now time.Time) error {
+	policy := medicine.Escalation
+
+	if todo.OwnerRemindedAt == nil && policy.OwnerAfterMinutes > 0 {
+		if now.Before(todo.DueAt.Add(time.Duration(policy.OwnerAfterMinutes) * time.Minute)) {
+			return nil
+		}
+
+		escalated, err := e.markEscalated(ctx, todo, func(todo *repository.ToDo) bool {
+			if todo.OwnerRemindedAt != nil {
+				return false
+			}
+			todo.OwnerRemindedAt = &now
+			return true
+		})
+		if err != nil || !escalated {
+			return err
+		}
+
+		return e.notifyMissedDose(ctx, todo, medicine, []string{todo.UserUID})
+	}
+
+	if policy.CaretakersAfterMinutes <= 0 || now.Before(caretakersEscalationStart(todo, policy).Add(time.Duration(policy.CaretakersAfterMinutes)*time.Minute)) {
+		return nil
+	}
+
+	escalated, err := e.markEscalated(ctx, todo, func(todo *repository.ToDo) bool {
+		if todo.CaretakersNotifiedAt != nil {
+			return false
+		}
+		todo.CaretakersNotifiedAt = &now
+		return true
+	})
+	if err != nil || !escalated {
+		return err
+	}
+
+	_, caretakers, err := e.petRepository.GetPetCaretakers(ctx, todo.PetUUID.String())
+	if err != nil {
+		return err
+	}
+
+	otherCaretakers := []string{}
+	for _, caretaker := range caretakers {
+		if caretaker != todo.UserUID {
+			otherCaretakers = append(otherCaretakers, caretaker)
+		}
+	}
+
+	return e.notifyMissedDose(ctx, todo, medicine, otherCaretakers)
+}
+
+// caretakersEscalationStart is the time the delay of the caretakers is counted from, which is when the owner was
+// reminded or when the dose was due if the owner isn't reminded
+func caretakersEscalationStart(todo *repository.ToDo, policy *repository.EscalationPolicy) time.Time {
+	if policy.OwnerAfterMinutes <= 0 || todo.OwnerRemindedAt == nil {
+		return todo.DueAt
+	}
+
+	return *todo.OwnerRemindedAt
+}
+
+// markEscalated records an escalation step on the todo, so it is only sent once. It returns false
+// if the todo was done in the meantime or the step was already recorded by another run, the todo
+// isn't written then.
+func (e *MissedDoseEscalator) markEscalated(ctx context.Context, todo *repository.ToDo, markFn func(todo *repository.ToDo) bool) (bool, error) {
+	_, err := e.todoRepository.UpdateToDo(
+		ctx,
+		todo.UserUID,
+		todo.PetUUID.String(),
+		todo.UUID.String(),
+		func(ctx context.Context, firestoreToDo *repository.ToDo) (*repository.ToDo, error) {
+			if firestoreToDo.Status != repository.TODO_STATUS_OPEN || !markFn(firestoreToDo) {
+				return nil, errNotEscalated
+			}
+			return firestoreToDo, nil
+		},
+	)
+	if errors.Cause(err) == errNotEscalated {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (e *MissedDoseEscalator) notifyMissedDose(ctx context.Context, todo *repository.ToDo, medicine *repository.Medicine, userUids []string) error {
+	pet, err := e.petRepository.GetPet(ctx, todo.UserUID, todo.PetUUID.String())
+	if err != nil {
+		return err
+	}
+
+	for _, userUid := range userUids {
+		err := e.notifier.Notify(ctx, notify.Notification{
+			Type:    notify.NOTIFICATION_TYPE_MISSED_DOSE,
+			UserUid: userUid,
+			Data: map[string]string{
+				"PetUuid":      pet.UUID.String(),
+				"PetName":      pet.Name,
+				"MedicineUuid": medicine.UUID.String(),
+				"MedicineName": medicine.Name,
+				"Dosage":       fmt.Sprint(medicine.Dosage),
+				"Unit":         string(medicine.Unit),
+				"Time":         todo.DueAt.In(preferredLocation(ctx, e.preferencesRepository, pet.UserUID, e.location)).Format("15:04"),
+				"ToDoUuid":     todo.UUID.String(),
+			},
+		})
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var domainError repository.DomainError
+	if errors.As(err, &domainError) {
+		return domainError.Kind() == repository.ERROR_KIND_NOT_FOUND
+	}
+
+	return status.Code(errors.Cause(err)) == codes.NotFound
+}
@@ -0,0 +1,24 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+type QueuedNotificationSender interface {
+	SendQueuedNotifications(ctx context.Context, now time.Time) error
+}
+
+// NotificationQueueWorker sends the notifications which were held back by quiet hours or for the daily digest once they are due
+type NotificationQueueWorker struct {
+	sender QueuedNotificationSender
+}
+
+func NewNotificationQueueWorker(sender QueuedNotificationSender) *NotificationQueueWorker {
+	return &NotificationQueueWorker{sender}
+}
+
+// Start sends the due queued notifications at the beginning of every minute until the context is done
+func (w *NotificationQueueWorker) Start(ctx context.Context) {
+	everyMinute(ctx, "send queued notifications", w.sender.SendQueuedNotifications)
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// everyMinute runs the job at the beginning of every minute until the context is done
+func everyMinute(ctx context.Context, name string, job func(ctx context.Context, now time.Time) error) {
+	go func() {
+		for {
+			now := time.Now()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+			}
+
+			err := job(ctx, time.Now())
+			if err != nil {
+				log.Error(errors.Wrapf(err, "scheduled job '%s' failed", name))
+			}
+		}
+	}()
+}
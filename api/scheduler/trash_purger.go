@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// TrashPurger deletes the pets, medicines and foods in the trash for good once they can't be restored anymore
+type TrashPurger struct {
+	trashRepository repository.TrashRepository
+}
+
+func NewTrashPurger(trashRepository repository.TrashRepository) *TrashPurger {
+	return &TrashPurger{trashRepository}
+}
+
+// Start purges the expired trash at the beginning of every minute until the context is done
+func (p *TrashPurger) Start(ctx context.Context) {
+	everyMinute(ctx, "purge trash", p.PurgeTrash)
+}
+
+// PurgeTrash deletes all deleted documents whose retention ended before the given time
+func (p *TrashPurger) PurgeTrash(ctx context.Context, now time.Time) error {
+	trashItems, err := p.trashRepository.GetTrashItemsToPurge(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, trashItem := range trashItems {
+		err = p.trashRepository.PurgeTrashItem(ctx, trashItem)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to purge %s '%s'", trashItem.Kind, trashItem.UUID))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+type PendingWebhookDeliverySender interface {
+	SendPendingDeliveries(ctx context.Context, now time.Time) error
+}
+
+// WebhookDeliveryWorker sends the queued webhook deliveries and their retries once they are due
+type WebhookDeliveryWorker struct {
+	sender PendingWebhookDeliverySender
+}
+
+func NewWebhookDeliveryWorker(sender PendingWebhookDeliverySender) *WebhookDeliveryWorker {
+	return &WebhookDeliveryWorker{sender}
+}
+
+// Start sends the due webhook deliveries at the beginning of every minute until the context is done
+func (w *WebhookDeliveryWorker) Start(ctx context.Context) {
+	everyMinute(ctx, "send webhook deliveries", w.sender.SendPendingDeliveries)
+}
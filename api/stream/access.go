@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/cafo13/fur-meds/api/repository"
+)
+
+// accessCacheDuration is how long a connection trusts a checked pet access, a revoked share
+// stops the stream of the pet after at most this duration
+const accessCacheDuration = 30 * time.Second
+
+type petAccess struct {
+	hasAccess bool
+	checkedAt time.Time
+}
+
+// AccessFilter decides which messages the user of a single connection may receive
+type AccessFilter struct {
+	mutex         sync.Mutex
+	userUid       string
+	petRepository repository.PetRepository
+	petAccess     map[string]petAccess
+}
+
+func NewAccessFilter(userUid string, petRepository repository.PetRepository) *AccessFilter {
+	return &AccessFilter{
+		userUid:       userUid,
+		petRepository: petRepository,
+		petAccess:     map[string]petAccess{},
+	}
+}
+
+func (f *AccessFilter) Allows(ctx context.Context, message Message) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if petDeleted, ok := message.event.(events.PetDeleted); ok {
+		return f.allowsDeletedPet(petDeleted.Pet), nil
+	}
+
+	return f.hasAccess(ctx, message.PetUuid)
+}
+
+func (f *AccessFilter) HasAccess(ctx context.Context, petUuid string) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.hasAccess(ctx, petUuid)
+}
+
+func (f *AccessFilter) hasAccess(ctx context.Context, petUuid string) (bool, error) {
+	now := time.Now()
+	if access, ok := f.petAccess[petUuid]; ok && now.Sub(access.checkedAt) < accessCacheDuration {
+		return access.hasAccess, nil
+	}
+
+	hasAccess, err := f.petRepository.UserHasAccessToPet(ctx, f.userUid, petUuid)
+	if err != nil {
+		return false, err
+	}
+	f.petAccess[petUuid] = petAccess{hasAccess: hasAccess, checkedAt: now}
+
+	return hasAccess, nil
+}
+
+// allowsDeletedPet checks the access with the last state of the pet, as the pet itself can't be loaded anymore.
+// Household members are only informed if their access to the pet was checked before on this connection.
+func (f *AccessFilter) allowsDeletedPet(pet *repository.Pet) bool {
+	if pet == nil {
+		return false
+	}
+
+	petUuid := pet.UUID.String()
+	access, checked := f.petAccess[petUuid]
+	delete(f.petAccess, petUuid)
+
+	if pet.UserUID == f.userUid {
+		return true
+	}
+	if share, isShared := pet.Share(f.userUid); isShared && share.ShareAccepted && share.IsActiveAt(time.Now()) {
+		return true
+	}
+
+	return checked && access.hasAccess
+}
@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Connection is the stream of a single client, it only delivers the messages of the pets the user has access to
+type Connection struct {
+	messages chan Message
+}
+
+// Connect subscribes to the hub and delivers the missed messages first. The connection ends when the context
+// is done or the client is too slow to keep up with the stream.
+func Connect(ctx context.Context, hub *Hub, filter *AccessFilter, lastEventId string) *Connection {
+	subscription, missed, resumed := hub.Subscribe(lastEventId)
+	connection := &Connection{messages: make(chan Message)}
+
+	go func() {
+		defer close(connection.messages)
+		defer hub.Unsubscribe(subscription)
+
+		if !resumed {
+			if !connection.send(ctx, Message{Name: MESSAGE_NAME_RESYNC, Data: []byte("{}")}) {
+				return
+			}
+		}
+
+		for _, message := range missed {
+			if !connection.deliver(ctx, filter, message) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-subscription.Messages():
+				if !ok {
+					return
+				}
+				if !connection.deliver(ctx, filter, message) {
+					return
+				}
+			}
+		}
+	}()
+
+	return connection
+}
+
+// Messages returns the messages for the client, the channel is closed when the connection ends
+func (c *Connection) Messages() <-chan Message {
+	return c.messages
+}
+
+func (c *Connection) deliver(ctx context.Context, filter *AccessFilter, message Message) bool {
+	allowed, err := filter.Allows(ctx, message)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "unable to check access to pet '%s' for streamed event '%s'", message.PetUuid, message.ID))
+		return true
+	}
+	if !allowed {
+		return true
+	}
+
+	return c.send(ctx, message)
+}
+
+func (c *Connection) send(ctx context.Context, message Message) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case c.messages <- message:
+		return true
+	}
+}
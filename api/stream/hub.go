@@ -0,0 +1,148 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/pkg/errors"
+)
+
+const (
+	// historySize is the number of latest messages kept to resume a stream after a reconnect
+	historySize = 1000
+	// subscriptionQueueSize is the number of messages buffered per subscription, a subscription which
+	// falls further behind is closed and the client has to reconnect with the ID of its last message
+	subscriptionQueueSize = 64
+)
+
+// MESSAGE_NAME_RESYNC tells the client that the stream could not be resumed and it has to reload its data
+const MESSAGE_NAME_RESYNC events.Name = "Resync"
+
+// streamedEvents are the events which change the data of a pet shown by the clients
+var streamedEvents = map[events.Name]bool{
+	events.EVENT_NAME_PET_CREATED:      true,
+	events.EVENT_NAME_PET_UPDATED:      true,
+	events.EVENT_NAME_PET_DELETED:      true,
+	events.EVENT_NAME_MEDICINE_CREATED: true,
+	events.EVENT_NAME_MEDICINE_UPDATED: true,
+	events.EVENT_NAME_MEDICINE_DELETED: true,
+	events.EVENT_NAME_FOOD_CREATED:     true,
+	events.EVENT_NAME_FOOD_UPDATED:     true,
+	events.EVENT_NAME_FOOD_DELETED:     true,
+	events.EVENT_NAME_TODO_UPDATED:     true,
+}
+
+// Message is an event as it is sent to the clients, the data is the JSON of the event
+type Message struct {
+	ID      string
+	Name    events.Name
+	PetUuid string
+	Data    []byte
+	event   events.Event
+}
+
+type Subscription struct {
+	messages chan Message
+	closed   bool
+}
+
+// Messages returns the channel of the subscription, it is closed when the subscription ends
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Hub fans the streamed events of the bus out to the subscriptions of the connected clients
+type Hub struct {
+	mutex         sync.Mutex
+	history       []Message
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewHub(bus *events.Bus) *Hub {
+	hub := &Hub{subscriptions: map[*Subscription]struct{}{}}
+	events.SubscribeAll(bus, "stream", func(ctx context.Context, event events.Event) error {
+		if !streamedEvents[event.Name()] {
+			return nil
+		}
+
+		return hub.publish(event)
+	})
+
+	return hub
+}
+
+// Subscribe starts a subscription together with the messages published after the given last event ID.
+// If the last event ID is no longer known, resumed is false and the client has to reload its data.
+func (h *Hub) Subscribe(lastEventId string) (subscription *Subscription, missed []Message, resumed bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subscription = &Subscription{messages: make(chan Message, subscriptionQueueSize)}
+	h.subscriptions[subscription] = struct{}{}
+
+	if lastEventId == "" {
+		return subscription, nil, true
+	}
+
+	for index, message := range h.history {
+		if message.ID == lastEventId {
+			missed = append(missed, h.history[index+1:]...)
+			return subscription, missed, true
+		}
+	}
+
+	return subscription, nil, false
+}
+
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.close(subscription)
+}
+
+func (h *Hub) publish(event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal %s event '%s'", event.Name(), event.EventHeader().ID)
+	}
+
+	header := event.EventHeader()
+	message := Message{
+		ID:      header.ID,
+		Name:    event.Name(),
+		PetUuid: header.PetUuid,
+		Data:    data,
+		event:   event,
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.history = append(h.history, message)
+	if len(h.history) > historySize {
+		h.history = h.history[len(h.history)-historySize:]
+	}
+
+	for subscription := range h.subscriptions {
+		select {
+		case subscription.messages <- message:
+		default:
+			h.close(subscription)
+		}
+	}
+
+	return nil
+}
+
+func (h *Hub) close(subscription *Subscription) {
+	if subscription.closed {
+		return
+	}
+
+	subscription.closed = true
+	delete(h.subscriptions, subscription)
+	close(subscription.messages)
+}
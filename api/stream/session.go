@@ -0,0 +1,455 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/events"
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxSessionsPerUser is the number of WebSocket connections a single user may have open at the same time
+	maxSessionsPerUser = 5
+	// maxPetsPerSubscribe limits the pets of a subscribe message, the access to each of them is checked in firestore
+	maxPetsPerSubscribe = 50
+	// sessionQueueSize is the number of messages buffered per session, a session which falls further
+	// behind is closed, so a slow client can't hold back the messages of the others
+	sessionQueueSize = 64
+	// heartbeatInterval is how often the server pings the client
+	heartbeatInterval = 25 * time.Second
+	// readTimeout closes a session which didn't receive any message, including pongs, for this duration
+	readTimeout  = 2 * heartbeatInterval
+	writeTimeout = 10 * time.Second
+)
+
+var ErrTooManySessions = repository.NewTooManyRequestsError("too_many_sessions", fmt.Errorf("a user may not have more than %d live connections", maxSessionsPerUser))
+
+type ClientMessageType string
+
+const (
+	CLIENT_MESSAGE_TYPE_SUBSCRIBE   ClientMessageType = "subscribe"
+	CLIENT_MESSAGE_TYPE_UNSUBSCRIBE ClientMessageType = "unsubscribe"
+	CLIENT_MESSAGE_TYPE_PRESENCE    ClientMessageType = "presence"
+	CLIENT_MESSAGE_TYPE_PING        ClientMessageType = "ping"
+	CLIENT_MESSAGE_TYPE_PONG        ClientMessageType = "pong"
+)
+
+// ClientMessage is sent by the client, PetUuids are used to (un)subscribe and PetUuid to set the presence.
+// A presence without activity removes the presence of the session from the pet.
+type ClientMessage struct {
+	Type     ClientMessageType `json:"type"`
+	PetUuids []string          `json:"petUuids,omitempty"`
+	PetUuid  string            `json:"petUuid,omitempty"`
+	Activity string            `json:"activity,omitempty"`
+	Resource string            `json:"resource,omitempty"`
+}
+
+type ServerMessageType string
+
+const (
+	SERVER_MESSAGE_TYPE_EVENT        ServerMessageType = "event"
+	SERVER_MESSAGE_TYPE_SUBSCRIBED   ServerMessageType = "subscribed"
+	SERVER_MESSAGE_TYPE_UNSUBSCRIBED ServerMessageType = "unsubscribed"
+	SERVER_MESSAGE_TYPE_PRESENCE     ServerMessageType = "presence"
+	SERVER_MESSAGE_TYPE_PING         ServerMessageType = "ping"
+	SERVER_MESSAGE_TYPE_PONG         ServerMessageType = "pong"
+	SERVER_MESSAGE_TYPE_ERROR        ServerMessageType = "error"
+)
+
+type ServerMessage struct {
+	Type     ServerMessageType `json:"type"`
+	ID       string            `json:"id,omitempty"`
+	Name     events.Name       `json:"name,omitempty"`
+	PetUuid  string            `json:"petUuid,omitempty"`
+	PetUuids []string          `json:"petUuids,omitempty"`
+	Data     json.RawMessage   `json:"data,omitempty"`
+	Presence []Presence        `json:"presence,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Presence tells what a user is doing with a pet, e.g. the activity "editing" of the resource "medicines"
+type Presence struct {
+	UserUid     string    `json:"userUid"`
+	DisplayName string    `json:"displayName"`
+	Activity    string    `json:"activity"`
+	Resource    string    `json:"resource,omitempty"`
+	Since       time.Time `json:"since"`
+}
+
+// Sessions keeps track of the open WebSocket sessions to limit them per user and to share the presence
+// of a pet with all sessions subscribed to it
+type Sessions struct {
+	hub           *Hub
+	petRepository repository.PetRepository
+
+	mutex        sync.Mutex
+	sessions     map[*Session]struct{}
+	userSessions map[string]int
+}
+
+func NewSessions(hub *Hub, petRepository repository.PetRepository) *Sessions {
+	return &Sessions{
+		hub:           hub,
+		petRepository: petRepository,
+		sessions:      map[*Session]struct{}{},
+		userSessions:  map[string]int{},
+	}
+}
+
+// Open registers a new session of the user, it has to be closed even if it was never served
+func (s *Sessions) Open(userUid string, displayName string) (*Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.userSessions[userUid] >= maxSessionsPerUser {
+		return nil, ErrTooManySessions
+	}
+
+	session := &Session{
+		sessions:    s,
+		userUid:     userUid,
+		displayName: displayName,
+		filter:      NewAccessFilter(userUid, s.petRepository),
+		queue:       make(chan ServerMessage, sessionQueueSize),
+		done:        make(chan struct{}),
+		pets:        map[string]bool{},
+		presence:    map[string]Presence{},
+	}
+	s.sessions[session] = struct{}{}
+	s.userSessions[userUid]++
+
+	return session, nil
+}
+
+func (s *Sessions) remove(session *Session) {
+	s.mutex.Lock()
+	delete(s.sessions, session)
+	s.userSessions[session.userUid]--
+	if s.userSessions[session.userUid] <= 0 {
+		delete(s.userSessions, session.userUid)
+	}
+	s.mutex.Unlock()
+
+	// the session is closed, so its context can't be used anymore
+	for _, petUuid := range session.clearPresence() {
+		s.broadcastPresence(context.Background(), petUuid)
+	}
+}
+
+// broadcastPresence sends the current presence of the pet to all sessions subscribed to it which still have access to it
+func (s *Sessions) broadcastPresence(ctx context.Context, petUuid string) {
+	s.mutex.Lock()
+	subscribers := []*Session{}
+	for session := range s.sessions {
+		if session.isSubscribedTo(petUuid) {
+			subscribers = append(subscribers, session)
+		}
+	}
+	s.mutex.Unlock()
+
+	message := s.presenceMessage(petUuid)
+	for _, session := range subscribers {
+		session.enqueuePresence(ctx, message)
+	}
+}
+
+func (s *Sessions) presenceMessage(petUuid string) ServerMessage {
+	s.mutex.Lock()
+	presence := []Presence{}
+	for session := range s.sessions {
+		if petPresence, ok := session.petPresence(petUuid); ok {
+			presence = append(presence, petPresence)
+		}
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(presence, func(i, j int) bool {
+		return presence[i].Since.Before(presence[j].Since)
+	})
+
+	return ServerMessage{Type: SERVER_MESSAGE_TYPE_PRESENCE, PetUuid: petUuid, Presence: presence}
+}
+
+// Session is a single WebSocket connection of a user, it receives the events and the presence of the pets it subscribed to
+type Session struct {
+	sessions    *Sessions
+	userUid     string
+	displayName string
+	filter      *AccessFilter
+	queue       chan ServerMessage
+	done        chan struct{}
+	closeOnce   sync.Once
+	conn        *websocket.Conn
+
+	mutex    sync.Mutex
+	pets     map[string]bool
+	presence map[string]Presence
+}
+
+// Serve handles the connection until the client disconnects, misses the heartbeats or can't keep up with its messages
+func (s *Session) Serve(ctx context.Context, conn *websocket.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mutex.Lock()
+	s.conn = conn
+	s.mutex.Unlock()
+	defer s.Close()
+
+	subscription, _, _ := s.sessions.hub.Subscribe("")
+	defer s.sessions.hub.Unsubscribe(subscription)
+
+	go s.write(conn)
+	go s.forwardEvents(ctx, subscription)
+
+	for {
+		err := conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if err != nil {
+			return
+		}
+
+		var message ClientMessage
+		err = websocket.JSON.Receive(conn, &message)
+		if err != nil {
+			return
+		}
+
+		s.handle(ctx, message)
+	}
+}
+
+// Close ends the session, it is safe to call it more than once
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.mutex.Lock()
+		conn := s.conn
+		s.mutex.Unlock()
+		if conn != nil {
+			conn.Close()
+		}
+
+		s.sessions.remove(s)
+	})
+}
+
+func (s *Session) handle(ctx context.Context, message ClientMessage) {
+	switch message.Type {
+	case CLIENT_MESSAGE_TYPE_SUBSCRIBE:
+		s.subscribe(ctx, message.PetUuids)
+	case CLIENT_MESSAGE_TYPE_UNSUBSCRIBE:
+		s.unsubscribe(ctx, message.PetUuids)
+	case CLIENT_MESSAGE_TYPE_PRESENCE:
+		s.setPresence(ctx, message.PetUuid, message.Activity, message.Resource)
+	case CLIENT_MESSAGE_TYPE_PING:
+		s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_PONG})
+	case CLIENT_MESSAGE_TYPE_PONG:
+		// every received message extends the read deadline, nothing else to do
+	default:
+		s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_ERROR, Error: fmt.Sprintf("unknown message type '%s'", message.Type)})
+	}
+}
+
+func (s *Session) subscribe(ctx context.Context, petUuids []string) {
+	if len(petUuids) > maxPetsPerSubscribe {
+		s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_ERROR, Error: fmt.Sprintf("a subscribe message may not have more than %d pets", maxPetsPerSubscribe)})
+		return
+	}
+
+	subscribed := []string{}
+	for _, petUuid := range petUuids {
+		hasAccess, err := s.filter.HasAccess(ctx, petUuid)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "unable to check access of user '%s' to pet '%s'", s.userUid, petUuid))
+		}
+		if err != nil || !hasAccess {
+			s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_ERROR, PetUuid: petUuid, Error: fmt.Sprintf("unable to subscribe to pet '%s'", petUuid)})
+			continue
+		}
+
+		s.mutex.Lock()
+		s.pets[petUuid] = true
+		s.mutex.Unlock()
+		subscribed = append(subscribed, petUuid)
+	}
+
+	if len(subscribed) == 0 {
+		return
+	}
+
+	s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_SUBSCRIBED, PetUuids: subscribed})
+	for _, petUuid := range subscribed {
+		s.enqueue(s.sessions.presenceMessage(petUuid))
+	}
+}
+
+func (s *Session) unsubscribe(ctx context.Context, petUuids []string) {
+	leftPets := []string{}
+	s.mutex.Lock()
+	for _, petUuid := range petUuids {
+		delete(s.pets, petUuid)
+		if _, ok := s.presence[petUuid]; ok {
+			delete(s.presence, petUuid)
+			leftPets = append(leftPets, petUuid)
+		}
+	}
+	s.mutex.Unlock()
+
+	s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_UNSUBSCRIBED, PetUuids: petUuids})
+	for _, petUuid := range leftPets {
+		s.sessions.broadcastPresence(ctx, petUuid)
+	}
+}
+
+func (s *Session) setPresence(ctx context.Context, petUuid string, activity string, resource string) {
+	s.mutex.Lock()
+	if !s.pets[petUuid] {
+		s.mutex.Unlock()
+		s.enqueue(ServerMessage{Type: SERVER_MESSAGE_TYPE_ERROR, PetUuid: petUuid, Error: fmt.Sprintf("presence needs a subscription to pet '%s'", petUuid)})
+		return
+	}
+
+	if activity == "" {
+		delete(s.presence, petUuid)
+	} else {
+		since := time.Now()
+		if current, ok := s.presence[petUuid]; ok && current.Activity == activity && current.Resource == resource {
+			since = current.Since
+		}
+		s.presence[petUuid] = Presence{
+			UserUid:     s.userUid,
+			DisplayName: s.displayName,
+			Activity:    activity,
+			Resource:    resource,
+			Since:       since,
+		}
+	}
+	s.mutex.Unlock()
+
+	s.sessions.broadcastPresence(ctx, petUuid)
+}
+
+// clearPresence removes the presence of the session and returns the pets it was present at
+func (s *Session) clearPresence() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	petUuids := []string{}
+	for petUuid := range s.presence {
+		petUuids = append(petUuids, petUuid)
+	}
+	s.presence = map[string]Presence{}
+	s.pets = map[string]bool{}
+
+	return petUuids
+}
+
+func (s *Session) isSubscribedTo(petUuid string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.pets[petUuid]
+}
+
+func (s *Session) petPresence(petUuid string) (Presence, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	presence, ok := s.presence[petUuid]
+	return presence, ok
+}
+
+// forwardEvents sends the events of the subscribed pets, the session is closed if the hub dropped the subscription
+func (s *Session) forwardEvents(ctx context.Context, subscription *Subscription) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case message, ok := <-subscription.Messages():
+			if !ok {
+				s.Close()
+				return
+			}
+			if !s.isSubscribedTo(message.PetUuid) {
+				continue
+			}
+
+			allowed, err := s.filter.Allows(ctx, message)
+			if err != nil {
+				log.Error(errors.Wrapf(err, "unable to check access to pet '%s' for streamed event '%s'", message.PetUuid, message.ID))
+				continue
+			}
+			if !allowed {
+				continue
+			}
+
+			s.enqueue(ServerMessage{
+				Type:    SERVER_MESSAGE_TYPE_EVENT,
+				ID:      message.ID,
+				Name:    message.Name,
+				PetUuid: message.PetUuid,
+				Data:    message.Data,
+			})
+		}
+	}
+}
+
+// enqueuePresence queues the presence of a pet only if the user still has access to it, like forwardEvents does for
+// events, as a share may have ended since the session subscribed to the pet
+func (s *Session) enqueuePresence(ctx context.Context, message ServerMessage) {
+	hasAccess, err := s.filter.HasAccess(ctx, message.PetUuid)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "unable to check access to pet '%s' for streamed presence", message.PetUuid))
+		return
+	}
+	if !hasAccess {
+		return
+	}
+
+	s.enqueue(message)
+}
+
+// enqueue queues the message without blocking, a client which doesn't keep up with its queue is disconnected
+func (s *Session) enqueue(message ServerMessage) {
+	select {
+	case <-s.done:
+	case s.queue <- message:
+	default:
+		log.Warnf("closing live connection of user '%s' as it can't keep up with its messages", s.userUid)
+		go s.Close()
+	}
+}
+
+func (s *Session) write(conn *websocket.Conn) {
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var message ServerMessage
+		select {
+		case <-s.done:
+			return
+		case <-heartbeat.C:
+			message = ServerMessage{Type: SERVER_MESSAGE_TYPE_PING}
+		case message = <-s.queue:
+		}
+
+		err := conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err == nil {
+			err = websocket.JSON.Send(conn, message)
+		}
+		if err != nil {
+			s.Close()
+			return
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Enum is implemented by the string types of the repository which only allow a set of values
+type Enum interface {
+	IsValid() bool
+}
+
+// Violation is a rule a value doesn't follow, Pointer is the JSON pointer (RFC 6901) of the value in the document
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error reports every violation of a validated document at once
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	violations := []string{}
+	for _, violation := range e.Violations {
+		violations = append(violations, fmt.Sprintf("%s %s", violation.Pointer, violation.Message))
+	}
+
+	return "invalid document: " + strings.Join(violations, ", ")
+}
+
+func (e *Error) Kind() repository.ErrorKind {
+	return repository.ERROR_KIND_VALIDATION
+}
+
+func (e *Error) Code() string {
+	return "validation_failed"
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	validate := validator.New()
+
+	// violations are reported by the names of the JSON documents
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+
+		return name
+	})
+
+	// the nil UUID counts as no value, so it fails required and is skipped by omitempty
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		value := field.Interface().(uuid.UUID)
+		if value == uuid.Nil {
+			return ""
+		}
+
+		return value.String()
+	}, uuid.UUID{})
+
+	mustRegister(validate, "enum", isEnum)
+	mustRegister(validate, "timeofday", isTimeOfDay)
+	mustRegister(validate, "uuid", isUUID)
+
+	validate.RegisterStructValidation(validateEscalationPolicy, repository.EscalationPolicy{})
+	validate.RegisterStructValidation(validateMedicine, repository.Medicine{})
+	validate.RegisterStructValidation(validateFood, repository.Food{})
+
+	return validate
+}
+
+func mustRegister(validate *validator.Validate, tag string, fn validator.Func) {
+	err := validate.RegisterValidation(tag, fn)
+	if err != nil {
+		panic(errors.Wrapf(err, "failed to register validation '%s'", tag))
+	}
+}
+
+// Validate checks the rules of the validate tags of the document, which has to be a struct or a pointer to one.
+// All violations are returned together as an *Error.
+func Validate(document interface{}) error {
+	err := validate.Struct(document)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	violations := []Violation{}
+	for _, fieldError := range fieldErrors {
+		violations = append(violations, Violation{
+			Pointer: jsonPointer(fieldError.Namespace()),
+			Rule:    fieldError.Tag(),
+			Message: message(fieldError),
+		})
+	}
+
+	return &Error{Violations: violations}
+}
+
+// IsTimeOfDay checks if the value is a time of the day formatted as HH:MM
+func IsTimeOfDay(value string) bool {
+	_, err := time.Parse("15:04", value)
+	return err == nil && len(value) == len("15:04")
+}
+
+func isEnum(field validator.FieldLevel) bool {
+	enum, ok := field.Field().Interface().(Enum)
+	return ok && enum.IsValid()
+}
+
+func isTimeOfDay(field validator.FieldLevel) bool {
+	return IsTimeOfDay(field.Field().String())
+}
+
+func isUUID(field validator.FieldLevel) bool {
+	_, err := uuid.Parse(field.Field().String())
+	return err == nil
+}
+
+// validateEscalationPolicy checks that caretakers are only notified after the owner, who is reminded first
+func validateEscalationPolicy(structLevel validator.StructLevel) {
+	policy := structLevel.Current().Interface().(repository.EscalationPolicy)
+	if policy.CaretakersAfterMinutes > 0 && policy.OwnerAfterMinutes <= 0 {
+		structLevel.ReportError(policy.CaretakersAfterMinutes, "caretakersAfterMinutes", "CaretakersAfterMinutes", "requires_owner_step", "")
+	}
+}
+
+// validateMedicine checks that the frequencies of the medicine can be told apart, the todos of a dose refer to them
+func validateMedicine(structLevel validator.StructLevel) {
+	medicine := structLevel.Current().Interface().(repository.Medicine)
+	frequencyUuids := []uuid.UUID{}
+	for _, frequency := range medicine.Frequencies {
+		frequencyUuids = append(frequencyUuids, frequency.UUID)
+	}
+	reportDuplicateUuids(structLevel, medicine.Frequencies, "frequencies", "Frequencies", frequencyUuids)
+}
+
+// validateFood checks that the frequencies of the food can be told apart
+func validateFood(structLevel validator.StructLevel) {
+	food := structLevel.Current().Interface().(repository.Food)
+	frequencyUuids := []uuid.UUID{}
+	for _, frequency := range food.Frequencies {
+		frequencyUuids = append(frequencyUuids, frequency.UUID)
+	}
+	reportDuplicateUuids(structLevel, food.Frequencies, "frequencies", "Frequencies", frequencyUuids)
+}
+
+func reportDuplicateUuids(structLevel validator.StructLevel, field interface{}, fieldName string, structFieldName string, uuids []uuid.UUID) {
+	seenUuids := map[uuid.UUID]bool{}
+	for _, value := range uuids {
+		if value != uuid.Nil && seenUuids[value] {
+			structLevel.ReportError(field, fieldName, structFieldName, "unique", "uuid")
+			return
+		}
+		seenUuids[value] = true
+	}
+}
+
+// jsonPointer converts the namespace of a field error like 'Medicine.frequencies[0].time' to '/frequencies/0/time'
+func jsonPointer(namespace string) string {
+	_, path, _ := strings.Cut(namespace, ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	pointer := ""
+	for _, segment := range strings.Split(path, ".") {
+		pointer += "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(segment)
+	}
+
+	return pointer
+}
+
+func message(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return fmt.Sprintf("has to be greater than %s", fieldError.Param())
+	case "gte":
+		return fmt.Sprintf("has to be at least %s", fieldError.Param())
+	case "lte":
+		return fmt.Sprintf("has to be at most %s", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("may not be longer than %s characters", fieldError.Param())
+	case "email":
+		return fmt.Sprintf("'%v' has to be an email address", fieldError.Value())
+	case "enum":
+		return fmt.Sprintf("'%v' is not a known value", fieldError.Value())
+	case "timeofday":
+		return fmt.Sprintf("'%v' has to be a time formatted as HH:MM", fieldError.Value())
+	case "uuid":
+		return fmt.Sprintf("'%v' has to be a UUID", fieldError.Value())
+	case "unique":
+		return fmt.Sprintf("entries need a unique %s", fieldError.Param())
+	case "requires_owner_step":
+		return "requires the owner to be reminded first, ownerAfterMinutes has to be greater than 0"
+	default:
+		return fmt.Sprintf("violates the rule '%s'", fieldError.Tag())
+	}
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxDeliveryAttempts is the number of attempts after which a delivery is given up
+	maxDeliveryAttempts = 8
+	// initialRetryDelay is doubled after every failed attempt, up to maxRetryDelay
+	initialRetryDelay = time.Minute
+	maxRetryDelay     = 6 * time.Hour
+)
+
+// DeliveryWorker sends the pending webhook deliveries and retries failed ones with exponential backoff
+type DeliveryWorker struct {
+	webhookRepository repository.WebhookRepository
+	httpClient        *http.Client
+}
+
+func NewDeliveryWorker(webhookRepository repository.WebhookRepository) *DeliveryWorker {
+	return &DeliveryWorker{webhookRepository, NewPublicHTTPClient(10 * time.Second)}
+}
+
+// SendPendingDeliveries attempts every pending delivery whose next attempt is due
+func (w *DeliveryWorker) SendPendingDeliveries(ctx context.Context, now time.Time) error {
+	deliveries, err := w.webhookRepository.GetPendingWebhookDeliveriesDueBefore(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		err := w.deliver(ctx, delivery)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "failed to process webhook delivery '%s'", delivery.UUID))
+		}
+	}
+
+	return nil
+}
+
+func (w *DeliveryWorker) deliver(ctx context.Context, delivery *repository.WebhookDelivery) error {
+	webhook, err := w.webhookRepository.GetWebhook(ctx, delivery.UserUID, delivery.WebhookUUID.String())
+	if err != nil {
+		return w.record(ctx, delivery, repository.WebhookDeliveryAttempt{AttemptedAt: time.Now(), Error: err.Error()}, false)
+	}
+
+	attempt := w.send(ctx, webhook, delivery)
+
+	return w.record(ctx, delivery, attempt, webhook.Active)
+}
+
+func (w *DeliveryWorker) send(ctx context.Context, webhook *repository.Webhook, delivery *repository.WebhookDelivery) repository.WebhookDeliveryAttempt {
+	attempt := repository.WebhookDeliveryAttempt{AttemptedAt: time.Now()}
+	body := []byte(delivery.Payload)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	if request.URL.Scheme != "https" {
+		attempt.Error = fmt.Sprintf("webhook URL '%s' is not an https URL", webhook.URL)
+		return attempt
+	}
+
+	timestamp := attempt.AttemptedAt.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, delivery.UUID.String())
+	request.Header.Set(TimestampHeader, fmt.Sprint(timestamp))
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	response, err := w.httpClient.Do(request)
+	attempt.DurationMs = time.Since(attempt.AttemptedAt).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("webhook responded with status %d", response.StatusCode)
+	}
+
+	return attempt
+}
+
+// record adds the attempt to the delivery log and schedules the next attempt if the delivery failed and may be retried
+func (w *DeliveryWorker) record(ctx context.Context, delivery *repository.WebhookDelivery, attempt repository.WebhookDeliveryAttempt, retry bool) error {
+	return w.webhookRepository.UpdateWebhookDelivery(
+		ctx,
+		delivery.UUID.String(),
+		func(ctx context.Context, firestoreDelivery *repository.WebhookDelivery) (*repository.WebhookDelivery, error) {
+			firestoreDelivery.Attempts = append(firestoreDelivery.Attempts, attempt)
+
+			switch {
+			case attempt.Error == "":
+				firestoreDelivery.Status = repository.WEBHOOK_DELIVERY_STATUS_SUCCEEDED
+			case !retry || len(firestoreDelivery.Attempts) >= maxDeliveryAttempts:
+				firestoreDelivery.Status = repository.WEBHOOK_DELIVERY_STATUS_FAILED
+			default:
+				firestoreDelivery.NextAttemptAt = attempt.AttemptedAt.Add(RetryDelay(len(firestoreDelivery.Attempts)))
+			}
+
+			return firestoreDelivery, nil
+		},
+	)
+}
+
+// RetryDelay is the backoff before the next attempt after the given number of failed attempts
+func RetryDelay(failedAttempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < failedAttempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cafo13/fur-meds/api/repository"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// deliveriesNamespace is used to derive the UUIDs of deliveries, so an event published twice, e.g. when a failed dispatch is retried, is only delivered once per webhook
+var deliveriesNamespace = uuid.MustParse("5b0f3c8e-2a71-4d9b-8e65-93c4f1a7d2b0")
+
+type Dispatcher interface {
+	Dispatch(ctx context.Context, event Event) error
+}
+
+// QueueDispatcher queues a delivery for every webhook subscribed to an event, the deliveries are sent by the DeliveryWorker
+type QueueDispatcher struct {
+	webhookRepository repository.WebhookRepository
+	petRepository     repository.PetRepository
+}
+
+func NewQueueDispatcher(webhookRepository repository.WebhookRepository, petRepository repository.PetRepository) Dispatcher {
+	return QueueDispatcher{webhookRepository, petRepository}
+}
+
+func (d QueueDispatcher) Dispatch(ctx context.Context, event Event) error {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	userUids, err := d.recipients(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := d.webhookRepository.GetSubscribedWebhooks(ctx, userUids, string(event.Type))
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %s event", event.Type)
+	}
+
+	for _, webhook := range webhooks {
+		err := d.webhookRepository.AddWebhookDelivery(ctx, &repository.WebhookDelivery{
+			UUID:          uuid.NewSHA1(deliveriesNamespace, []byte(webhook.UUID.String()+event.ID)),
+			WebhookUUID:   webhook.UUID,
+			UserUID:       webhook.UserUID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       string(payload),
+			Status:        repository.WEBHOOK_DELIVERY_STATUS_PENDING,
+			NextAttemptAt: event.OccurredAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recipients are the caretakers of the pet of the event, events of deleted pets can't be looked up anymore
+// and are only sent to the owner, which is part of the deleted pet in the event data
+func (d QueueDispatcher) recipients(ctx context.Context, event Event) ([]string, error) {
+	if pet, ok := event.Data.(*repository.Pet); ok && event.Type == EVENT_TYPE_PET_DELETED {
+		return []string{pet.UserUID}, nil
+	}
+
+	_, caretakers, err := d.petRepository.GetPetCaretakers(ctx, event.PetUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get caretakers of pet '%s' for %s event", event.PetUUID, event.Type)
+	}
+
+	return caretakers, nil
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sharedAddressSpace is used for carrier-grade NAT, it is not reachable from the internet either
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// NewPublicHTTPClient creates a client which only connects to public addresses over https. The address is checked
+// after the host was resolved, so a webhook can't reach internal services by changing the DNS record of its host.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("webhook address '%s' is not public", address)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if request.URL.Scheme != "https" {
+				return errors.Errorf("webhook redirected to '%s', which is not an https URL", request.URL)
+			}
+			if len(via) >= 10 {
+				return errors.New("webhook redirected too often")
+			}
+
+			return nil
+		},
+	}
+}
+
+// IsPublicIP checks if the address is reachable from the internet, loopback, private, link-local and other special
+// addresses are not
+func IsPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// IsPublicHost checks if the host of a webhook URL may be public. Names are only resolved when connecting, here only
+// IP addresses and local names are rejected.
+func IsPublicHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPublicIP(ip)
+	}
+
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/cafo13/fur-meds/api/events"
+)
+
+// SubscribeToEvents dispatches the domain events webhooks can be subscribed to
+func SubscribeToEvents(bus *events.Bus, dispatcher Dispatcher) {
+	events.SubscribeAll(bus, "webhooks", func(ctx context.Context, event events.Event) error {
+		eventType, data, ok := webhookEvent(event)
+		if !ok {
+			return nil
+		}
+
+		header := event.EventHeader()
+		return dispatcher.Dispatch(ctx, Event{
+			ID:         header.ID,
+			Type:       eventType,
+			PetUUID:    header.PetUuid,
+			OccurredAt: header.OccurredAt,
+			Data:       data,
+		})
+	})
+}
+
+// webhookEvent maps a domain event to the webhook event type and the data sent with it
+func webhookEvent(event events.Event) (EventType, interface{}, bool) {
+	switch e := event.(type) {
+	case events.PetCreated:
+		return EVENT_TYPE_PET_CREATED, e.Pet, true
+	case events.PetUpdated:
+		return EVENT_TYPE_PET_UPDATED, e.Pet, true
+	case events.PetDeleted:
+		return EVENT_TYPE_PET_DELETED, e.Pet, true
+	case events.MedicineCreated:
+		return EVENT_TYPE_MEDICINE_CREATED, e.Medicine, true
+	case events.MedicineUpdated:
+		return EVENT_TYPE_MEDICINE_UPDATED, e.Medicine, true
+	case events.MedicineDeleted:
+		return EVENT_TYPE_MEDICINE_DELETED, e.Medicine, true
+	case events.FoodCreated:
+		return EVENT_TYPE_FOOD_CREATED, e.Food, true
+	case events.FoodUpdated:
+		return EVENT_TYPE_FOOD_UPDATED, e.Food, true
+	case events.FoodDeleted:
+		return EVENT_TYPE_FOOD_DELETED, e.Food, true
+	case events.DoseRecorded:
+		return EVENT_TYPE_DOSE_RECORDED, e.ToDo, true
+	case events.ToDoCompleted:
+		return EVENT_TYPE_TODO_COMPLETED, e.ToDo, true
+	case events.StockLow:
+		return EVENT_TYPE_STOCK_LOW, e, true
+	}
+
+	return "", nil, false
+}
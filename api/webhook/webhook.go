@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	EVENT_TYPE_PET_CREATED      EventType = "pet.created"
+	EVENT_TYPE_PET_UPDATED      EventType = "pet.updated"
+	EVENT_TYPE_PET_DELETED      EventType = "pet.deleted"
+	EVENT_TYPE_MEDICINE_CREATED EventType = "medicine.created"
+	EVENT_TYPE_MEDICINE_UPDATED EventType = "medicine.updated"
+	EVENT_TYPE_MEDICINE_DELETED EventType = "medicine.deleted"
+	EVENT_TYPE_FOOD_CREATED     EventType = "food.created"
+	EVENT_TYPE_FOOD_UPDATED     EventType = "food.updated"
+	EVENT_TYPE_FOOD_DELETED     EventType = "food.deleted"
+	EVENT_TYPE_DOSE_RECORDED    EventType = "dose.recorded"
+	EVENT_TYPE_TODO_COMPLETED   EventType = "todo.completed"
+	EVENT_TYPE_STOCK_LOW        EventType = "stock.low"
+)
+
+var eventTypes = []EventType{
+	EVENT_TYPE_PET_CREATED,
+	EVENT_TYPE_PET_UPDATED,
+	EVENT_TYPE_PET_DELETED,
+	EVENT_TYPE_MEDICINE_CREATED,
+	EVENT_TYPE_MEDICINE_UPDATED,
+	EVENT_TYPE_MEDICINE_DELETED,
+	EVENT_TYPE_FOOD_CREATED,
+	EVENT_TYPE_FOOD_UPDATED,
+	EVENT_TYPE_FOOD_DELETED,
+	EVENT_TYPE_DOSE_RECORDED,
+	EVENT_TYPE_TODO_COMPLETED,
+	EVENT_TYPE_STOCK_LOW,
+}
+
+func IsEventType(value string) bool {
+	for _, eventType := range eventTypes {
+		if string(eventType) == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	SignatureHeader = "X-FurMeds-Signature"
+	TimestampHeader = "X-FurMeds-Timestamp"
+	EventHeader     = "X-FurMeds-Event"
+	DeliveryHeader  = "X-FurMeds-Delivery"
+)
+
+// Event is something that happened to a pet or one of its resources. It is sent to the webhooks
+// of every caretaker of the pet who subscribed to its type.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       EventType   `json:"type"`
+	PetUUID    string      `json:"petUuid"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// Sign calculates the HMAC-SHA256 signature of a delivery. The timestamp is part of the signed content,
+// so receivers can reject replayed deliveries. The signature is sent as "sha256=<hex>" in the SignatureHeader.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret generates a random secret for signing the deliveries of a webhook
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}